@@ -0,0 +1,59 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	log "github.com/sirupsen/logrus"
+)
+
+const bannerPrompt = "You are the %s service of a production server. " +
+	"Reply ONLY with the single line the service sends to a client right after connecting " +
+	"(for SSH the identification string like SSH-2.0-..., for HTTP the Server header), nothing else."
+
+var defaultBanners = map[tracer.Protocol]string{
+	tracer.SSH:  "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6",
+	tracer.HTTP: "Server: nginx/1.18.0 (Ubuntu)",
+}
+
+// generatedBanners caches the banners written by the model, the strategies build a
+// new LLMHoneypot for every connection so the cache is shared by all of them
+var generatedBanners sync.Map
+
+// Greeting returns what the service shows on connect, before any command: Banner
+// when configured, otherwise a banner generated once by the model when GenerateBanner
+// is set and DryRun is not, otherwise a stock banner for the protocol
+func (llm *LLMHoneypot) Greeting() string {
+	if llm.Banner != "" {
+		return llm.Banner
+	}
+	if !llm.GenerateBanner || llm.DryRun {
+		return defaultBanners[llm.Protocol]
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", llm.Protocol.String(), llm.Provider.String(), llm.Model)
+	if banner, ok := generatedBanners.Load(key); ok {
+		return banner.(string)
+	}
+
+	// the banner is not an answer, LastRawResponse keeps the one of the last command
+	generator := *llm
+	generator.lastRaw = nil
+	banner, _, err := generator.callProvider(context.Background(), []Message{
+		{Role: SYSTEM.String(), Content: fmt.Sprintf(bannerPrompt, llm.Protocol.String())},
+		{Role: USER.String(), Content: "banner"},
+	})
+	banner, _, _ = strings.Cut(strings.TrimSpace(banner), "\n")
+	if err != nil || banner == "" {
+		if err != nil {
+			log.Warnf("error generating banner: %s", err.Error())
+		}
+		return defaultBanners[llm.Protocol]
+	}
+
+	actual, _ := generatedBanners.LoadOrStore(key, strings.TrimSpace(banner))
+	return actual.(string)
+}
@@ -0,0 +1,106 @@
+package plugins
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGreetingConfiguredAndDefault(t *testing.T) {
+	configured := LLMHoneypot{Protocol: tracer.SSH, Banner: "SSH-2.0-dropbear_2022.83"}
+	ssh := LLMHoneypot{Protocol: tracer.SSH}
+	http := LLMHoneypot{Protocol: tracer.HTTP}
+	dns := LLMHoneypot{Protocol: tracer.DNS}
+
+	assert.Equal(t, "SSH-2.0-dropbear_2022.83", configured.Greeting())
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6", ssh.Greeting())
+	assert.Equal(t, "Server: nginx/1.18.0 (Ubuntu)", http.Greeting())
+	assert.Equal(t, "", dns.Greeting())
+}
+
+func TestGreetingGeneratedOnce(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"SSH-2.0-OpenSSH_7.4\n"}}`), nil
+		},
+	)
+
+	newHoneypot := func() *LLMHoneypot {
+		honeypot := InitLLMHoneypot(LLMHoneypot{
+			Protocol:       tracer.SSH,
+			Model:          "banner-test",
+			Provider:       Ollama,
+			GenerateBanner: true,
+		})
+		honeypot.client = client
+		return honeypot
+	}
+
+	//When
+	first := newHoneypot().Greeting()
+	second := newHoneypot().Greeting()
+
+	//Then
+	assert.Equal(t, "SSH-2.0-OpenSSH_7.4", first)
+	assert.Equal(t, "SSH-2.0-OpenSSH_7.4", second)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestGreetingGenerationFailsFallsBack(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":""}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.HTTP,
+		Model:          "banner-fail-test",
+		Provider:       Ollama,
+		GenerateBanner: true,
+	})
+	honeypot.client = client
+
+	//When
+	banner := honeypot.Greeting()
+
+	//Then
+	assert.Equal(t, "Server: nginx/1.18.0 (Ubuntu)", banner)
+}
+
+func TestGreetingDryRunDoesNotGenerate(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "banner-dry-run-test",
+		Provider:       Ollama,
+		GenerateBanner: true,
+		DryRun:         true,
+	})
+	honeypot.client = client
+
+	//When
+	banner := honeypot.Greeting()
+
+	//Then
+	assert.Equal(t, "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6", banner)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,90 @@
+package plugins
+
+// defaultBashHistory is the routine of an admin who has been on the box for a while
+var defaultBashHistory = []string{
+	"sudo apt update",
+	"sudo apt upgrade -y",
+	"df -h",
+	"free -m",
+	"systemctl status nginx",
+	"sudo systemctl restart nginx",
+	"tail -n 100 /var/log/nginx/error.log",
+	"cd /var/www/html",
+	"git pull",
+	"ls -la",
+	"cat .env",
+	"docker ps",
+	"docker compose logs --tail=50 app",
+	"crontab -l",
+	"htop",
+	"cd ~",
+	"vim backup.sh",
+	"chmod +x backup.sh",
+	"./backup.sh",
+	"ls -lh /backup",
+}
+
+// bashHistoryPromptEntries bounds the history sent to the model, like the
+// default output of `history` it keeps the most recent entries
+const bashHistoryPromptEntries = 100
+
+// PreloadBashHistory fills the shell history of the session, so that `history`,
+// ~/.bash_history and arrow-key recall show prior activity. Nil or empty commands
+// load a generic admin routine. It enables State when it is nil
+func (llm *LLMHoneypot) PreloadBashHistory(commands []string) {
+	if llm.State == nil {
+		llm.State = NewSessionState()
+	}
+	llm.State.PreloadBashHistory(commands)
+}
+
+// PreloadBashHistory puts commands before the history recorded so far
+func (s *SessionState) PreloadBashHistory(commands []string) {
+	if len(commands) == 0 {
+		commands = defaultBashHistory
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.BashHistory = append(append([]string(nil), commands...), s.BashHistory...)
+}
+
+// addBashHistory skips a repeat of the previous entry, like HISTCONTROL=ignoredups
+func (s *SessionState) addBashHistory(command string) {
+	if command == "" {
+		return
+	}
+	if n := len(s.BashHistory); n > 0 && s.BashHistory[n-1] == command {
+		return
+	}
+	s.BashHistory = append(s.BashHistory, command)
+}
+
+// TerminalHistory adapts the shell history to the History of golang.org/x/term,
+// assign it to Terminal.History so that the arrow keys recall the same commands
+func (s *SessionState) TerminalHistory() *TerminalHistory {
+	return &TerminalHistory{state: s}
+}
+
+// TerminalHistory is the State shell history seen by a terminal line editor
+type TerminalHistory struct {
+	state *SessionState
+}
+
+func (h *TerminalHistory) Add(entry string) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	h.state.addBashHistory(entry)
+}
+
+func (h *TerminalHistory) Len() int {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	return len(h.state.BashHistory)
+}
+
+// At returns the idx-th most recent entry
+func (h *TerminalHistory) At(idx int) string {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	return h.state.BashHistory[len(h.state.BashHistory)-1-idx]
+}
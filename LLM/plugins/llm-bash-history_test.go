@@ -0,0 +1,64 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/term"
+)
+
+var _ term.History = (*TerminalHistory)(nil)
+
+func TestPreloadBashHistory(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{}
+
+	//When
+	honeypot.PreloadBashHistory([]string{"cd /srv/app", "git log -3"})
+	honeypot.State.Update("whoami", "root")
+	honeypot.State.Update("whoami", "root")
+
+	//Then
+	assert.Equal(t, []string{"cd /srv/app", "git log -3", "whoami"}, honeypot.State.BashHistory)
+	assert.Contains(t, honeypot.State.PromptContext(), "    1  cd /srv/app\n    2  git log -3\n    3  whoami")
+}
+
+func TestPreloadBashHistoryDefault(t *testing.T) {
+	//Given
+	state := NewSessionState()
+
+	//When
+	state.PreloadBashHistory(nil)
+
+	//Then
+	assert.Equal(t, defaultBashHistory, state.BashHistory)
+}
+
+func TestBashHistoryCleared(t *testing.T) {
+	//Given
+	state := NewSessionState()
+	state.PreloadBashHistory([]string{"ls"})
+
+	//When
+	state.Update("history -c", "")
+
+	//Then
+	assert.Empty(t, state.BashHistory)
+	assert.Equal(t, "", state.PromptContext())
+}
+
+func TestTerminalHistory(t *testing.T) {
+	//Given
+	state := NewSessionState()
+	state.PreloadBashHistory([]string{"uptime", "df -h"})
+	history := state.TerminalHistory()
+
+	//When
+	history.Add("ls")
+	state.Update("ls", "")
+
+	//Then
+	assert.Equal(t, 3, history.Len())
+	assert.Equal(t, "ls", history.At(0))
+	assert.Equal(t, "uptime", history.At(2))
+}
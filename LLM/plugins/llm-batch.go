@@ -0,0 +1,217 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+)
+
+const batchInstruction = `
+You will receive %d independent HTTP requests from different clients, each one introduced by a line "### REQUEST <n> ###".
+Answer every request on its own, exactly as if it were the only one, and introduce each answer with a line "### RESPONSE <n> ###" using the same number. Do not add anything else.`
+
+var batchResponseMarker = regexp.MustCompile(`(?m)^### RESPONSE (\d+) ###[ \t]*\r?\n?`)
+
+type batchResult struct {
+	output string
+	usage  Usage
+	err    error
+}
+
+type batchItem struct {
+	ctx     context.Context
+	request string
+	result  chan batchResult
+}
+
+// httpBatcher collects the requests sharing a provider, model and prompt prefix
+type httpBatcher struct {
+	mu      sync.Mutex
+	pending []*batchItem
+	timer   *time.Timer
+}
+
+// httpBatchers is shared by every honeypot instance, the HTTP strategy builds one per request
+var httpBatchers sync.Map
+
+// batches reports whether the call goes through the micro-batching of stateless HTTP
+func (llm *LLMHoneypot) batches(t *turn) bool {
+	return llm.BatchWindow > 0 && llm.Stateless && llm.Protocol == tracer.HTTP &&
+		t.onChunk == nil && t.tools == nil && len(t.images) == 0
+}
+
+// callProviderBatched waits up to BatchWindow, or until BatchSize requests with the
+// same prompt prefix are queued, and sends them all in one provider call
+func (llm *LLMHoneypot) callProviderBatched(ctx context.Context, prompt []Message) (string, Usage, error) {
+	prefix, request := prompt[:len(prompt)-1], prompt[len(prompt)-1].Content
+	key, err := llm.batchKey(prefix)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	value, _ := httpBatchers.LoadOrStore(key, &httpBatcher{})
+	batcher := value.(*httpBatcher)
+
+	item := &batchItem{ctx: ctx, request: request, result: make(chan batchResult, 1)}
+	batcher.mu.Lock()
+	batcher.pending = append(batcher.pending, item)
+	switch {
+	case llm.BatchSize > 0 && len(batcher.pending) >= llm.BatchSize:
+		items := batcher.take(key)
+		go llm.sendBatch(prefix, items)
+	case len(batcher.pending) == 1:
+		batcher.timer = time.AfterFunc(llm.BatchWindow, func() {
+			batcher.mu.Lock()
+			items := batcher.take(key)
+			batcher.mu.Unlock()
+			llm.sendBatch(prefix, items)
+		})
+	}
+	batcher.mu.Unlock()
+
+	select {
+	case result := <-item.result:
+		return result.output, result.usage, result.err
+	case <-ctx.Done():
+		return "", Usage{}, ctx.Err()
+	}
+}
+
+// take empties the queue and forgets the batcher, so that prompts carrying per-client
+// context do not pile up. A request that already loaded it still gets served: its
+// append finds an empty queue and starts a new window. The caller holds mu
+func (b *httpBatcher) take(key string) []*batchItem {
+	httpBatchers.CompareAndDelete(key, b)
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	items := b.pending
+	b.pending = nil
+	return items
+}
+
+// batchKey hashes the prompt prefix with everything else that shapes the provider
+// call, so that requests with other credentials, headers or sampling never share a batch
+func (llm *LLMHoneypot) batchKey(prefix []Message) (string, error) {
+	raw, err := json.Marshal(struct {
+		Prefix               []Message
+		Host                 string
+		CompatibleBaseURL    string
+		Keys                 []string
+		CompatibleAuthHeader string
+		ExtraHeaders         map[string]string
+		VertexAI             bool
+		GCPProject           string
+		GCPRegion            string
+		Temperature          float32
+		TopP                 float32
+		TopK                 int
+		MaxTokens            int
+		StopSequences        []string
+		Seed                 *int
+		LogitBias            map[string]float64
+		KeepAlive            string
+		OllamaOptions        map[string]interface{}
+		JSONMode             bool
+		ThinkingBudget       *int
+		GeminiSafetySettings []GeminiSafetySetting
+	}{
+		prefix, llm.Host, llm.CompatibleBaseURL,
+		[]string{llm.OpenAIKey, llm.GoogleAPIKey, llm.CohereKey, llm.CompatibleKey}, llm.CompatibleAuthHeader, llm.ExtraHeaders,
+		llm.VertexAI, llm.GCPProject, llm.GCPRegion,
+		llm.Temperature, llm.TopP, llm.TopK, llm.MaxTokens, llm.StopSequences, llm.Seed, llm.LogitBias,
+		llm.KeepAlive, llm.OllamaOptions, llm.JSONMode, llm.ThinkingBudget, llm.GeminiSafetySettings,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return fmt.Sprintf("%s|%s|%s", llm.Provider.String(), llm.Model, hex.EncodeToString(sum[:])), nil
+}
+
+// sendBatch makes the provider call of items and hands every client its answer.
+// The call outlives the client that opened the batch, one disconnect must not fail the others.
+// The batch is one call, it takes a single rate limit token and MaxConcurrent slot
+func (llm *LLMHoneypot) sendBatch(prefix []Message, items []*batchItem) {
+	if len(items) == 0 {
+		return
+	}
+	ctx := context.WithoutCancel(items[0].ctx)
+	fail := func(err error) {
+		for _, item := range items {
+			item.result <- batchResult{err: err}
+		}
+	}
+	if err := llm.waitRateLimit(ctx); err != nil {
+		fail(err)
+		return
+	}
+	release, err := llm.acquireSlot(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer release()
+
+	if len(items) == 1 {
+		output, usage, err := llm.callProvider(ctx, append(append([]Message(nil), prefix...), Message{Role: USER.String(), Content: items[0].request}))
+		items[0].result <- batchResult{output: output, usage: usage, err: err}
+		return
+	}
+
+	var batch strings.Builder
+	for i, item := range items {
+		fmt.Fprintf(&batch, "### REQUEST %d ###\n%s\n", i+1, item.request)
+	}
+	msgs := append(append([]Message(nil), prefix...),
+		Message{Role: SYSTEM.String(), Content: fmt.Sprintf(batchInstruction, len(items))},
+		Message{Role: USER.String(), Content: strings.TrimRight(batch.String(), "\n")},
+	)
+	output, usage, err := llm.callProvider(ctx, msgs)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	responses := splitBatchResponses(output)
+	share := Usage{
+		PromptTokens:     usage.PromptTokens / len(items),
+		CompletionTokens: usage.CompletionTokens / len(items),
+		TotalTokens:      usage.TotalTokens / len(items),
+	}
+	for i, item := range items {
+		response, ok := responses[i+1]
+		if !ok {
+			item.result <- batchResult{usage: share, err: fmt.Errorf("batched answer has no response %d of %d", i+1, len(items))}
+			continue
+		}
+		item.result <- batchResult{output: response, usage: share}
+	}
+}
+
+// splitBatchResponses maps the number of every "### RESPONSE n ###" section to its text
+func splitBatchResponses(output string) map[int]string {
+	responses := make(map[int]string)
+	markers := batchResponseMarker.FindAllStringSubmatchIndex(output, -1)
+	for i, marker := range markers {
+		n, err := strconv.Atoi(output[marker[2]:marker[3]])
+		if err != nil {
+			continue
+		}
+		end := len(output)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+		responses[n] = strings.TrimRight(output[marker[1]:end], "\n")
+	}
+	return responses
+}
@@ -0,0 +1,200 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchEchoResponder answers every request of a batch with a 404 echoing it
+func batchEchoResponder(mu *sync.Mutex, batches *[]string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		var body Request
+		json.NewDecoder(req.Body).Decode(&body)
+		request := body.Messages[len(body.Messages)-1].Content
+		mu.Lock()
+		*batches = append(*batches, request)
+		mu.Unlock()
+
+		var answer strings.Builder
+		for _, line := range strings.Split(request, "\n") {
+			if strings.HasPrefix(line, "### REQUEST ") {
+				answer.WriteString(strings.Replace(line, "REQUEST", "RESPONSE", 1) + "\n")
+				continue
+			}
+			answer.WriteString("HTTP/1.1 404 Not Found\r\n\r\n" + line + "\n")
+		}
+		content, _ := json.Marshal(answer.String())
+		return newJSONStringResponse(`{"message":{"role":"assistant","content":` + string(content) + `}}`), nil
+	}
+}
+
+func TestExecuteModelBatchesStatelessHTTP(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var mu sync.Mutex
+	var batches []string
+	httpmock.RegisterResponder("POST", ollamaEndpoint, batchEchoResponder(&mu, &batches))
+
+	commands := []string{"GET /.env", "GET /.git/config", "GET /wp-login.php"}
+	outputs := make([]string, len(commands))
+	var wg sync.WaitGroup
+
+	//When
+	for i, command := range commands {
+		wg.Add(1)
+		go func(i int, command string) {
+			defer wg.Done()
+			honeypot := InitLLMHoneypot(LLMHoneypot{
+				Protocol:    tracer.HTTP,
+				Model:       "llama3",
+				Provider:    Ollama,
+				Stateless:   true,
+				BatchWindow: time.Second,
+				BatchSize:   len(commands),
+			})
+			honeypot.client = client
+			outputs[i], _ = honeypot.ExecuteModel(command)
+		}(i, command)
+	}
+	wg.Wait()
+
+	//Then
+	assert.Len(t, batches, 1)
+	for i, command := range commands {
+		assert.Contains(t, batches[0], command)
+		assert.Contains(t, outputs[i], command)
+		assert.True(t, strings.HasPrefix(outputs[i], "HTTP/1.1 404 Not Found"))
+	}
+}
+
+func TestExecuteModelBatchTakesOneSlot(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var mu sync.Mutex
+	var batches []string
+	httpmock.RegisterResponder("POST", ollamaEndpoint, batchEchoResponder(&mu, &batches))
+
+	commands := []string{"GET /.env", "GET /admin"}
+	errs := make([]error, len(commands))
+	var wg sync.WaitGroup
+
+	//When
+	for i, command := range commands {
+		wg.Add(1)
+		go func(i int, command string) {
+			defer wg.Done()
+			honeypot := InitLLMHoneypot(LLMHoneypot{
+				Protocol:      tracer.HTTP,
+				Model:         "llama3",
+				Provider:      Ollama,
+				Stateless:     true,
+				BatchWindow:   time.Second,
+				BatchSize:     len(commands),
+				MaxConcurrent: 1,
+			})
+			honeypot.client = client
+			_, errs[i] = honeypot.ExecuteModel(command)
+		}(i, command)
+	}
+	wg.Wait()
+
+	//Then
+	assert.Len(t, batches, 1)
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, 0, InFlightRequests())
+}
+
+func TestBatchKeySeparatesSettings(t *testing.T) {
+	//Given
+	prefix := []Message{{Role: SYSTEM.String(), Content: "prompt"}}
+	base := LLMHoneypot{Provider: Ollama, Model: "llama3"}
+	otherKey := base
+	otherKey.CompatibleKey = "other"
+	otherHeaders := base
+	otherHeaders.ExtraHeaders = map[string]string{"X-Tenant": "b"}
+	otherTemperature := base
+	otherTemperature.Temperature = 0.9
+
+	//When
+	key, _ := base.batchKey(prefix)
+	same, _ := base.batchKey(prefix)
+	keys := []string{key}
+	for _, honeypot := range []LLMHoneypot{otherKey, otherHeaders, otherTemperature} {
+		other, _ := honeypot.batchKey(prefix)
+		keys = append(keys, other)
+	}
+
+	//Then
+	assert.Equal(t, key, same)
+	for i := 1; i < len(keys); i++ {
+		assert.NotEqual(t, key, keys[i])
+	}
+}
+
+func TestExecuteModelBatchWindowSingleRequest(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var request string
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			request = body.Messages[len(body.Messages)-1].Content
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"HTTP/1.1 200 OK\r\n\r\nhello"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.HTTP,
+		Model:       "llama3",
+		Provider:    Ollama,
+		Stateless:   true,
+		BatchWindow: 10 * time.Millisecond,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("GET /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Contains(t, str, "hello")
+	assert.Equal(t, "GET /", request)
+}
+
+func TestSplitBatchResponses(t *testing.T) {
+	//Given
+	output := "### RESPONSE 2 ###\nsecond\n\n### RESPONSE 1 ###\r\nfirst\nline\n"
+
+	//When
+	responses := splitBatchResponses(output)
+
+	//Then
+	keys := make([]int, 0, len(responses))
+	for k := range responses {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	assert.Equal(t, []int{1, 2}, keys)
+	assert.Equal(t, "first\nline", responses[1])
+	assert.Equal(t, "second", responses[2])
+}
@@ -0,0 +1,81 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	log "github.com/sirupsen/logrus"
+)
+
+type canary struct {
+	command string
+	pattern string
+}
+
+// defaultCanaries are commands whose in-character answer has a predictable shape
+var defaultCanaries = map[tracer.Protocol]canary{
+	tracer.SSH:  {command: "whoami", pattern: `^[a-z_][a-z0-9_-]{0,31}$`},
+	tracer.HTTP: {command: "GET / HTTP/1.1", pattern: `(?i)^\s*(HTTP/\d(\.\d)? \d{3}|<|[{\[])`},
+}
+
+// VerifyPersona sends CanaryCommand and checks the answer against CanaryPattern
+// (whoami and a username for SSH by default). The probe is stateless: it neither
+// reads nor writes history, state and scenario, and it is not emitted to the Sink, the Tracer or Events
+func (llm *LLMHoneypot) VerifyPersona(ctx context.Context) error {
+	probe := defaultCanaries[llm.Protocol]
+	if llm.CanaryCommand != "" {
+		probe = canary{command: llm.CanaryCommand, pattern: llm.CanaryPattern}
+	}
+	if probe.command == "" || probe.pattern == "" {
+		return fmt.Errorf("no canary configured for protocol %s", llm.Protocol.String())
+	}
+	pattern, err := regexp.Compile(probe.pattern)
+	if err != nil {
+		return fmt.Errorf("invalid canary pattern: %v", err)
+	}
+
+	isolated := *llm
+	isolated.Stateless = true
+	isolated.State = nil
+	isolated.Scenario = nil
+	isolated.Sink = nil
+	isolated.Tracer = nil
+	isolated.events = nil
+	isolated.Latency = nil
+	isolated.FallbackResponse = ""
+	isolated.recorder, isolated.replay = nil, nil
+	isolated.lastRaw = nil
+	output, err := isolated.ExecuteModelContext(ctx, probe.command)
+	if err != nil {
+		return fmt.Errorf("canary %q failed: %v", probe.command, err)
+	}
+	if !pattern.MatchString(output) {
+		return fmt.Errorf("persona drift: %q answered %q, expected %s", probe.command, output, probe.pattern)
+	}
+	return nil
+}
+
+// StartPersonaCanary runs VerifyPersona every interval until ctx is done and logs drifts
+func (llm *LLMHoneypot) StartPersonaCanary(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := llm.VerifyPersona(ctx); err != nil {
+					log.WithFields(log.Fields{
+						"protocol": llm.Protocol.String(),
+						"provider": llm.Provider.String(),
+						"model":    llm.Model,
+					}).Warn(err.Error())
+				}
+			}
+		}
+	}()
+}
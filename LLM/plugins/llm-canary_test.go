@@ -0,0 +1,128 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func canaryHoneypot(t *testing.T, answer string) *LLMHoneypot {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, &Response{Message: Message{Role: ASSISTANT.String(), Content: answer}})
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	honeypot.client = client
+	return honeypot
+}
+
+func TestVerifyPersonaInCharacter(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "root")
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.Empty(t, honeypot.Histories)
+}
+
+func TestVerifyPersonaDrift(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "You are currently logged in as the root user.")
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+
+	//Then
+	assert.Equal(t, `persona drift: "whoami" answered "You are currently logged in as the root user.", expected ^[a-z_][a-z0-9_-]{0,31}$`, err.Error())
+}
+
+func TestVerifyPersonaCustomCanary(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "Linux web-01 5.15.0-91-generic")
+	honeypot.CanaryCommand = "uname -a"
+	honeypot.CanaryPattern = `^Linux \S+ \d`
+	dns := LLMHoneypot{Protocol: tracer.DNS}
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+	dnsErr := dns.VerifyPersona(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "no canary configured for protocol DNS", dnsErr.Error())
+}
+
+func TestVerifyPersonaIsNotRecorded(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "root")
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	assert.Nil(t, honeypot.RecordTo(path))
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	recorded, _ := os.ReadFile(path)
+	assert.Empty(t, recorded)
+}
+
+func TestVerifyPersonaIsNotTraced(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "root")
+	tr := &mockTracer{}
+	honeypot.Tracer = tr
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.Empty(t, tr.events)
+}
+
+func TestVerifyPersonaIsNotPublished(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "root")
+	events := honeypot.Events()
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+}
+
+func TestVerifyPersonaLeavesScenario(t *testing.T) {
+	//Given
+	honeypot := canaryHoneypot(t, "root")
+	honeypot.Scenario = NewScenario()
+
+	//When
+	err := honeypot.VerifyPersona(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.Empty(t, honeypot.Scenario.Activity)
+}
@@ -0,0 +1,148 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while the breaker of the provider is open and no BreakerFallback is set
+var ErrCircuitOpen = errors.New("llm provider circuit open")
+
+const (
+	defaultBreakerWindow   = time.Minute
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+var breakerNow = time.Now
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker opens after consecutive failures within a window, rejects calls for
+// the cooldown, then lets a single probe through: success closes it, failure reopens it
+type circuitBreaker struct {
+	mu           sync.Mutex
+	state        breakerState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+}
+
+// providerBreakers is shared like providerLimiters, honeypots are built per request
+var (
+	providerBreakersMu sync.Mutex
+	providerBreakers   = make(map[LLMProvider]*circuitBreaker)
+)
+
+func breakerFor(provider LLMProvider) *circuitBreaker {
+	providerBreakersMu.Lock()
+	defer providerBreakersMu.Unlock()
+
+	breaker, ok := providerBreakers[provider]
+	if !ok {
+		breaker = &circuitBreaker{}
+		providerBreakers[provider] = breaker
+	}
+	return breaker
+}
+
+func (b *circuitBreaker) allow(cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if breakerNow().Sub(b.openedAt) < cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(failed bool, threshold int, window time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := breakerNow()
+	b.probing = false
+	if !failed {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = now
+		return
+	}
+	if b.failures == 0 || now.Sub(b.firstFailure) > window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.failures >= threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+		b.failures = 0
+	}
+}
+
+// release ends a probe that says nothing about the provider, the state and the
+// failure count are kept and the next call probes again
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// guard runs call through the breaker of the provider when BreakerFailures is set.
+// While open it returns BreakerFallback, or ErrCircuitOpen when that is empty
+func (llm *LLMHoneypot) guard(ctx context.Context, call func() error) (fallback string, open bool, err error) {
+	if llm.BreakerFailures <= 0 {
+		return "", false, call()
+	}
+
+	window, cooldown := llm.BreakerWindow, llm.BreakerCooldown
+	if window <= 0 {
+		window = defaultBreakerWindow
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	breaker := breakerFor(llm.Provider)
+	if !breaker.allow(cooldown) {
+		if llm.BreakerFallback != "" {
+			return llm.BreakerFallback, true, nil
+		}
+		return "", true, ErrCircuitOpen
+	}
+
+	err = call()
+	// an attacker disconnecting or a request stopped by PreSendValidator is neither
+	// a provider failure nor a success
+	if err != nil && (ctx.Err() != nil || isRejectedRequest(err)) {
+		breaker.release()
+		return "", false, err
+	}
+	breaker.record(err != nil, llm.BreakerFailures, window)
+	return "", false, err
+}
@@ -0,0 +1,143 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	breakerNow = func() time.Time { return now }
+	defer func() { breakerNow = time.Now }()
+	delete(providerBreakers, Gemini)
+
+	// Given
+	healthy := false
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			if !healthy {
+				return httpmock.NewStringResponse(503, "overloaded"), nil
+			}
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:        tracer.SSH,
+		Model:           "gemini-pro",
+		Provider:        Gemini,
+		GoogleAPIKey:    "sdjdnklfjndslkjanfk",
+		BreakerFailures: 2,
+		BreakerCooldown: 10 * time.Second,
+	})
+	honeypot.client = client
+
+	//When
+	_, first := honeypot.ExecuteModel("ls")
+	_, second := honeypot.ExecuteModel("ls")
+	_, rejected := honeypot.ExecuteModel("ls")
+	callsWhileOpen := httpmock.GetTotalCallCount()
+
+	now = now.Add(11 * time.Second)
+	_, probe := honeypot.ExecuteModel("ls")
+	_, reopened := honeypot.ExecuteModel("ls")
+
+	now = now.Add(11 * time.Second)
+	healthy = true
+	recovered, recoveredErr := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Error(t, first)
+	assert.Error(t, second)
+	assert.ErrorIs(t, rejected, ErrCircuitOpen)
+	assert.Equal(t, 2, callsWhileOpen)
+	assert.Error(t, probe)
+	assert.ErrorIs(t, reopened, ErrCircuitOpen)
+	assert.Nil(t, recoveredErr)
+	assert.Equal(t, "prova.txt", recovered)
+	assert.Equal(t, 4, httpmock.GetTotalCallCount())
+}
+
+func TestCircuitBreakerFallbackAndWindow(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	breakerNow = func() time.Time { return now }
+	defer func() { breakerNow = time.Now }()
+	delete(providerBreakers, Compatible)
+
+	// Given
+	httpmock.RegisterResponder("POST", "http://gateway.local/v1/chat/completions",
+		httpmock.NewStringResponder(500, "boom"))
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:          tracer.SSH,
+		Model:             "llama3",
+		Provider:          Compatible,
+		CompatibleBaseURL: "http://gateway.local/v1",
+		BreakerFailures:   2,
+		BreakerWindow:     time.Minute,
+		BreakerFallback:   "Segmentation fault",
+	})
+	honeypot.client = client
+
+	//When
+	honeypot.ExecuteModel("ls")
+	now = now.Add(2 * time.Minute)
+	_, outsideWindow := honeypot.ExecuteModel("ls")
+	honeypot.ExecuteModel("ls")
+	fallback, fallbackErr := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.NotErrorIs(t, outsideWindow, ErrCircuitOpen)
+	assert.Nil(t, fallbackErr)
+	assert.Equal(t, "Segmentation fault", fallback)
+	assert.Equal(t, 3, httpmock.GetTotalCallCount())
+}
+
+func TestCircuitBreakerIgnoresCancelledCalls(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	breakerNow = func() time.Time { return now }
+	defer func() { breakerNow = time.Now }()
+	delete(providerBreakers, Cohere)
+
+	//Given
+	honeypot := LLMHoneypot{Provider: Cohere, BreakerFailures: 2, BreakerCooldown: 10 * time.Second}
+	failing := func() error { return errors.New("overloaded") }
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//When
+	_, _, first := honeypot.guard(context.Background(), failing)
+	_, _, _ = honeypot.guard(cancelled, func() error { return cancelled.Err() })
+	_, _, _ = honeypot.guard(context.Background(), func() error { return rejectedRequest{errors.New("too long")} })
+	_, _, second := honeypot.guard(context.Background(), failing)
+	_, open, _ := honeypot.guard(context.Background(), failing)
+
+	now = now.Add(11 * time.Second)
+	_, _, _ = honeypot.guard(cancelled, func() error { return cancelled.Err() })
+	_, stillOpen, _ := honeypot.guard(context.Background(), failing)
+	_, reopened, _ := honeypot.guard(context.Background(), failing)
+
+	//Then
+	assert.Error(t, first)
+	assert.Error(t, second)
+	assert.True(t, open)
+	assert.False(t, stillOpen, "the probe after a cancelled probe is let through")
+	assert.True(t, reopened)
+}
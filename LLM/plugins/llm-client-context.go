@@ -0,0 +1,36 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClientContext describes the attacker connection, so invented data can match
+// their locale and `who` or `last` can show the real source address
+type ClientContext struct {
+	SourceIP   string
+	ReverseDNS string
+	// Country is the ISO 3166-1 alpha-2 code of the geolocated source IP
+	Country string
+}
+
+// PromptContext is the system message describing the client, empty when nothing is known.
+// Like every system message it is replaced by a placeholder in debug payload logs
+func (c ClientContext) PromptContext() string {
+	var facts []string
+	if c.SourceIP != "" {
+		facts = append(facts, "source IP: "+c.SourceIP)
+	}
+	if c.ReverseDNS != "" {
+		facts = append(facts, "reverse DNS: "+c.ReverseDNS)
+	}
+	if c.Country != "" {
+		facts = append(facts, "country: "+strings.ToUpper(c.Country))
+	}
+	if len(facts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("The current client connection is known, use it where the real system would "+
+		"(login records, connection logs) and prefer names, languages and formats of its country for invented data. "+
+		"Never comment on it.\n%s", strings.Join(facts, "\n"))
+}
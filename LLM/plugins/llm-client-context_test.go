@@ -0,0 +1,43 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientContextPromptContext(t *testing.T) {
+	//Given
+	full := ClientContext{SourceIP: "203.0.113.7", ReverseDNS: "host-7.example.net", Country: "de"}
+	partial := ClientContext{Country: "BR"}
+
+	//When
+	fullContext := full.PromptContext()
+	partialContext := partial.PromptContext()
+
+	//Then
+	assert.Contains(t, fullContext, "source IP: 203.0.113.7\nreverse DNS: host-7.example.net\ncountry: DE")
+	assert.NotContains(t, partialContext, "source IP")
+	assert.Contains(t, partialContext, "country: BR")
+	assert.Equal(t, "", ClientContext{}.PromptContext())
+}
+
+func TestBuildPromptWithClientContext(t *testing.T) {
+	//Given
+	anonymous := LLMHoneypot{Protocol: tracer.SSH}
+	localized := LLMHoneypot{Protocol: tracer.SSH, Client: &ClientContext{SourceIP: "203.0.113.7", Country: "JP"}}
+
+	//When
+	anonymousPrompt, err := anonymous.buildPrompt("last")
+	assert.Nil(t, err)
+	localizedPrompt, err := localized.buildPrompt("last")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(anonymousPrompt))
+	assert.Equal(t, SystemPromptLen+1, len(localizedPrompt))
+	assert.Equal(t, SYSTEM.String(), localizedPrompt[3].Role)
+	assert.Contains(t, localizedPrompt[3].Content, "country: JP")
+	assert.Nil(t, localized.NewSession().Client)
+}
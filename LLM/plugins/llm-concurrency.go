@@ -0,0 +1,80 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrTooManyRequests is returned when MaxConcurrent calls are in flight and
+// MaxConcurrentWait is off, FallbackResponse turns it into a plausible answer
+var ErrTooManyRequests = errors.New("too many concurrent llm requests")
+
+// inFlightLimiter counts the provider calls of the whole process: a scan storm
+// spreads over many honeypot instances, each built for a single request
+type inFlightLimiter struct {
+	mu       sync.Mutex
+	inFlight int
+	// released is closed and replaced on every release to wake the waiters
+	released chan struct{}
+}
+
+var inFlight = &inFlightLimiter{released: make(chan struct{})}
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace: "beelzebub",
+	Name:      "llm_in_flight_requests",
+	Help:      "The number of LLM provider calls in flight",
+}, func() float64 { return float64(InFlightRequests()) })
+
+// InFlightRequests is the number of provider calls currently in flight
+func InFlightRequests() int {
+	inFlight.mu.Lock()
+	defer inFlight.mu.Unlock()
+	return inFlight.inFlight
+}
+
+func (l *inFlightLimiter) acquire(ctx context.Context, max int, wait bool) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < max {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		if !wait {
+			return ErrTooManyRequests
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return errors.Join(ErrTooManyRequests, ctx.Err())
+		}
+	}
+}
+
+func (l *inFlightLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// acquireSlot takes one of MaxConcurrent slots, waiting for it until ctx is done when
+// MaxConcurrentWait is set. The returned release must be called once the call ends
+func (llm *LLMHoneypot) acquireSlot(ctx context.Context) (release func(), err error) {
+	if llm.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	if err := inFlight.acquire(ctx, llm.MaxConcurrent, llm.MaxConcurrentWait); err != nil {
+		return nil, err
+	}
+	return inFlight.release, nil
+}
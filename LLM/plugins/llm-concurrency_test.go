@@ -0,0 +1,119 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteModelRejectsOverMaxConcurrent(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			entered <- struct{}{}
+			<-unblock
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Model:         "gpt-4o",
+		Provider:      OpenAI,
+		OpenAIKey:     "sdjdnklfjndslkjanfk",
+		MaxConcurrent: 1,
+	})
+	honeypot.client = client
+
+	done := make(chan error)
+	go func() {
+		_, err := honeypot.ExecuteModel("ls")
+		done <- err
+	}()
+	<-entered
+
+	//When
+	inFlightDuringCall := InFlightRequests()
+	_, err := honeypot.ExecuteModel("ls")
+	close(unblock)
+
+	//Then
+	assert.Equal(t, 1, inFlightDuringCall)
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+	assert.Nil(t, <-done)
+	assert.Equal(t, 0, InFlightRequests())
+}
+
+func TestExecuteModelOverMaxConcurrentUsesFallback(t *testing.T) {
+	//Given
+	assert.Nil(t, inFlight.acquire(context.Background(), 1, false))
+	defer inFlight.release()
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.SSH,
+		Model:            "gpt-4o",
+		Provider:         OpenAI,
+		OpenAIKey:        "sdjdnklfjndslkjanfk",
+		MaxConcurrent:    1,
+		FallbackResponse: "bash: fork: retry: Resource temporarily unavailable",
+	})
+
+	//When
+	output, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "bash: fork: retry: Resource temporarily unavailable", output)
+}
+
+func TestInFlightLimiterWaitsForSlot(t *testing.T) {
+	//Given
+	limiter := &inFlightLimiter{released: make(chan struct{})}
+	assert.Nil(t, limiter.acquire(context.Background(), 1, true))
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- limiter.acquire(context.Background(), 1, true)
+	}()
+
+	//When
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot while the limit was reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+	limiter.release()
+
+	//Then
+	assert.Nil(t, <-acquired)
+	assert.Equal(t, 1, limiter.inFlight)
+}
+
+func TestInFlightLimiterWaitEndsWithContext(t *testing.T) {
+	//Given
+	limiter := &inFlightLimiter{released: make(chan struct{})}
+	assert.Nil(t, limiter.acquire(context.Background(), 1, true))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	//When
+	err := limiter.acquire(ctx, 1, true)
+
+	//Then
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, limiter.inFlight)
+}
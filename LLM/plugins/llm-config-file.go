@@ -0,0 +1,207 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the on-disk shape of a honeypot persona, see LoadConfigFile
+type configFile struct {
+	Protocol             string   `yaml:"protocol" json:"protocol"`
+	Provider             string   `yaml:"provider" json:"provider"`
+	Model                string   `yaml:"model" json:"model"`
+	Host                 string   `yaml:"host" json:"host"`
+	OpenAIKey            string   `yaml:"openAIKey" json:"openAIKey"`
+	GoogleAPIKey         string   `yaml:"googleAPIKey" json:"googleAPIKey"`
+	CohereKey            string   `yaml:"cohereKey" json:"cohereKey"`
+	VertexAI             bool     `yaml:"vertexAI" json:"vertexAI"`
+	GCPProject           string   `yaml:"gcpProject" json:"gcpProject"`
+	GCPRegion            string   `yaml:"gcpRegion" json:"gcpRegion"`
+	CompatibleBaseURL    string   `yaml:"compatibleBaseURL" json:"compatibleBaseURL"`
+	CompatibleKey        string   `yaml:"compatibleKey" json:"compatibleKey"`
+	CompatibleAuthHeader string   `yaml:"compatibleAuthHeader" json:"compatibleAuthHeader"`
+	ExecCommand          []string `yaml:"execCommand" json:"execCommand"`
+	// ExtraHeaders values may reference the environment, e.g. ${OPENAI_ORG_ID}
+	ExtraHeaders map[string]string `yaml:"extraHeaders" json:"extraHeaders"`
+	// Persona is a PersonaRegistry key like "ssh/alpine"
+	Persona string `yaml:"persona" json:"persona"`
+	// Environment is host, docker or kubernetes
+	Environment string `yaml:"environment" json:"environment"`
+	// CustomPrompt is taken verbatim, PromptFile is read relative to the config file
+	CustomPrompt  string   `yaml:"customPrompt" json:"customPrompt"`
+	PromptFile    string   `yaml:"promptFile" json:"promptFile"`
+	Temperature   *float32 `yaml:"temperature" json:"temperature"`
+	TopP          *float32 `yaml:"topP" json:"topP"`
+	TopK          int      `yaml:"topK" json:"topK"`
+	Seed          *int     `yaml:"seed" json:"seed"`
+	StopSequences []string `yaml:"stopSequences" json:"stopSequences"`
+	Timeout       string   `yaml:"timeout" json:"timeout"`
+	Stateless     bool     `yaml:"stateless" json:"stateless"`
+	// Connection pool of the provider transport, IdleConnTimeout is a duration like "90s"
+	MaxIdleConns        int    `yaml:"maxIdleConns" json:"maxIdleConns"`
+	MaxIdleConnsPerHost int    `yaml:"maxIdleConnsPerHost" json:"maxIdleConnsPerHost"`
+	IdleConnTimeout     string `yaml:"idleConnTimeout" json:"idleConnTimeout"`
+	// FallbackResponse keeps the persona in character when the provider fails
+	FallbackResponse string  `yaml:"fallbackResponse" json:"fallbackResponse"`
+	RateLimit        float64 `yaml:"rateLimit" json:"rateLimit"`
+	Burst            int     `yaml:"burst" json:"burst"`
+	MaxConcurrent    int     `yaml:"maxConcurrent" json:"maxConcurrent"`
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvReferences replaces ${VAR} with the environment value, an unset variable
+// is an error so that a missing secret does not silently become an empty key
+func expandEnvReferences(value string) (string, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+func protocolFromString(protocol string) (tracer.Protocol, error) {
+	for p := tracer.HTTP; p <= tracer.LDAP; p++ {
+		if strings.EqualFold(p.String(), protocol) {
+			return p, nil
+		}
+	}
+	return -1, fmt.Errorf("protocol %s not supported", protocol)
+}
+
+// LoadConfigFile reads a persona from a YAML or JSON file (by extension, YAML
+// otherwise). ${VAR} references in the string values are expanded from the
+// environment; the prompt is left as is. The returned config uses EnvFillEmpty,
+// so the file wins over the LLM_* variables when passed to InitLLMHoneypot
+func LoadConfigFile(path string) (LLMHoneypot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return LLMHoneypot{}, fmt.Errorf("reading config file: %v", err)
+	}
+
+	var file configFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(raw, &file)
+	} else {
+		err = yaml.Unmarshal(raw, &file)
+	}
+	if err != nil {
+		return LLMHoneypot{}, fmt.Errorf("parsing config file %s: %v", path, err)
+	}
+
+	for _, field := range []*string{
+		&file.Protocol, &file.Provider, &file.Model, &file.Host,
+		&file.OpenAIKey, &file.GoogleAPIKey, &file.CohereKey,
+		&file.GCPProject, &file.GCPRegion,
+		&file.CompatibleBaseURL, &file.CompatibleKey, &file.CompatibleAuthHeader,
+		&file.PromptFile, &file.Timeout,
+	} {
+		if *field, err = expandEnvReferences(*field); err != nil {
+			return LLMHoneypot{}, fmt.Errorf("config file %s: %v", path, err)
+		}
+	}
+	for i := range file.ExecCommand {
+		if file.ExecCommand[i], err = expandEnvReferences(file.ExecCommand[i]); err != nil {
+			return LLMHoneypot{}, fmt.Errorf("config file %s: %v", path, err)
+		}
+	}
+
+	for name, value := range file.ExtraHeaders {
+		if file.ExtraHeaders[name], err = expandEnvReferences(value); err != nil {
+			return LLMHoneypot{}, fmt.Errorf("config file %s: %v", path, err)
+		}
+	}
+
+	config := LLMHoneypot{
+		Host:                 file.Host,
+		Model:                file.Model,
+		OpenAIKey:            file.OpenAIKey,
+		GoogleAPIKey:         file.GoogleAPIKey,
+		CohereKey:            file.CohereKey,
+		VertexAI:             file.VertexAI,
+		GCPProject:           file.GCPProject,
+		GCPRegion:            file.GCPRegion,
+		CompatibleBaseURL:    file.CompatibleBaseURL,
+		CompatibleKey:        file.CompatibleKey,
+		CompatibleAuthHeader: file.CompatibleAuthHeader,
+		ExecCommand:          file.ExecCommand,
+		ExtraHeaders:         file.ExtraHeaders,
+		Persona:              file.Persona,
+		CustomPrompt:         file.CustomPrompt,
+		TopK:                 file.TopK,
+		Seed:                 file.Seed,
+		StopSequences:        file.StopSequences,
+		Stateless:            file.Stateless,
+		FallbackResponse:     file.FallbackResponse,
+		RateLimit:            file.RateLimit,
+		Burst:                file.Burst,
+		MaxConcurrent:        file.MaxConcurrent,
+		MaxIdleConns:         file.MaxIdleConns,
+		MaxIdleConnsPerHost:  file.MaxIdleConnsPerHost,
+		EnvPolicy:            EnvFillEmpty,
+	}
+
+	if file.Protocol != "" {
+		if config.Protocol, err = protocolFromString(file.Protocol); err != nil {
+			return LLMHoneypot{}, err
+		}
+	}
+	if file.Provider != "" {
+		if config.Provider, err = FromStringToLLMProvider(file.Provider); err != nil {
+			return LLMHoneypot{}, err
+		}
+	}
+	if config.Environment, err = RuntimeEnvironmentFromString(file.Environment); err != nil {
+		return LLMHoneypot{}, err
+	}
+	// an explicit 0 in the file must not fall back to the default
+	explicit := func(v *float32) float32 {
+		switch {
+		case v == nil:
+			return 0
+		case *v == 0:
+			return ExplicitZero
+		}
+		return *v
+	}
+	config.Temperature = explicit(file.Temperature)
+	config.TopP = explicit(file.TopP)
+	if file.Timeout != "" {
+		if config.Timeout, err = time.ParseDuration(file.Timeout); err != nil {
+			return LLMHoneypot{}, fmt.Errorf("invalid timeout %q: %v", file.Timeout, err)
+		}
+	}
+	if file.IdleConnTimeout != "" {
+		if config.IdleConnTimeout, err = time.ParseDuration(file.IdleConnTimeout); err != nil {
+			return LLMHoneypot{}, fmt.Errorf("invalid idleConnTimeout %q: %v", file.IdleConnTimeout, err)
+		}
+	}
+	if file.PromptFile != "" {
+		promptPath := file.PromptFile
+		if !filepath.IsAbs(promptPath) {
+			promptPath = filepath.Join(filepath.Dir(path), promptPath)
+		}
+		prompt, err := os.ReadFile(promptPath)
+		if err != nil {
+			return LLMHoneypot{}, fmt.Errorf("reading prompt file: %v", err)
+		}
+		config.CustomPrompt = string(prompt)
+	}
+	return config, nil
+}
@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigFileYAML(t *testing.T) {
+	//Given
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "prompt.txt"), []byte("You are a FreeBSD server"), 0o600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "persona.yaml"), []byte(`
+protocol: ssh
+environment: docker
+provider: openai
+model: gpt-4o
+openAIKey: ${TEST_HONEYPOT_OPENAI_KEY}
+promptFile: prompt.txt
+temperature: 0
+topK: 40
+timeout: 30s
+maxIdleConnsPerHost: 128
+idleConnTimeout: 5m
+extraHeaders:
+  OpenAI-Organization: ${TEST_HONEYPOT_OPENAI_ORG}
+stopSequences: ["$ "]
+`), 0o600))
+	t.Setenv("TEST_HONEYPOT_OPENAI_KEY", "sk-from-env")
+	t.Setenv("TEST_HONEYPOT_OPENAI_ORG", "org-research")
+
+	//When
+	config, err := LoadConfigFile(filepath.Join(dir, "persona.yaml"))
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, tracer.SSH, config.Protocol)
+	assert.Equal(t, EnvironmentDocker, config.Environment)
+	assert.Equal(t, OpenAI, config.Provider)
+	assert.Equal(t, "gpt-4o", config.Model)
+	assert.Equal(t, "sk-from-env", config.OpenAIKey)
+	assert.Equal(t, "You are a FreeBSD server", config.CustomPrompt)
+	assert.Equal(t, ExplicitZero, config.Temperature)
+	assert.Equal(t, float32(0), config.TopP)
+	assert.Equal(t, 40, config.TopK)
+	assert.Equal(t, 30*time.Second, config.Timeout)
+	assert.Equal(t, 128, config.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Minute, config.IdleConnTimeout)
+	assert.Equal(t, map[string]string{HeaderOpenAIOrganization: "org-research"}, config.ExtraHeaders)
+	assert.Equal(t, []string{"$ "}, config.StopSequences)
+	assert.Equal(t, EnvFillEmpty, config.EnvPolicy)
+}
+
+func TestLoadConfigFileJSON(t *testing.T) {
+	//Given
+	path := filepath.Join(t.TempDir(), "persona.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{
+		"protocol": "http",
+		"persona": "http/nginx",
+		"provider": "gemini",
+		"model": "gemini-1.5-flash",
+		"googleAPIKey": "plain-key",
+		"customPrompt": "Leave ${NOT_EXPANDED} alone"
+	}`), 0o600))
+
+	//When
+	config, err := LoadConfigFile(path)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, tracer.HTTP, config.Protocol)
+	assert.Equal(t, "http/nginx", config.Persona)
+	assert.Equal(t, Gemini, config.Provider)
+	assert.Equal(t, "plain-key", config.GoogleAPIKey)
+	assert.Equal(t, "Leave ${NOT_EXPANDED} alone", config.CustomPrompt)
+}
+
+func TestLoadConfigFileMissingEnvReference(t *testing.T) {
+	//Given
+	path := filepath.Join(t.TempDir(), "persona.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("provider: openai\nopenAIKey: ${TEST_HONEYPOT_UNSET_KEY}\n"), 0o600))
+
+	//When
+	_, err := LoadConfigFile(path)
+
+	//Then
+	assert.ErrorContains(t, err, "environment variable TEST_HONEYPOT_UNSET_KEY is not set")
+}
+
+func TestLoadConfigFileInvalidProvider(t *testing.T) {
+	//Given
+	path := filepath.Join(t.TempDir(), "persona.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("provider: anthropic\n"), 0o600))
+
+	//When
+	_, err := LoadConfigFile(path)
+
+	//Then
+	assert.ErrorContains(t, err, "provider anthropic not found")
+}
@@ -0,0 +1,119 @@
+package plugins
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Pool defaults, net/http keeps only 2 idle connections per host which forces a
+// new TLS handshake for most requests once a scan runs a few sessions in parallel
+const (
+	defaultMaxIdleConns        = 256
+	defaultMaxIdleConnsPerHost = 64
+	defaultIdleConnTimeout     = 120 * time.Second
+)
+
+// Strategies build an LLMHoneypot per request, so the transports are process-wide
+// and shared by every honeypot with the same pool and TLS settings
+var transports sync.Map // transportKey -> *http.Transport
+
+type transportKey struct {
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	tlsClientCert       string
+	tlsClientKey        string
+	tlsCACert           string
+	insecureSkipVerify  bool
+	// modification times of the TLS files, a rotated certificate gets a new transport
+	tlsClientCertMod int64
+	tlsClientKeyMod  int64
+	tlsCACertMod     int64
+}
+
+func (llm *LLMHoneypot) transportKey() transportKey {
+	key := transportKey{
+		maxIdleConns:        llm.MaxIdleConns,
+		maxIdleConnsPerHost: llm.MaxIdleConnsPerHost,
+		idleConnTimeout:     llm.IdleConnTimeout,
+		tlsClientCert:       llm.TLSClientCert,
+		tlsClientKey:        llm.TLSClientKey,
+		tlsCACert:           llm.TLSCACert,
+		insecureSkipVerify:  llm.InsecureSkipVerify,
+		tlsClientCertMod:    modTime(llm.TLSClientCert),
+		tlsClientKeyMod:     modTime(llm.TLSClientKey),
+		tlsCACertMod:        modTime(llm.TLSCACert),
+	}
+	if key.maxIdleConns <= 0 {
+		key.maxIdleConns = defaultMaxIdleConns
+	}
+	if key.maxIdleConnsPerHost <= 0 {
+		key.maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if key.idleConnTimeout <= 0 {
+		key.idleConnTimeout = defaultIdleConnTimeout
+	}
+	return key
+}
+
+// modTime is the modification time of the file at path, 0 when there is none
+func modTime(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
+
+// transport returns the pooled transport for the honeypot settings. When the TLS
+// files cannot be loaded every request fails with the error instead of connecting
+// without the client certificate or the private CA; Validate reports it at startup.
+// The failure is not cached, so a fixed file is picked up by the next honeypot
+func (llm *LLMHoneypot) transport() http.RoundTripper {
+	key := llm.transportKey()
+	if cached, ok := transports.Load(key); ok {
+		return cached.(*http.Transport)
+	}
+
+	transport, err := newTransport(key, llm)
+	if err != nil {
+		log.Errorf("error configuring LLM TLS: %s", err.Error())
+		return tlsErrorTransport{err: err}
+	}
+	cached, _ := transports.LoadOrStore(key, transport)
+	return cached.(*http.Transport)
+}
+
+// tlsErrorTransport fails every request with the TLS configuration error
+type tlsErrorTransport struct {
+	err error
+}
+
+func (t tlsErrorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("LLM TLS configuration: %w", t.err)
+}
+
+func newTransport(key transportKey, llm *LLMHoneypot) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = key.maxIdleConns
+	transport.MaxIdleConnsPerHost = key.maxIdleConnsPerHost
+	transport.IdleConnTimeout = key.idleConnTimeout
+	transport.ForceAttemptHTTP2 = true
+
+	tlsConfig, err := llm.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLLMHoneypotPoolDefaults(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(WithTimeout(time.Second))
+
+	//When
+	transport, ok := honeypot.client.GetClient().Transport.(*http.Transport)
+
+	//Then
+	assert.True(t, ok)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.Greater(t, transport.MaxIdleConnsPerHost, http.DefaultMaxIdleConnsPerHost)
+}
+
+func TestNewLLMHoneypotPoolSettings(t *testing.T) {
+	//Given
+	config := LLMHoneypot{MaxIdleConns: 10, MaxIdleConnsPerHost: 5, IdleConnTimeout: time.Minute}
+
+	//When
+	transport := NewLLMHoneypot(WithConfig(config)).client.GetClient().Transport.(*http.Transport)
+
+	//Then
+	assert.Equal(t, 10, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+}
+
+func TestHoneypotsShareTransport(t *testing.T) {
+	//Given
+	first := NewLLMHoneypot(WithModel("gpt-4o"))
+	second := NewLLMHoneypot(WithModel("gpt-4o-mini"))
+	insecure := NewLLMHoneypot(WithConfig(LLMHoneypot{InsecureSkipVerify: true}))
+
+	//When
+	transport := first.client.GetClient().Transport
+
+	//Then
+	assert.Same(t, transport, second.client.GetClient().Transport)
+	assert.NotSame(t, transport, insecure.client.GetClient().Transport)
+	assert.True(t, insecure.client.GetClient().Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+}
+
+func TestNewLLMHoneypotTLSErrorFailsRequests(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(
+		WithConfig(LLMHoneypot{TLSCACert: filepath.Join(t.TempDir(), "missing-ca.pem")}),
+		WithProtocol(tracer.SSH),
+		WithProvider(OpenAI),
+		WithModel("gpt-4o"),
+		WithOpenAIKey("sdjdnklfjndslkjanfk"),
+	)
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+	validateErr := honeypot.Validate()
+
+	//Then
+	assert.ErrorContains(t, err, "LLM TLS configuration: reading CA certificate")
+	assert.ErrorContains(t, validateErr, "invalid TLS settings: reading CA certificate")
+	_, cached := transports.Load(honeypot.transportKey())
+	assert.False(t, cached)
+}
+
+func TestRotatedCACertGetsNewTransport(t *testing.T) {
+	//Given
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(ca, pemCert, 0o600))
+	before := NewLLMHoneypot(WithConfig(LLMHoneypot{TLSCACert: ca}))
+
+	//When
+	rotated := time.Now().Add(time.Hour)
+	assert.Nil(t, os.Chtimes(ca, rotated, rotated))
+	after := NewLLMHoneypot(WithConfig(LLMHoneypot{TLSCACert: ca}))
+	again := NewLLMHoneypot(WithConfig(LLMHoneypot{TLSCACert: ca}))
+
+	//Then
+	assert.NotSame(t, before.client.GetClient().Transport, after.client.GetClient().Transport)
+	assert.Same(t, after.client.GetClient().Transport, again.client.GetClient().Transport)
+}
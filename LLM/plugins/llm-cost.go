@@ -0,0 +1,57 @@
+package plugins
+
+import "strings"
+
+// ModelPrice is the list price of a model in USD per million tokens
+type ModelPrice struct {
+	PricePerMillionInput  float64
+	PricePerMillionOutput float64
+}
+
+// defaultModelPrices are the public list prices at the time of writing, they
+// change often: set ModelPrices to override them or to price other models
+var defaultModelPrices = map[string]ModelPrice{
+	"gpt-4o":           {PricePerMillionInput: 2.50, PricePerMillionOutput: 10.00},
+	"gpt-4o-mini":      {PricePerMillionInput: 0.15, PricePerMillionOutput: 0.60},
+	"gpt-4.1":          {PricePerMillionInput: 2.00, PricePerMillionOutput: 8.00},
+	"gpt-4.1-mini":     {PricePerMillionInput: 0.40, PricePerMillionOutput: 1.60},
+	"gpt-4.1-nano":     {PricePerMillionInput: 0.10, PricePerMillionOutput: 0.40},
+	"gpt-3.5-turbo":    {PricePerMillionInput: 0.50, PricePerMillionOutput: 1.50},
+	"gemini-1.5-flash": {PricePerMillionInput: 0.075, PricePerMillionOutput: 0.30},
+	"gemini-1.5-pro":   {PricePerMillionInput: 1.25, PricePerMillionOutput: 5.00},
+	"gemini-2.0-flash": {PricePerMillionInput: 0.10, PricePerMillionOutput: 0.40},
+	"gemini-2.5-flash": {PricePerMillionInput: 0.30, PricePerMillionOutput: 2.50},
+	"gemini-2.5-pro":   {PricePerMillionInput: 1.25, PricePerMillionOutput: 10.00},
+}
+
+// EstimateCost converts usage into USD with the price of Model. Dated snapshots
+// such as gpt-4o-2024-08-06 use the price of the longest matching name, unknown
+// models (local Ollama ones included) cost zero
+func (llm *LLMHoneypot) EstimateCost(usage Usage) float64 {
+	return llm.estimateCost(llm.Model, usage)
+}
+
+func (llm *LLMHoneypot) estimateCost(model string, usage Usage) float64 {
+	price, ok := lookupModelPrice(llm.ModelPrices, model)
+	if !ok {
+		price, _ = lookupModelPrice(defaultModelPrices, model)
+	}
+	return (float64(usage.PromptTokens)*price.PricePerMillionInput +
+		float64(usage.CompletionTokens)*price.PricePerMillionOutput) / 1e6
+}
+
+func lookupModelPrice(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+	var best string
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return prices[best], true
+}
@@ -0,0 +1,28 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateCost(t *testing.T) {
+	//Given
+	usage := Usage{PromptTokens: 2_000_000, CompletionTokens: 500_000, TotalTokens: 2_500_000}
+	snapshot := LLMHoneypot{Model: "gpt-4o-mini-2024-07-18"}
+	overridden := LLMHoneypot{
+		Model:       "gpt-4o",
+		ModelPrices: map[string]ModelPrice{"gpt-4o": {PricePerMillionInput: 1, PricePerMillionOutput: 2}},
+	}
+	local := LLMHoneypot{Model: "llama3"}
+
+	//When
+	snapshotCost := snapshot.EstimateCost(usage)
+	overriddenCost := overridden.EstimateCost(usage)
+	localCost := local.EstimateCost(usage)
+
+	//Then
+	assert.InDelta(t, 0.6, snapshotCost, 1e-9)
+	assert.InDelta(t, 3.0, overriddenCost, 1e-9)
+	assert.Equal(t, 0.0, localCost)
+}
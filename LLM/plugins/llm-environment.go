@@ -0,0 +1,126 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// RuntimeEnvironment is what the SSH persona reveals about where it runs, for the
+// probes attackers use to spot containers (cat /proc/1/cgroup, ls /.dockerenv)
+type RuntimeEnvironment int
+
+const (
+	// EnvironmentUnset leaves it to the model
+	EnvironmentUnset RuntimeEnvironment = iota
+	// EnvironmentHost is a plain server booted with systemd
+	EnvironmentHost
+	// EnvironmentDocker is a Docker container: /.dockerenv, /docker/<id> cgroups
+	EnvironmentDocker
+	// EnvironmentKubernetes is a pod container: kubepods cgroups, service account
+	EnvironmentKubernetes
+)
+
+func (e RuntimeEnvironment) String() string {
+	return [...]string{"", "host", "docker", "kubernetes"}[e]
+}
+
+// RuntimeEnvironmentFromString parses "host", "docker" or "kubernetes", empty is EnvironmentUnset
+func RuntimeEnvironmentFromString(environment string) (RuntimeEnvironment, error) {
+	for e := EnvironmentUnset; e <= EnvironmentKubernetes; e++ {
+		if strings.EqualFold(e.String(), environment) {
+			return e, nil
+		}
+	}
+	return -1, fmt.Errorf("environment %s not found, valid environments: host, docker, kubernetes", environment)
+}
+
+// cgroupV1Controllers are the hierarchies of /proc/1/cgroup on a cgroup v1 host,
+// the layout container runtimes still show
+var cgroupV1Controllers = []string{
+	"12:hugetlb", "11:memory", "10:cpuset", "9:pids", "8:freezer", "7:devices",
+	"6:cpu,cpuacct", "5:blkio", "4:net_cls,net_prio", "3:perf_event", "2:rdma", "1:name=systemd",
+}
+
+// containerID is the 64 hex digits ID of the container, stable for a hostname
+func (llm *LLMHoneypot) containerID() string {
+	sum := sha256.Sum256([]byte("container:" + llm.promptVariables().Hostname))
+	return hex.EncodeToString(sum[:])
+}
+
+// podUID is the UID of the pod, a UUID stable for a hostname
+func (llm *LLMHoneypot) podUID() string {
+	sum := sha256.Sum256([]byte("pod:" + llm.promptVariables().Hostname))
+	h := hex.EncodeToString(sum[:16])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+func cgroupFile(path string) string {
+	var b strings.Builder
+	for _, controller := range cgroupV1Controllers {
+		fmt.Fprintf(&b, "%s:%s\n", controller, path)
+	}
+	fmt.Fprintf(&b, "0::%s\n", path)
+	return b.String()
+}
+
+// environmentFiles are the files answered without the model for the Environment,
+// FileOverrides wins over them
+func (llm *LLMHoneypot) environmentFiles() map[string]string {
+	switch llm.Environment {
+	case EnvironmentHost:
+		return map[string]string{"/proc/1/cgroup": "0::/init.scope\n"}
+	case EnvironmentDocker:
+		return map[string]string{
+			"/.dockerenv":    "",
+			"/proc/1/cgroup": cgroupFile("/docker/" + llm.containerID()),
+		}
+	case EnvironmentKubernetes:
+		serviceAccount := "/var/run/secrets/kubernetes.io/serviceaccount/"
+		return map[string]string{
+			"/proc/1/cgroup":             cgroupFile(fmt.Sprintf("/kubepods/burstable/pod%s/%s", llm.podUID(), llm.containerID())),
+			serviceAccount + "namespace": "default",
+			serviceAccount + "token":     llm.serviceAccountToken(),
+		}
+	}
+	return nil
+}
+
+// serviceAccountToken is a JWT shaped like a projected service account token, its
+// signature is random bytes: it leads nowhere but looks worth stealing
+func (llm *LLMHoneypot) serviceAccountToken() string {
+	encode := base64.RawURLEncoding.EncodeToString
+	header := `{"alg":"RS256","kid":"` + llm.containerID()[:43] + `"}`
+	payload := fmt.Sprintf(`{"aud":["https://kubernetes.default.svc.cluster.local"],"exp":1767225600,"iat":1735689600,`+
+		`"iss":"https://kubernetes.default.svc.cluster.local","kubernetes.io":{"namespace":"default",`+
+		`"pod":{"name":"%s","uid":"%s"},"serviceaccount":{"name":"default"}},"nbf":1735689600,`+
+		`"sub":"system:serviceaccount:default:default"}`, llm.promptVariables().Hostname, llm.podUID())
+	signature := sha256.Sum256([]byte("signature:" + llm.containerID()))
+	return encode([]byte(header)) + "." + encode([]byte(payload)) + "." + encode(append(signature[:], signature[:]...))
+}
+
+// environmentInstruction is appended to the SSH prompt, so that the probes the
+// files above do not answer (ls, ps, mount, env) tell the same story
+func (llm *LLMHoneypot) environmentInstruction() string {
+	switch llm.Environment {
+	case EnvironmentHost:
+		return "\n\nThe system is a virtual machine, not a container: PID 1 is systemd, /.dockerenv does not exist, " +
+			"/proc/1/cgroup reads 0::/init.scope, the root filesystem is an ext4 disk and ps shows the usual system daemons."
+	case EnvironmentDocker:
+		return fmt.Sprintf("\n\nThe shell runs inside a Docker container %s: /.dockerenv exists and is empty, "+
+			"/proc/1/cgroup lists /docker/%s, PID 1 is the entrypoint of the image and there is no systemd "+
+			"(systemctl answers \"System has not been booted with systemd as init system (PID 1). Can't operate.\"), "+
+			"ps shows only a few processes, / is an overlay mount and the network is a single eth0 on 172.17.0.0/16.",
+			llm.containerID()[:12], llm.containerID())
+	case EnvironmentKubernetes:
+		return fmt.Sprintf("\n\nThe shell runs in a container of the Kubernetes pod %s in namespace default, run by containerd: "+
+			"there is no /.dockerenv, /proc/1/cgroup lists /kubepods/burstable/pod%s, KUBERNETES_SERVICE_HOST=10.96.0.1 and "+
+			"KUBERNETES_SERVICE_PORT=443 are set, the default service account is mounted in "+
+			"/var/run/secrets/kubernetes.io/serviceaccount (ca.crt, namespace, token), PID 1 is the application and there is "+
+			"no systemd, ps shows only a few processes, / is an overlay mount and eth0 has a 10.244.0.0/16 pod address.",
+			llm.promptVariables().Hostname, llm.podUID())
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package plugins
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerEnvironmentProbes(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:        tracer.SSH,
+		Environment:     EnvironmentDocker,
+		PromptVariables: PromptVariables{Hostname: "web-01"},
+	})
+	id := honeypot.containerID()
+
+	//When
+	dockerenv, dockerenvErr := honeypot.ExecuteModel("cat /.dockerenv")
+	cgroup, cgroupErr := honeypot.ExecuteModel("head -n 2 /proc/1/cgroup")
+	prompt, promptErr := honeypot.buildPrompt("ls -la /")
+
+	//Then
+	assert.Nil(t, dockerenvErr)
+	assert.Equal(t, "", dockerenv)
+	assert.Nil(t, cgroupErr)
+	assert.Equal(t, "12:hugetlb:/docker/"+id+"\n11:memory:/docker/"+id, cgroup)
+	assert.Nil(t, promptErr)
+	assert.Contains(t, prompt[0].Content, "/.dockerenv exists and is empty")
+	assert.Contains(t, prompt[0].Content, "Docker container "+id[:12])
+	assert.Len(t, id, 64)
+}
+
+func TestKubernetesEnvironmentProbes(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Environment: EnvironmentKubernetes})
+
+	//When
+	namespace, _ := honeypot.ExecuteModel("cat /var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	token, _ := honeypot.ExecuteModel("cat /var/run/secrets/kubernetes.io/serviceaccount/token")
+	cgroup, _ := honeypot.ExecuteModel("tail -1 /proc/1/cgroup")
+	_, dockerenv := honeypot.environmentFiles()["/.dockerenv"]
+
+	//Then
+	assert.Equal(t, "default", namespace)
+	assert.Len(t, strings.Split(token, "."), 3)
+	assert.True(t, strings.HasPrefix(token, "eyJhbGciOiJSUzI1NiIs"))
+	assert.Equal(t, "0::/kubepods/burstable/pod"+honeypot.podUID()+"/"+honeypot.containerID(), cgroup)
+	assert.False(t, dockerenv)
+}
+
+func TestEnvironmentFilesYieldToFileOverrides(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Environment:   EnvironmentHost,
+		FileOverrides: map[string]string{"/proc/1/cgroup": "0::/system.slice/custom.scope\n"},
+	})
+	unset := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH})
+
+	//When
+	cgroup, _ := honeypot.readOverriddenFiles("cat /proc/1/cgroup")
+	_, handled := unset.readOverriddenFiles("cat /proc/1/cgroup")
+	prompt, _ := unset.buildPrompt("ls")
+
+	//Then
+	assert.Equal(t, "0::/system.slice/custom.scope", cgroup)
+	assert.False(t, handled)
+	assert.Equal(t, systemPromptVirtualizeLinuxTerminal, prompt[0].Content)
+}
+
+func TestRuntimeEnvironmentFromString(t *testing.T) {
+	//When
+	kubernetes, err := RuntimeEnvironmentFromString("Kubernetes")
+	unset, unsetErr := RuntimeEnvironmentFromString("")
+	_, invalid := RuntimeEnvironmentFromString("lxc")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, EnvironmentKubernetes, kubernetes)
+	assert.Nil(t, unsetErr)
+	assert.Equal(t, EnvironmentUnset, unset)
+	assert.EqualError(t, invalid, "environment lxc not found, valid environments: host, docker, kubernetes")
+}
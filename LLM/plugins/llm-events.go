@@ -0,0 +1,76 @@
+package plugins
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// EventPolicy decides what happens to an interaction when the Events channel is full
+type EventPolicy int
+
+const (
+	// EventDrop discards the interaction, a slow consumer never slows the attacker down
+	EventDrop EventPolicy = iota
+	// EventBlock waits for the consumer, or for the context of the call to end
+	EventBlock
+)
+
+const defaultEventBuffer = 100
+
+var droppedInteractionEvents atomic.Uint64
+
+var _ = promauto.NewCounterFunc(prometheus.CounterOpts{
+	Namespace: "beelzebub",
+	Name:      "llm_dropped_interaction_events_total",
+	Help:      "The total number of interactions dropped because the Events channel was full",
+}, func() float64 { return float64(droppedInteractionEvents.Load()) })
+
+// DroppedInteractionEvents is the number of interactions lost to a full Events channel
+func DroppedInteractionEvents() uint64 {
+	return droppedInteractionEvents.Load()
+}
+
+// Events streams every interaction of the honeypot and of the sessions created from it
+// with NewSession afterwards. The channel is created by the first call, interactions
+// before it are not buffered. It is never closed, consumers stop reading when done
+func (llm *LLMHoneypot) Events() <-chan Interaction {
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if llm.events == nil {
+		size := llm.EventBuffer
+		if size <= 0 {
+			size = defaultEventBuffer
+		}
+		llm.events = make(chan Interaction, size)
+	}
+	return llm.events
+}
+
+func (llm *LLMHoneypot) publish(ctx context.Context, interaction Interaction) {
+	mu := llm.historyLock()
+	mu.Lock()
+	events := llm.events
+	mu.Unlock()
+	if events == nil {
+		return
+	}
+
+	if llm.EventPolicy == EventBlock {
+		select {
+		case events <- interaction:
+		case <-ctx.Done():
+			droppedInteractionEvents.Add(1)
+		}
+		return
+	}
+	select {
+	case events <- interaction:
+	default:
+		droppedInteractionEvents.Add(1)
+	}
+}
@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func eventsHoneypot(t *testing.T, config LLMHoneypot) *LLMHoneypot {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	config.Protocol = tracer.SSH
+	config.Model = "llama3"
+	config.Provider = Ollama
+	honeypot := InitLLMHoneypot(config)
+	honeypot.client = client
+	return honeypot
+}
+
+func TestEvents(t *testing.T) {
+	//Given
+	honeypot := eventsHoneypot(t, LLMHoneypot{})
+	events := honeypot.Events()
+	session := honeypot.NewSession()
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+	assert.Nil(t, err)
+	_, err = session.ExecuteModel("id")
+	assert.Nil(t, err)
+
+	//Then
+	first, second := <-events, <-events
+	assert.Equal(t, "ls", first.Command)
+	assert.Equal(t, "prova.txt", first.Response)
+	assert.Equal(t, "llama3", first.Model)
+	assert.Greater(t, first.Latency, time.Duration(0))
+	assert.Equal(t, "id", second.Command)
+	assert.Nil(t, honeypot.Clone().events)
+}
+
+func TestEventsDropWhenFull(t *testing.T) {
+	//Given
+	honeypot := eventsHoneypot(t, LLMHoneypot{EventBuffer: 1})
+	events := honeypot.Events()
+	dropped := DroppedInteractionEvents()
+
+	//When
+	honeypot.ExecuteModel("ls")
+	honeypot.ExecuteModel("id")
+
+	//Then
+	assert.Equal(t, "ls", (<-events).Command)
+	assert.Equal(t, dropped+1, DroppedInteractionEvents())
+}
+
+func TestEventsBlockUntilContextDone(t *testing.T) {
+	//Given
+	honeypot := eventsHoneypot(t, LLMHoneypot{EventBuffer: 1, EventPolicy: EventBlock})
+	events := honeypot.Events()
+	honeypot.ExecuteModel("ls")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	//When
+	start := time.Now()
+	_, err := honeypot.ExecuteModelContext(ctx, "id")
+
+	//Then
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, "ls", (<-events).Command)
+}
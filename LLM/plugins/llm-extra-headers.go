@@ -0,0 +1,69 @@
+package plugins
+
+import (
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Provider headers that change how the request is attributed, retained or
+// moderated. Which providers honor them:
+//
+//   - OpenAI: OpenAI-Organization and OpenAI-Project bill and log the request
+//     under that organization or project, so research traffic can be kept in
+//     a project with its own retention and abuse settings. Zero data retention
+//     is an organization-level agreement with OpenAI, there is no header for it.
+//   - Compatible gateways: OpenRouter reads HTTP-Referer and X-Title to identify
+//     the application, its data collection opt-out is part of the request body
+//     and account settings. LiteLLM and other proxies forward or act on their
+//     own headers, see their documentation.
+//   - Ollama, Gemini, Vertex AI and Cohere have no header for retention or abuse
+//     reporting, ExtraHeaders are still sent e.g. for an authenticating proxy.
+const (
+	HeaderOpenAIOrganization = "OpenAI-Organization"
+	HeaderOpenAIProject      = "OpenAI-Project"
+)
+
+// reservedHeaders are set by the honeypot itself and cannot be overridden
+var reservedHeaders = map[string]bool{
+	"Authorization": true,
+	"Content-Type":  true,
+	textproto.CanonicalMIMEHeaderKey(requestIDHeader): true,
+}
+
+// applyExtraHeaders sets ExtraHeaders in a stable order, the authentication and
+// content headers are set afterwards by the callers and always win
+func (llm *LLMHoneypot) applyExtraHeaders(req *resty.Request) {
+	names := make([]string, 0, len(llm.ExtraHeaders))
+	for name := range llm.ExtraHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+			req.SetHeader(name, llm.ExtraHeaders[name])
+		}
+	}
+}
+
+// validateExtraHeaders rejects names that are not HTTP tokens and the headers the
+// honeypot sets itself, including CompatibleAuthHeader
+func (llm *LLMHoneypot) validateExtraHeaders() []error {
+	var errs []error
+	for name, value := range llm.ExtraHeaders {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		switch {
+		case name == "" || strings.ContainsAny(name, " \t\r\n:"):
+			errs = append(errs, fmt.Errorf("invalid extra header name %q", name))
+		case reservedHeaders[canonical],
+			llm.CompatibleAuthHeader != "" && canonical == textproto.CanonicalMIMEHeaderKey(llm.CompatibleAuthHeader):
+			errs = append(errs, fmt.Errorf("extra header %s is set by the honeypot", name))
+		case strings.ContainsAny(value, "\r\n"):
+			errs = append(errs, fmt.Errorf("invalid value for extra header %s", name))
+		}
+	}
+	return errs
+}
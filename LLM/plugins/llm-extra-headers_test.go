@@ -0,0 +1,76 @@
+package plugins
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteModelSendsExtraHeaders(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var header http.Header
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			header = req.Header
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		ExtraHeaders: map[string]string{
+			HeaderOpenAIOrganization: "org-research",
+			HeaderOpenAIProject:      "proj_honeypot",
+			"authorization":          "Bearer stolen",
+		},
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "org-research", header.Get("OpenAI-Organization"))
+	assert.Equal(t, "proj_honeypot", header.Get("OpenAI-Project"))
+	assert.Equal(t, "Bearer sdjdnklfjndslkjanfk", header.Get("Authorization"))
+}
+
+func TestValidateExtraHeaders(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Protocol:             tracer.SSH,
+		Model:                "llama3",
+		Provider:             Compatible,
+		CompatibleBaseURL:    "http://localhost:4000/v1",
+		CompatibleAuthHeader: "api-key",
+		ExtraHeaders: map[string]string{
+			"X-Title":      "research",
+			"Content-Type": "text/plain",
+			"Api-Key":      "other",
+			"Bad Header":   "x",
+			"X-Injected":   "a\r\nHost: evil",
+		},
+	}
+
+	//When
+	err := honeypot.Validate()
+
+	//Then
+	assert.ErrorContains(t, err, "extra header Content-Type is set by the honeypot")
+	assert.ErrorContains(t, err, "extra header Api-Key is set by the honeypot")
+	assert.ErrorContains(t, err, `invalid extra header name "Bad Header"`)
+	assert.ErrorContains(t, err, "invalid value for extra header X-Injected")
+	assert.NotContains(t, err.Error(), "X-Title")
+}
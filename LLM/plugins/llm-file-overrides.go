@@ -0,0 +1,117 @@
+package plugins
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+)
+
+// fileReaders are the commands answered from FileOverrides, head and tail keep 10 lines by default
+var fileReaders = map[string]bool{"cat": true, "less": true, "more": true, "head": true, "tail": true}
+
+// readOverriddenFiles answers `cat`, `less`, `more`, `head` and `tail` of paths in
+// FileOverrides, planted in the Scenario or among the files of the Environment. Only
+// simple commands are handled: pipes, redirections, command lists and any path
+// without an override go to the model
+func (llm *LLMHoneypot) readOverriddenFiles(command string) (string, bool) {
+	planted := llm.Scenario != nil && llm.Scenario.hasFiles()
+	if (len(llm.FileOverrides) == 0 && llm.Environment == EnvironmentUnset && !planted) || llm.Protocol != tracer.SSH || strings.ContainsAny(command, "|;&<>`$(") {
+		return "", false
+	}
+	fields := strings.Fields(command)
+	if len(fields) > 0 && fields[0] == "sudo" {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 || !fileReaders[fields[0]] {
+		return "", false
+	}
+	reader, lines := fields[0], 10
+
+	var contents []string
+	for i := 1; i < len(fields); i++ {
+		arg := fields[i]
+		switch {
+		case (reader == "head" || reader == "tail") && arg == "-n" && i+1 < len(fields):
+			i++
+			arg = "-n" + fields[i]
+			fallthrough
+		case (reader == "head" || reader == "tail") && strings.HasPrefix(arg, "-") && len(arg) > 1:
+			n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "n"))
+			if err != nil {
+				return "", false
+			}
+			lines = n
+		case strings.HasPrefix(arg, "-"):
+			// options of cat, less and more do not change plain text files enough to matter
+		default:
+			content, ok := llm.fileOverride(arg)
+			if !ok {
+				return "", false
+			}
+			contents = append(contents, content)
+		}
+	}
+	if len(contents) == 0 {
+		return "", false
+	}
+
+	for i, content := range contents {
+		switch reader {
+		case "head":
+			contents[i] = headLines(content, lines)
+		case "tail":
+			contents[i] = tailLines(content, lines)
+		}
+	}
+	return strings.TrimRight(strings.Join(contents, ""), "\n"), true
+}
+
+// fileOverride looks p up after expanding ~ and resolving it against the home
+// directory, keys may be written either way (~/.aws/credentials or /root/.aws/credentials)
+func (llm *LLMHoneypot) fileOverride(p string) (string, bool) {
+	home := "/home/" + llm.promptVariables().Username
+	if llm.promptVariables().Username == "root" {
+		home = "/root"
+	}
+	resolve := func(p string) string {
+		if p == "~" || strings.HasPrefix(p, "~/") {
+			p = home + p[1:]
+		}
+		if !path.IsAbs(p) {
+			p = path.Join(home, p)
+		}
+		return path.Clean(p)
+	}
+
+	target := resolve(p)
+	for key, content := range llm.FileOverrides {
+		if resolve(key) == target {
+			return content, true
+		}
+	}
+	if llm.Scenario != nil {
+		if content, ok := llm.Scenario.file(target); ok {
+			return content, true
+		}
+	}
+	content, ok := llm.environmentFiles()[target]
+	return content, ok
+}
+
+func headLines(content string, n int) string {
+	lines := strings.SplitAfter(content, "\n")
+	if n < len(lines) {
+		lines = lines[:n]
+	}
+	return strings.Join(lines, "")
+}
+
+func tailLines(content string, n int) string {
+	lines := strings.SplitAfter(strings.TrimSuffix(content, "\n"), "\n")
+	if n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "") + "\n"
+}
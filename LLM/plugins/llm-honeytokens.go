@@ -0,0 +1,72 @@
+package plugins
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// honeytokenPlaceholder matches {{TOKEN:name}}, the stand-in the model copies in
+// place of a canary value it would otherwise reformat or line-wrap
+var honeytokenPlaceholder = regexp.MustCompile(`\{\{\s*TOKEN:([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// maxPendingPlaceholder bounds what a stream holds back waiting for the end of a
+// placeholder, so that an unrelated "{{" in the output does not stall it
+const maxPendingPlaceholder = 64
+
+const honeytokenInstruction = "\n\nValues written as {{TOKEN:name}} placeholders are filled in after you answer. " +
+	"Wherever such a value belongs in the output, write its placeholder exactly as given, " +
+	"and never invent, shorten or wrap the value yourself."
+
+// escapeHoneytokens turns the placeholders into template literals, so that the
+// system prompt still renders as a text/template
+func escapeHoneytokens(prompt string) string {
+	return honeytokenPlaceholder.ReplaceAllString(prompt, `{{"{{TOKEN:${1}}}"}}`)
+}
+
+// substituteHoneytokens replaces the placeholders with the exact Honeytokens values,
+// unknown names are left as written
+func (llm *LLMHoneypot) substituteHoneytokens(output string) string {
+	if len(llm.Honeytokens) == 0 || !strings.Contains(output, "{{") {
+		return output
+	}
+	return honeytokenPlaceholder.ReplaceAllStringFunc(output, func(placeholder string) string {
+		name := honeytokenPlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := llm.Honeytokens[name]
+		if !ok {
+			log.Warnf("no honeytoken named %q, the placeholder is left in the output", name)
+			return placeholder
+		}
+		return value
+	})
+}
+
+// honeytokenStream substitutes the placeholders of streamed chunks, holding back
+// a tail that may be a placeholder split across chunks. flush sends what is left
+func (llm *LLMHoneypot) honeytokenStream(onChunk func(string)) (write func(string), flush func()) {
+	if len(llm.Honeytokens) == 0 {
+		return onChunk, func() {}
+	}
+	var pending string
+	write = func(chunk string) {
+		pending += chunk
+		cut := len(pending)
+		if i := strings.LastIndex(pending, "{{"); i >= 0 && !strings.Contains(pending[i:], "}}") && len(pending)-i < maxPendingPlaceholder {
+			cut = i
+		} else if strings.HasSuffix(pending, "{") {
+			cut--
+		}
+		if cut > 0 {
+			onChunk(llm.substituteHoneytokens(pending[:cut]))
+			pending = pending[cut:]
+		}
+	}
+	flush = func() {
+		if pending != "" {
+			onChunk(llm.substituteHoneytokens(pending))
+			pending = ""
+		}
+	}
+	return write, flush
+}
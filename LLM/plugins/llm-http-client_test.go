@@ -0,0 +1,62 @@
+package plugins
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTransport stands in for an instrumented transport, e.g. otelhttp
+type countingTransport struct {
+	requests int
+	next     http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return c.next.RoundTrip(req)
+}
+
+func TestExecuteModelWithInjectedHTTPClient(t *testing.T) {
+	mock := httpmock.NewMockTransport()
+	mock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	// Given
+	transport := &countingTransport{next: mock}
+	honeypot := NewLLMHoneypot(
+		WithProtocol(tracer.SSH),
+		WithProvider(OpenAI),
+		WithModel("gpt-4o"),
+		WithOpenAIKey("sdjdnklfjndslkjanfk"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+	)
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, 1, transport.requests)
+}
+
+func TestNewLLMHoneypotKeepsInjectedRestyClient(t *testing.T) {
+	//Given
+	client := resty.New().SetTimeout(5 * time.Second)
+
+	//When
+	honeypot := NewLLMHoneypot(WithRestyClient(client), WithTimeout(time.Second))
+
+	//Then
+	assert.Same(t, client, honeypot.client)
+	assert.Equal(t, 5*time.Second, client.GetClient().Timeout)
+}
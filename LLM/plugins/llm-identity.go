@@ -0,0 +1,71 @@
+package plugins
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// MachineIdentity is the host a session believes it is connected to
+type MachineIdentity struct {
+	Hostname string
+	Kernel   string
+	MAC      string
+	BootTime time.Time
+}
+
+var (
+	identityRoles   = []string{"web", "db", "app", "api", "mail", "backup", "build", "cache"}
+	identityKernels = []struct{ release, version string }{
+		{"5.15.0-91-generic", "#101-Ubuntu SMP Tue Nov 14 13:30:08 UTC 2023"},
+		{"5.15.0-105-generic", "#115-Ubuntu SMP Mon Apr 15 09:52:04 UTC 2024"},
+		{"6.5.0-35-generic", "#35~22.04.1-Ubuntu SMP PREEMPT_DYNAMIC Tue May  7 09:00:52 UTC 2"},
+		{"6.8.0-45-generic", "#45-Ubuntu SMP PREEMPT_DYNAMIC Fri Aug 30 12:02:04 UTC 2024"},
+	}
+)
+
+// UnameA renders the identity as `uname -a` prints it
+func (id MachineIdentity) UnameA() string {
+	return fmt.Sprintf("Linux %s %s x86_64 x86_64 x86_64 GNU/Linux", id.Hostname, id.Kernel)
+}
+
+// PromptContext is the system message that keeps hostname, uname, ip link and uptime consistent
+func (id MachineIdentity) PromptContext() string {
+	return fmt.Sprintf("This machine has a fixed identity, every answer MUST match it.\n"+
+		"hostname: %s\nuname -a: %s\nMAC address of eth0: %s\nbooted at: %s",
+		id.Hostname, id.UnameA(), id.MAC, id.BootTime.UTC().Format("2006-01-02 15:04:05 UTC"))
+}
+
+// newMachineIdentity draws a plausible Ubuntu server on a KVM host, booted 1 to 90 days ago
+func newMachineIdentity(r *rand.Rand, now time.Time) MachineIdentity {
+	kernel := identityKernels[r.Intn(len(identityKernels))]
+	return MachineIdentity{
+		Hostname: fmt.Sprintf("%s-%02d", identityRoles[r.Intn(len(identityRoles))], 1+r.Intn(40)),
+		Kernel:   kernel.release + " " + kernel.version,
+		MAC:      fmt.Sprintf("52:54:00:%02x:%02x:%02x", r.Intn(256), r.Intn(256), r.Intn(256)),
+		BootTime: now.Add(-time.Duration(1+r.Intn(90*24*60)) * time.Minute).Truncate(time.Second),
+	}
+}
+
+// NewSession returns a copy of the honeypot for a new attacker session: empty
+// history and state, and a random identity so sessions cannot be correlated
+func (llm *LLMHoneypot) NewSession() *LLMHoneypot {
+	session := *llm
+	session.historyMu = &sync.Mutex{}
+	session.Histories = nil
+	session.HistorySummary = ""
+	// a new session is a new attacker, the caller sets their ClientContext
+	session.Client = nil
+	session.Scenario = nil
+	session.retryLimiter = nil
+	session.recorder, session.replay = nil, nil
+	session.lastRaw = nil
+	if llm.State != nil {
+		session.State = NewSessionState()
+	}
+
+	identity := newMachineIdentity(rand.New(rand.NewSource(time.Now().UnixNano())), time.Now())
+	session.Identity = &identity
+	return &session
+}
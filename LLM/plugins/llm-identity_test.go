@@ -0,0 +1,46 @@
+package plugins
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMachineIdentityIsStableForASeed(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	first := newMachineIdentity(rand.New(rand.NewSource(42)), now)
+	second := newMachineIdentity(rand.New(rand.NewSource(42)), now)
+
+	assert.Equal(t, first, second)
+	assert.Regexp(t, `^[a-z]+-\d{2}$`, first.Hostname)
+	assert.Regexp(t, `^52:54:00(:[0-9a-f]{2}){3}$`, first.MAC)
+	assert.True(t, first.BootTime.Before(now))
+	assert.Regexp(t, `^Linux `+first.Hostname+` \d\.\d+\.0-\d+-generic #.* x86_64 GNU/Linux$`, first.UnameA())
+}
+
+func TestNewSession(t *testing.T) {
+	//Given
+	base := NewLLMHoneypot(WithProtocol(tracer.SSH), WithModel("llama3"))
+	base.State = NewSessionState()
+	base.AppendHistory(USER, "whoami")
+
+	//When
+	session := base.NewSession()
+	prompt, err := session.buildPrompt("hostname")
+	again, _ := session.buildPrompt("uname -a")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Nil(t, base.Identity)
+	assert.Empty(t, session.Histories)
+	assert.NotSame(t, base.State, session.State)
+	assert.Equal(t, "llama3", session.Model)
+	assert.Equal(t, SYSTEM.String(), prompt[3].Role)
+	assert.Contains(t, prompt[3].Content, "hostname: "+session.Identity.Hostname)
+	assert.Contains(t, prompt[3].Content, session.Identity.UnameA())
+	assert.Equal(t, prompt[3], again[3])
+}
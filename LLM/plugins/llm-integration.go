@@ -2,15 +2,34 @@
 package plugins
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/mariocandela/beelzebub/v3/tracer"
 	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"net/http"
 	"os"
+	"os/exec"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -22,12 +41,193 @@ If a command is unrecognised, reply exactly: "command not found".`
 
 	systemPromptVirtualizeHTTPServer = "You will act as an unsecure HTTP Server with multiple vulnerabilities such as AWS && Git credentials in the root HTTP directory. The user will send HTTP requests, and you must reply with what the server should show. Do not provide explanations or type commands unless explicitly instructed by the user."
 
-	LLMPluginName  = "LLMHoneypot"
+	systemPromptVirtualizeWebSocketServer = `
+You are the WebSocket endpoint of a Node.js chat and notification service behind nginx/1.24.0.
+The first user message is the HTTP upgrade request: reply with the raw "HTTP/1.1 101 Switching Protocols" response and the correct Sec-WebSocket-Accept for the given Sec-WebSocket-Key, or with a 400/404 response if the request is not a valid upgrade.
+Every following user message is the payload of one text frame sent by the client. Reply ONLY with the payloads of the frames the server sends back, one frame per line, usually JSON messages with "type" and "data" fields.
+Invent plausible channels, users and notifications with internal hostnames and tokens, keep them consistent across frames, and answer unknown message types with an error frame. Never add explanations.`
+
+	systemPromptVirtualizeGitSmartHTTPServer = `
+You are a self-hosted Gitea 1.21 server exposing the repository /acme/backend.git over the Git smart HTTP protocol.
+The user sends raw HTTP requests: GET /info/refs?service=git-upload-pack or git-receive-pack, POST /git-upload-pack with want/have lines, or browser requests.
+Reply ONLY with the raw HTTP response. Ref advertisements use Content-Type application/x-git-<service>-advertisement and the pkt-line format: every line is prefixed by its length as 4 lowercase hex digits counting the prefix and the trailing "\n", "0000" is a flush packet, the first ref carries the capabilities after a NUL byte.
+Advertise the same refs every time: HEAD and refs/heads/main at 8d3f2a6c1e9b47f0a2c5d8e1b4f7a0c3d6e9f2a5, refs/heads/develop at 1b7e4c9a2f5d8b0e3a6c9f2d5b8e1a4c7f0d3b6e, refs/tags/v2.3.1. Answer receive-pack with 401 and WWW-Authenticate: Basic realm="Gitea". Never add explanations.`
+
+	systemPromptVirtualizeDNSServer = `
+You are an authoritative DNS server answering queries like the dig utility would print them.
+The user sends a query name followed by a record type (A, AAAA, TXT, MX, NS, CNAME, SOA).
+Reply ONLY with the ANSWER SECTION lines in zone-file format: name, TTL, class, type and record data.
+Use plausible TTLs and record data, stay consistent with previous answers, never add explanations.
+If the name does not exist, reply exactly: "status: NXDOMAIN".`
+
+	systemPromptVirtualizeSIPServer = `
+You are an Asterisk 18 PBX reachable over SIP/2.0 (RFC 3261).
+The user sends raw SIP requests such as REGISTER, INVITE, OPTIONS or BYE.
+Reply ONLY with the raw SIP response message: status line, then headers (Via, From, To, Call-ID, CSeq, Server, Content-Length and, when challenging, WWW-Authenticate), then an empty line.
+Copy Via, From, Call-ID and CSeq from the request, add a To tag, use realistic status codes (100 Trying, 401 Unauthorized, 200 OK, 404 Not Found).
+Never add explanations.`
+
+	systemPromptVirtualizeRDPServer = `
+You are the RDP listener (TCP 3389) of a Windows Server 2019 host that has not been patched recently.
+The user sends the first messages of an RDP connection: X.224 Connection Requests, often with an mstshash cookie and requested protocols (RDP, SSL, HYBRID/NLA).
+Reply ONLY with a short textual description of the server handshake: the negotiation response with the selected protocol, then the TLS certificate subject and the NTLM target info (NetBIOS domain, computer name, DNS name, product version) a scanner would fingerprint.
+Stay consistent with previous answers, never add explanations.`
+
+	systemPromptVirtualizeVNCServer = `
+You are a VNC server (TCP 5900) running TigerVNC on a Linux desktop.
+The user sends the client side of the RFB handshake: protocol version, chosen security type, authentication responses.
+Reply ONLY with what the server sends back: the "RFB 003.008" version string first, then the list of security types, the VNC authentication challenge, the SecurityResult and, after a successful login, the ServerInit with framebuffer size and desktop name.
+Stay consistent with previous answers, never add explanations.`
+
+	systemPromptVirtualizeSNMPAgent = `
+You are the SNMPv2c agent of a Cisco router running IOS 15, community "public".
+The user sends GET, GETNEXT or WALK requests followed by an OID.
+Reply ONLY with OID = TYPE: value lines like snmpget/snmpwalk print them, one per line (STRING, INTEGER, Timeticks, OID, Counter32, Gauge32, IpAddress).
+For WALK return every OID under the requested subtree in order; cover the system group, ifTable and ipAddrTable with plausible values.
+If the OID does not exist reply exactly: "No Such Object available on this agent at this OID". Never add explanations.`
+
+	systemPromptVirtualizePostgresServer = `
+You are a PostgreSQL 16 server queried through psql by the user "postgres" on database "app".
+The user sends SQL statements or psql meta-commands (\l, \dt, \du).
+Reply ONLY with what psql prints: aligned tables with a header, a dashed separator and a "(N rows)" footer, command tags like INSERT 0 1 or CREATE TABLE, or errors formatted as
+ERROR:  <message>
+with the real SQLSTATE code appended as (SQLSTATE <code>), e.g. 42P01 for an unknown relation, 42601 for syntax errors, 42501 for missing privileges.
+Keep the schema consistent across queries; it holds users, orders and payments tables with plausible data. Never add explanations.`
+
+	systemPromptVirtualizeIMAPServer = `
+You are the Dovecot IMAP4rev1 server of the company mail host mail.corp.local.
+The client sends tagged IMAP commands (LOGIN, CAPABILITY, LIST, SELECT, EXAMINE, SEARCH, FETCH, STORE, LOGOUT).
+Reply ONLY with the raw server lines: untagged data prefixed with "* ", then the completion line with the client tag, e.g. "A001 OK LOGIN completed", "A002 NO [AUTHENTICATIONFAILED] Authentication failed." or "A003 BAD Error in IMAP command".
+Accept any LOGIN. Invent a plausible mailbox (INBOX, Sent, Drafts, Trash, Archive) with realistic business emails, UIDs, flags and sizes, and keep it consistent across commands. FETCH returns literal bodies with the exact {size} prefix. Never add explanations.`
+
+	systemPromptVirtualizePOP3Server = `
+You are the Dovecot POP3 server of the company mail host mail.corp.local.
+The client sends POP3 commands (USER, PASS, STAT, LIST, UIDL, TOP, RETR, DELE, QUIT).
+Reply ONLY with the raw server response: a "+OK" or "-ERR" status line, followed for multi-line answers by the data and a line with a single ".".
+Accept any USER and PASS. Invent a plausible maildrop of realistic business emails with consistent message numbers, octet sizes and UIDs across commands. Never add explanations.`
+
+	systemPromptVirtualizeLDAPServer = `
+You are the OpenLDAP 2.5 directory server of the company domain corp.local, base DN dc=corp,dc=local.
+The client sends LDAP operations written as text: bind requests with a DN and password, search requests with base, scope, filter and attributes, and unbind.
+Reply ONLY with the result in LDIF, like ldapsearch prints it: one "dn:" block per entry with its attributes, then a "# result:" line with the LDAP result code and name, e.g. "0 success", "32 noSuchObject", "49 invalidCredentials" or "50 insufficientAccessRights".
+Anonymous binds may read the rootDSE only, any other bind succeeds for cn=admin,dc=corp,dc=local and for existing users. Invent a plausible directory (ou=people, ou=groups, ou=services) with realistic users, groups, mail addresses and uid numbers, and keep it consistent across requests. Never add explanations.`
+
+	// typoToleranceHint is appended to the SSH prompt by TypoToleranceHint
+	typoToleranceHint = `
+Behave like a real shell with imperfect users: a misspelled command is not found, even when its intent is obvious, a wrong option prints the real usage error of the tool and a missing path prints "No such file or directory". Never correct or guess what the user meant.`
+
+	systemPromptSummarizeHistory = `
+You summarize a honeypot session transcript for later continuation.
+Write a short factual note of the state the simulated system is in: current directory, files and users created or modified, installed software, environment changes and any other detail later answers must stay consistent with.
+Reply only with the note.`
+
+	LLMPluginName = "LLMHoneypot"
+
+	defaultDeniedResponse   = "command not found"
+	defaultMaxResponseBytes = 64 * 1024
+	dryRunResponse          = "[dry run] no model was called"
+	// terminalClearSequence moves the cursor home and erases the screen, like clear(1)
+	terminalClearSequence = "\033[H\033[2J"
+	// maxContinuations bounds the follow-up requests of AutoContinue
+	maxContinuations   = 3
+	continuationPrompt = "Continue exactly where your previous answer stopped. Do not repeat anything, do not add any comment."
+
 	openAIEndpoint = "https://api.openai.com/v1/chat/completions"
 	ollamaEndpoint = "http://localhost:11434/api/chat"
-	geminiEndpoint = "https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent"
+	geminiEndpoint = "https://generativelanguage.googleapis.com" + geminiPath
+	geminiPath     = "/v1beta/models/%s:generateContent"
+	vertexEndpoint = "https://%[1]s-aiplatform.googleapis.com/v1/projects/%[2]s/locations/%[1]s/publishers/google/models/%[3]s:generateContent"
+	cohereEndpoint = "https://api.cohere.com/v1/chat"
 )
 
+const (
+	rdpSeedConnectionRequest = "X.224 Connection Request: Cookie: mstshash=Administrator; requestedProtocols=PROTOCOL_SSL|PROTOCOL_HYBRID"
+	rdpSeedConnectionConfirm = "X.224 Connection Confirm: RDP_NEG_RSP selectedProtocol=PROTOCOL_HYBRID\n" +
+		"TLS certificate: CN=WIN-SRV01.corp.local\n" +
+		"NTLM target info: NetBIOS domain CORP, computer WIN-SRV01, DNS name WIN-SRV01.corp.local, product version 10.0.17763"
+
+	postgresSeedVersion = "                                                       version\n" +
+		"---------------------------------------------------------------------------------------------------------------------\n" +
+		" PostgreSQL 16.2 (Ubuntu 16.2-1.pgdg22.04+1) on x86_64-pc-linux-gnu, compiled by gcc (Ubuntu 11.4.0-1ubuntu1~22.04) 11.4.0, 64-bit\n" +
+		"(1 row)"
+
+	imapSeedCapability = "* CAPABILITY IMAP4rev1 SASL-IR LOGIN-REFERRALS ID ENABLE IDLE LITERAL+ AUTH=PLAIN\r\n" +
+		"A001 OK Pre-login capabilities listed, post-login capabilities have more."
+
+	pop3SeedCapability = "+OK\r\nCAPA\r\nTOP\r\nUIDL\r\nRESP-CODES\r\nPIPELINING\r\nAUTH-RESP-CODE\r\nUSER\r\nSASL PLAIN\r\n."
+
+	ldapSeedRootDSEQuery = "search base=\"\" scope=base filter=(objectClass=*) attributes=namingContexts supportedLDAPVersion supportedSASLMechanisms"
+	ldapSeedRootDSE      = "dn:\n" +
+		"namingContexts: dc=corp,dc=local\n" +
+		"supportedLDAPVersion: 3\n" +
+		"supportedSASLMechanisms: SCRAM-SHA-256\n" +
+		"supportedSASLMechanisms: EXTERNAL\n" +
+		"\n" +
+		"# result: 0 success"
+
+	// websocketSeedUpgrade uses the sample key of RFC 6455, so the accept value is the known one
+	websocketSeedUpgrade = "GET /ws HTTP/1.1\r\n" +
+		"Host: app.corp.local\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	websocketSeedSwitching = "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Server: nginx/1.24.0\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n"
+
+	gitSeedInfoRefs = "GET /acme/backend.git/info/refs?service=git-upload-pack HTTP/1.1\r\n" +
+		"Host: git.corp.local\r\n" +
+		"User-Agent: git/2.43.0\r\n" +
+		"Git-Protocol: version=0\r\n"
+	gitSeedRefAdvertisement = "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/x-git-upload-pack-advertisement\r\n" +
+		"Cache-Control: no-cache, max-age=0, must-revalidate\r\n" +
+		"\r\n" +
+		"001e# service=git-upload-pack\n" +
+		"0000" +
+		"010b8d3f2a6c1e9b47f0a2c5d8e1b4f7a0c3d6e9f2a5 HEAD\x00multi_ack thin-pack side-band side-band-64k ofs-delta shallow " +
+		"deepen-since deepen-not deepen-relative no-progress include-tag multi_ack_detailed no-done " +
+		"symref=HEAD:refs/heads/main object-format=sha1 agent=git/2.43.0\n" +
+		"003d8d3f2a6c1e9b47f0a2c5d8e1b4f7a0c3d6e9f2a5 refs/heads/main\n" +
+		"00401b7e4c9a2f5d8b0e3a6c9f2d5b8e1a4c7f0d3b6e refs/heads/develop\n" +
+		"003e8d3f2a6c1e9b47f0a2c5d8e1b4f7a0c3d6e9f2a5 refs/tags/v2.3.1\n" +
+		"0000"
+
+	snmpSeedSysDescr = "iso.3.6.1.2.1.1.1.0 = STRING: \"Cisco IOS Software, C2900 Software (C2900-UNIVERSALK9-M), Version 15.1(4)M4, RELEASE SOFTWARE (fc1)\""
+
+	sipSeedRegister = "REGISTER sip:pbx.local SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 10.0.0.5:5060;branch=z9hG4bK776asdhds\r\n" +
+		"From: <sip:100@pbx.local>;tag=1928301774\r\n" +
+		"To: <sip:100@pbx.local>\r\n" +
+		"Call-ID: a84b4c76e66710@10.0.0.5\r\n" +
+		"CSeq: 1 REGISTER\r\n" +
+		"Contact: <sip:100@10.0.0.5:5060>\r\n" +
+		"Content-Length: 0\r\n\r\n"
+	sipSeedUnauthorized = "SIP/2.0 401 Unauthorized\r\n" +
+		"Via: SIP/2.0/UDP 10.0.0.5:5060;branch=z9hG4bK776asdhds;received=10.0.0.5\r\n" +
+		"From: <sip:100@pbx.local>;tag=1928301774\r\n" +
+		"To: <sip:100@pbx.local>;tag=as5f1c3e2a\r\n" +
+		"Call-ID: a84b4c76e66710@10.0.0.5\r\n" +
+		"CSeq: 1 REGISTER\r\n" +
+		"Server: Asterisk PBX 18.20.0\r\n" +
+		"WWW-Authenticate: Digest algorithm=MD5, realm=\"asterisk\", nonce=\"5f3a9b1c\"\r\n" +
+		"Content-Length: 0\r\n\r\n"
+)
+
+// defaultSSHStopSequences stops the model before it invents the next prompt line
+var defaultSSHStopSequences = []string{"\nuser@", "\nroot@"}
+
+var breakCharacterIncidents atomic.Uint64
+
+var _ = promauto.NewCounterFunc(prometheus.CounterOpts{
+	Namespace: "beelzebub",
+	Name:      "llm_break_character_total",
+	Help:      "The total number of LLM responses replaced because the model broke character",
+}, func() float64 { return float64(breakCharacterIncidents.Load()) })
+
 // -----------------------------------------------------------------------------
 // Structs & types
 // -----------------------------------------------------------------------------
@@ -36,20 +236,387 @@ type LLMHoneypot struct {
 	Histories    []Message
 	OpenAIKey    string
 	GoogleAPIKey string
+	CohereKey    string
+	// VertexAI sends Gemini requests to the Vertex AI endpoint of GCPProject in
+	// GCPRegion, authenticated with GCPTokenSource or, when nil, with the service
+	// account in GOOGLE_APPLICATION_CREDENTIALS
+	VertexAI       bool
+	GCPProject     string
+	GCPRegion      string
+	GCPTokenSource TokenSource
+	// GeminiSafetySettings is sent as safetySettings, so that security research
+	// prompts are not dropped by the default Gemini filters
+	GeminiSafetySettings []GeminiSafetySetting
+	// CachePrompt stores the system prompt as Gemini cached content, billed at
+	// a discount on every later request. Anthropic cache_control breakpoints belong here
+	// once that provider exists
+	CachePrompt bool
+	// CandidateCount asks Gemini for several answers, CandidateSelection picks the one returned
+	CandidateCount     int
+	CandidateSelection CandidateSelection
+	// AutoContinue asks Gemini for the rest of an answer cut at maxOutputTokens
+	// (finishReason MAX_TOKENS), up to maxContinuations follow-up requests
+	AutoContinue bool
+	// ThinkingBudget caps the thinking tokens of Gemini 2.5 and later, 0 disables
+	// thinking and -1 lets the model decide. Nil omits it, as do older models
+	ThinkingBudget *int
+	// OpenAI-compatible gateway (OpenRouter, Together, LocalAI...). The key is sent
+	// as a bearer token unless CompatibleAuthHeader names a custom header
+	CompatibleBaseURL    string
+	CompatibleKey        string
+	CompatibleAuthHeader string
+	// ExtraHeaders are sent with every provider request, e.g. OpenAI-Organization
+	// and OpenAI-Project; see HeaderOpenAIOrganization for the providers honoring them
+	ExtraHeaders map[string]string
+	// ExecCommand is the binary and arguments of the Exec provider, the prompt is
+	// written to its stdin and the completion read from its stdout
+	ExecCommand  []string
 	client       *resty.Client
+	historyMu    *sync.Mutex
 	Protocol     tracer.Protocol
 	Provider     LLMProvider
 	Model        string
-	Host         string
+	Host         string // chat endpoint of OpenAI and Ollama, base URL of the Gemini API
 	CustomPrompt string
+	// Persona picks the prompt and seeds by "protocol/variant" key, e.g. "ssh/alpine"
+	// or "http/nginx", from Personas or DefaultPersonas. Empty uses the protocol default.
+	// CustomPrompt still replaces the prompt of the persona
+	Persona  string
+	Personas *PersonaRegistry
+	// Router picks the provider and model of each command, e.g. HeuristicRouter to send
+	// simple commands to a cheap model. Nil always uses Provider and Model
+	Router func(command string) (LLMProvider, string)
+	// ModelFallbacks are tried in order when the provider answers that the model
+	// does not exist, e.g. a model that is not pulled on Ollama or was deprecated
+	ModelFallbacks []string
+	// ModelPrices overrides the default price table of EstimateCost, keyed by model name
+	ModelPrices map[string]ModelPrice
+	// PromptVariables are rendered into prompts written as text/template
+	PromptVariables PromptVariables
+	// Locale (e.g. "de_DE", "ja_JP") asks for localized human-readable content, dates,
+	// file names and messages, while commands and protocol syntax stay unchanged
+	Locale string
+	// Timeout bounds every provider HTTP request, zero means no timeout
+	Timeout time.Duration
+	// Connection pool of the provider transport, zero picks the defaults which are
+	// higher than net/http's so that sustained scans reuse TLS connections
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// RestyClient, or HTTPClient wrapped in resty, replaces the client built by
+	// NewLLMHoneypot, e.g. for OpenTelemetry transports or a shared connection pool.
+	// It is used as is: Timeout, the pool and the TLS options are not applied to it
+	RestyClient *resty.Client
+	HTTPClient  *http.Client
+	// KeyProvider resolves the API keys before every request, the key fields
+	// above are used when it is nil or returns an empty key
+	KeyProvider KeyProvider
+	// EnvPolicy decides how environment variables combine with the fields above
+	EnvPolicy EnvPolicy
 
-	// Tunables (dùng cho OpenAI)
+	// Tunables (dùng cho OpenAI). Zero means the default, use ExplicitZero for 0
 	Temperature float32
 	TopP        float32
+	TopK        int
+	// MaxTokens bounds the completion of OpenAI and compatible gateways, sent as
+	// max_completion_tokens to reasoning models. Zero leaves the provider default
+	MaxTokens int
+	// StopSequences ends generation early, e.g. at the next fake shell prompt
+	StopSequences []string
+	// SSHPromptRegex matches a trailing fake prompt line to strip from SSH output
+	SSHPromptRegex string
+	// EchoCommand prefixes SSH and TCP answers with the prompt and the command line,
+	// for front-ends that do not echo input. EchoPrompt defaults to user@host:~$
+	EchoCommand bool
+	EchoPrompt  string
+	// StripANSI and SanitizeControl clean SSH and TCP output of escape sequences
+	// and control characters, either one also replaces invalid UTF-8
+	StripANSI       bool
+	SanitizeControl bool
+	// Seed makes sampling reproducible: "seed" for OpenAI, options.seed for Ollama. Nil omits it
+	Seed *int
+	// LogitBias is sent as logit_bias to OpenAI and compatible gateways, token ID to
+	// bias between -100 and 100. LogitBiasForWords builds one that bans giveaway words
+	LogitBias map[string]float64
+	// KeepAlive keeps the Ollama model loaded between requests (e.g. "10m"), OllamaOptions
+	// is merged into the request options (num_ctx, num_gpu...) and wins over the fields above
+	KeepAlive     string
+	OllamaOptions map[string]interface{}
+
+	// JSONMode asks the provider for a JSON object and rejects non-JSON output
+	JSONMode bool
+	// Stateless sends every command as a single-shot prompt, history is neither read nor written
+	Stateless bool
+	// CanaryCommand and CanaryPattern override the probe of VerifyPersona
+	CanaryCommand string
+	CanaryPattern string
+	// MergeConsecutiveRoles joins consecutive messages of the same role before sending,
+	// for gateways that require alternating turns. Gemini and Cohere always merge
+	MergeConsecutiveRoles bool
+	// Environment makes the SSH persona a plain host, a Docker or a Kubernetes container:
+	// the prompt says so and /proc/1/cgroup, /.dockerenv and the service account files
+	// are answered consistently without the model
+	Environment RuntimeEnvironment
+	// WrapMultilineInput frames SSH commands spanning several lines (heredocs, pasted
+	// scripts) as a single input, so that the model does not answer them line by
+	// line. Line ends are normalized to LF either way
+	WrapMultilineInput bool
+	// FileOverrides maps file paths to the exact content returned for cat, less,
+	// more, head and tail over SSH without calling the model, e.g. to plant honeytokens
+	// in /etc/shadow or ~/.aws/credentials. ~ is the home of PromptVariables.Username
+	FileOverrides map[string]string
+	// Honeytokens maps names to canary values substituted after generation for the
+	// {{TOKEN:name}} placeholders of the prompt, seeds and FileOverrides, so that the
+	// model never has to reproduce a long key verbatim. History keeps the placeholders
+	Honeytokens map[string]string
+	// PrivacyFilter replaces the email addresses, card numbers and private IPs of the
+	// attacker input with salted hash tokens before the prompt leaves the host, and
+	// puts the real values back where the model echoes the tokens. PrivacyDetector
+	// replaces DetectPII
+	PrivacyFilter   bool
+	PrivacyDetector PIIDetector
+	// DryRun builds and logs the prompt but never calls the provider, ExecuteModel
+	// answers with FallbackResponse or a placeholder
+	DryRun bool
+	// FallbackResponse is returned instead of any error, e.g. "Segmentation fault"
+	// for SSH or a 502 page for HTTP; the error is only logged
+	FallbackResponse string
+	// BreakerFailures consecutive provider errors within BreakerWindow (default 1m)
+	// open the circuit of the provider for BreakerCooldown (default 30s): calls then
+	// return BreakerFallback, or ErrCircuitOpen when it is empty. Zero disables it
+	BreakerFailures int
+	BreakerWindow   time.Duration
+	BreakerCooldown time.Duration
+	BreakerFallback string
+	// StorePartialOutput keeps in history what a stream cancelled by ctx had already
+	// sent, after the same filters as a complete answer
+	StorePartialOutput bool
+	// MaxResponseBytes caps the model output, larger answers are truncated (see
+	// safeTruncate, JSON that cannot be cut fails) or, with RejectOversizedResponse,
+	// fail with ErrResponseTooLarge. Zero means 64KB, negative no limit
+	MaxResponseBytes        int
+	RejectOversizedResponse bool
+	// Latency pads ExecuteModel to a random duration, nil disables it
+	Latency *LatencyProfile
+	// OutputRate paces the SSH chunks of ExecuteModelStream at that many characters
+	// per second, one character at a time or one line at a time with OutputPerLine
+	OutputRate    int
+	OutputPerLine bool
+	// RateLimit (requests per second) and Burst size the token bucket shared by
+	// every honeypot using the same provider, zero disables it. RateLimitWait
+	// blocks until a token is free or ctx ends instead of failing with ErrRateLimited
+	RateLimit     float64
+	Burst         int
+	RateLimitWait bool
+	// MaxConcurrent bounds the provider calls in flight across the process, excess
+	// calls wait for a slot with MaxConcurrentWait or fail with ErrTooManyRequests
+	MaxConcurrent     int
+	MaxConcurrentWait bool
+	// MaxRetries calls a failed provider again with exponential backoff, streamed
+	// calls are never retried. RetryBudgetPerMinute caps the retries of the whole
+	// session, once spent failures go straight to FallbackResponse. Zero means no cap
+	MaxRetries           int
+	RetryBudgetPerMinute int
+	retryLimiter         *rate.Limiter
+
+	// SummarizeHistory folds the oldest turns into HistorySummary once Histories
+	// grows beyond SummarizeThreshold messages, SummaryModel defaults to Model
+	SummarizeHistory   bool
+	SummarizeThreshold int
+	SummaryModel       string
+	HistorySummary     string
+
+	// MaxContextTokens bounds the prompt size by trimming the oldest history,
+	// counted with Tokenizer (HeuristicTokenizer when nil). Zero disables it
+	MaxContextTokens int
+	Tokenizer        Tokenizer
+
+	// HTTPRequest, when set for the HTTP protocol, is sent to the model instead of the bare command
+	HTTPRequest *HTTPRequestContext
+	// WebSocket turns the HTTP persona into a WebSocket endpoint: the first command is
+	// the upgrade request, the next ones are client frame payloads. HTTPRequest is ignored
+	WebSocket bool
+	// GitSmartHTTP turns the HTTP persona into a git server answering clones with pkt-lines
+	GitSmartHTTP bool
+	// BatchWindow groups the Stateless HTTP requests arriving within the window that
+	// share a prompt into one provider call, flushed early at BatchSize requests.
+	// Zero disables it
+	BatchWindow time.Duration
+	BatchSize   int
+	// PreSendValidator sees every OpenAI, compatible and Ollama request right before
+	// the POST, GeminiPreSendValidator every Gemini one: an error aborts the call and
+	// is returned as is, e.g. to enforce a message or token limit on outbound prompts
+	PreSendValidator       func(Request) error
+	GeminiPreSendValidator func(GeminiRequest) error
+
+	// Sink receives every interaction, e.g. to forward it to Kafka
+	Sink InteractionSink
+	// Tracer receives a trace event for every interaction, failed ones included.
+	// SessionID and SourceIP link the event to the one the strategy traced
+	Tracer    tracer.Tracer
+	SessionID string
+	SourceIP  string
+	// TracerProvider opens an OpenTelemetry span per interaction and propagates it
+	// to the provider request, nil disables it
+	TracerProvider trace.TracerProvider
+	// EventBuffer is the capacity of the Events channel (100 by default), EventPolicy
+	// decides whether a full channel drops interactions or blocks the call
+	EventBuffer int
+	EventPolicy EventPolicy
+	events      chan Interaction
+	// ReplayFallthrough sends the commands that do not match the recording of
+	// ReplayFrom to the live model instead of failing with ErrReplayMismatch
+	ReplayFallthrough bool
+	recorder          *sessionRecorder
+	replay            *sessionReplay
+	lastRaw           *rawResponse
+
+	// State tracks invented processes, files and env vars, nil disables it
+	State *SessionState
+	// SeedMessages replaces the built-in few-shot examples that follow the system prompt
+	SeedMessages []Message
+	// SeedAsContext folds the seeds into the system prompt as examples instead of
+	// sending them as user and assistant turns, for small models they confuse
+	SeedAsContext bool
+
+	// Identity is the host the session sees, set by NewSession
+	Identity *MachineIdentity
+	// Client opts in to telling the model who is connected, nil keeps the attacker anonymous
+	Client *ClientContext
+	// Scenario is the world state shared with the other protocols, see AttachScenario
+	Scenario *Scenario
+
+	// TLS settings for self-hosted endpoints: client certificate for mutual TLS,
+	// extra CA bundle, and certificate verification skip for dev servers only
+	TLSClientCert      string
+	TLSClientKey       string
+	TLSCACert          string
+	InsecureSkipVerify bool
+
+	// Command filter, checked before the LLM is queried. DenyCommands and
+	// AllowCommands match the full command or its first word, DenyPatterns
+	// are regular expressions. A non-empty AllowCommands denies everything else.
+	DenyCommands   []string
+	DenyPatterns   []string
+	AllowCommands  []string
+	DeniedResponse string
+	// CommandNotFoundResponse is the shell error for unknown commands, %s being the
+	// command name, e.g. "bash: %s: command not found" or BusyBox "sh: %s: not found".
+	// It replaces "command not found" in the default SSH prompt and fallbacks
+	CommandNotFoundResponse string
+	// TypoToleranceHint tells the SSH persona to fail on typos like a real shell
+	// instead of guessing what the attacker meant
+	TypoToleranceHint bool
+
+	// Banner is shown on connect by Greeting, GenerateBanner lets the model write
+	// it once when Banner is empty
+	Banner         string
+	GenerateBanner bool
+
+	// HandleControlCommands answers exit/logout and clear locally for SSH:
+	// exit/logout call OnExit, clear returns the terminal clear sequence
+	HandleControlCommands bool
+	OnExit                func(command string)
+	// SkipBlankInput answers empty or whitespace-only commands without calling the
+	// model: BlankInputResponse, or what the protocol answers to an empty line
+	SkipBlankInput     bool
+	BlankInputResponse string
+}
+
+// Interaction is one attacker command and what the honeypot answered
+type Interaction struct {
+	// RequestID correlates the interaction with logs and the X-Request-Id sent to the provider
+	RequestID string
+	SessionID string
+	SourceIP  string
+	Timestamp time.Time
+	Protocol  tracer.Protocol
+	Provider  LLMProvider
+	Model     string
+	Command   string
+	Response  string
+	Usage     Usage
+	// Latency is the time spent building the prompt and waiting for the provider,
+	// before any LatencyProfile padding
+	Latency time.Duration
+	// CostUSD is Usage priced by EstimateCost
+	CostUSD float64
+	Err     error
+}
+
+// traceEvent is the interaction as an event of the tracer pipeline, failures included.
+// Its ID is the session of the strategy event it details, the request id without one
+func (i Interaction) traceEvent() tracer.Event {
+	id := i.SessionID
+	if id == "" {
+		id = i.RequestID
+	}
+	event := tracer.Event{
+		Msg:           "LLM interaction",
+		Protocol:      i.Protocol.String(),
+		Status:        tracer.LLMInteraction.String(),
+		ID:            id,
+		SourceIp:      i.SourceIP,
+		Command:       i.Command,
+		CommandOutput: i.Response,
+		LLMProvider:   i.Provider.String(),
+		LLMModel:      i.Model,
+	}
+	if i.Err != nil {
+		event.Msg = "LLM interaction failed"
+		event.Error = i.Err.Error()
+	}
+	return event
+}
+
+// InteractionSink is the hook used to ship interactions to an external system
+type InteractionSink interface {
+	Emit(ctx context.Context, interaction Interaction) error
+}
+
+// LatencyProfile is the range of total response times a real service would exhibit
+type LatencyProfile struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// HTTPRequestContext is the attacker request as the HTTP persona sees it
+type HTTPRequestContext struct {
+	Method  string
+	Path    string
+	Headers map[string][]string
+	Body    string
+}
+
+// maxPromptHTTPBody caps how much of an attacker body ends up in the prompt
+const maxPromptHTTPBody = 4096
+
+// String renders the request in HTTP/1.1 wire format, headers sorted by name
+func (r HTTPRequestContext) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\n", r.Method, r.Path)
+
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range r.Headers[name] {
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+
+	if r.Body != "" {
+		b.WriteString("\n" + truncateUTF8(r.Body, maxPromptHTTPBody))
+	}
+	return strings.TrimRight(b.String(), "\n")
 }
 
 type Choice struct {
-	Message      Message `json:"message"`
+	Message Message `json:"message"`
+	// Delta carries the next piece of text in streamed responses
+	Delta        Message `json:"delta"`
 	Index        int     `json:"index"`
 	FinishReason string  `json:"finish_reason"`
 }
@@ -61,24 +628,69 @@ type Response struct {
 	Model   string   `json:"model"`
 	Choices []Choice `json:"choices"`
 	Message Message  `json:"message"`
-	Usage   struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage   Usage    `json:"usage"`
+	// Ollama reports token counts at the top level, Done marks the last streamed line
+	PromptEvalCount int  `json:"prompt_eval_count"`
+	EvalCount       int  `json:"eval_count"`
+	Done            bool `json:"done"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type Request struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Stream      bool      `json:"stream"`
-	Temperature float32   `json:"temperature,omitempty"`
-	TopP        float32   `json:"top_p,omitempty"`
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Stream         bool            `json:"stream"`
+	Temperature    *float32        `json:"temperature,omitempty"`
+	TopP           *float32        `json:"top_p,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Format         string          `json:"format,omitempty"`
+	// Options and KeepAlive are only understood by Ollama
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Stop      []string               `json:"stop,omitempty"`
+	Seed      *int                   `json:"seed,omitempty"`
+	LogitBias map[string]float64     `json:"logit_bias,omitempty"`
+	// MaxTokens is max_tokens for chat models, reasoning models only accept MaxCompletionTokens
+	MaxTokens           int `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+	// Tools are the functions the model may call, OpenAI and compatible gateways only
+	Tools []Tool `json:"tools,omitempty"`
+}
+
+type ResponseFormat struct {
+	Type string `json:"type"`
 }
 
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls are the functions an assistant message asks to run, ToolCallID
+	// links a tool message to the call it answers
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// Images are raw image files, marshalled as the base64 "images" array Ollama expects
+	Images [][]byte `json:"images,omitempty"`
+}
+
+// openAIVisionMessage is a Message in the OpenAI content-parts format
+type openAIVisionMessage struct {
+	Role    string              `json:"role"`
+	Content []openAIContentPart `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
 }
 
 type Role int
@@ -87,20 +699,62 @@ const (
 	SYSTEM Role = iota
 	USER
 	ASSISTANT
+	TOOL
 )
 
 func (role Role) String() string {
-	return [...]string{"system", "user", "assistant"}[role]
+	return [...]string{"system", "user", "assistant", "tool"}[role]
 }
 
+// ExplicitZero sets Temperature or TopP to 0, the zero value selects the default instead
+const ExplicitZero float32 = -1
+
+// EnvPolicy is the precedence between environment variables and explicit configuration.
+// The variables are LLM_PROVIDER, LLM_MODEL, LLM_TEMPERATURE, LLM_TOP_P, LLM_TOP_K and the provider keys.
+type EnvPolicy int
+
+const (
+	// EnvPolicyDefault lets the constructor decide: InitLLMHoneypot overrides, NewLLMHoneypot ignores
+	EnvPolicyDefault EnvPolicy = iota
+	// EnvOverride replaces configured values with the environment ones
+	EnvOverride
+	// EnvFillEmpty only sets fields left at their zero value (Ollama counts as the zero Provider)
+	EnvFillEmpty
+	// EnvIgnore never reads the environment
+	EnvIgnore
+)
+
 type LLMProvider int
 
 const (
 	Ollama LLMProvider = iota
 	OpenAI
 	Gemini
+	Cohere
+	Compatible
+	// Exec runs a local binary such as llama-cli, for air-gapped deployments
+	Exec
 )
 
+func (provider LLMProvider) String() string {
+	switch provider {
+	case Ollama:
+		return "ollama"
+	case OpenAI:
+		return "openai"
+	case Gemini:
+		return "gemini"
+	case Cohere:
+		return "cohere"
+	case Compatible:
+		return "compatible"
+	case Exec:
+		return "exec"
+	default:
+		return fmt.Sprintf("LLMProvider(%d)", int(provider))
+	}
+}
+
 func FromStringToLLMProvider(llmProvider string) (LLMProvider, error) {
 	switch strings.ToLower(llmProvider) {
 	case "ollama":
@@ -109,8 +763,14 @@ func FromStringToLLMProvider(llmProvider string) (LLMProvider, error) {
 		return OpenAI, nil
 	case "gemini":
 		return Gemini, nil
+	case "cohere":
+		return Cohere, nil
+	case "compatible":
+		return Compatible, nil
+	case "exec":
+		return Exec, nil
 	default:
-		return -1, fmt.Errorf("provider %s not found, valid providers: ollama, openai, gemini", llmProvider)
+		return -1, fmt.Errorf("provider %s not found, valid providers: ollama, openai, gemini, cohere, compatible, exec", llmProvider)
 	}
 }
 
@@ -118,205 +778,762 @@ func FromStringToLLMProvider(llmProvider string) (LLMProvider, error) {
 // Init
 // -----------------------------------------------------------------------------
 
-func InitLLMHoneypot(config LLMHoneypot) *LLMHoneypot {
-	config.client = resty.New()
+// Option configures an LLMHoneypot built by NewLLMHoneypot
+type Option func(*LLMHoneypot)
+
+// WithConfig starts from a fully populated struct, later options override its fields
+func WithConfig(config LLMHoneypot) Option {
+	return func(llm *LLMHoneypot) { *llm = config }
+}
+
+func WithProvider(provider LLMProvider) Option {
+	return func(llm *LLMHoneypot) { llm.Provider = provider }
+}
+
+func WithModel(model string) Option {
+	return func(llm *LLMHoneypot) { llm.Model = model }
+}
+
+func WithProtocol(protocol tracer.Protocol) Option {
+	return func(llm *LLMHoneypot) { llm.Protocol = protocol }
+}
+
+func WithHost(host string) Option {
+	return func(llm *LLMHoneypot) { llm.Host = host }
+}
+
+func WithCustomPrompt(prompt string) Option {
+	return func(llm *LLMHoneypot) { llm.CustomPrompt = prompt }
+}
+
+func WithOpenAIKey(key string) Option {
+	return func(llm *LLMHoneypot) { llm.OpenAIKey = key }
+}
+
+func WithGoogleAPIKey(key string) Option {
+	return func(llm *LLMHoneypot) { llm.GoogleAPIKey = key }
+}
+
+func WithHistories(histories []Message) Option {
+	return func(llm *LLMHoneypot) { llm.Histories = histories }
+}
+
+// WithTimeout bounds every provider HTTP request
+func WithTimeout(timeout time.Duration) Option {
+	return func(llm *LLMHoneypot) { llm.Timeout = timeout }
+}
+
+// WithEnvOverrides lets LLM_* and provider key environment variables override the configured values
+func WithEnvOverrides(enabled bool) Option {
+	return func(llm *LLMHoneypot) {
+		llm.EnvPolicy = EnvIgnore
+		if enabled {
+			llm.EnvPolicy = EnvOverride
+		}
+	}
+}
+
+// WithRestyClient sends the provider requests through client
+func WithRestyClient(client *resty.Client) Option {
+	return func(llm *LLMHoneypot) { llm.RestyClient = client }
+}
+
+// WithHTTPClient sends the provider requests through client, wrapped in resty
+func WithHTTPClient(client *http.Client) Option {
+	return func(llm *LLMHoneypot) { llm.HTTPClient = client }
+}
+
+func WithEnvPolicy(policy EnvPolicy) Option {
+	return func(llm *LLMHoneypot) { llm.EnvPolicy = policy }
+}
+
+// NewLLMHoneypot builds a ready to use honeypot. Environment variables are
+// ignored unless an EnvPolicy says otherwise.
+func NewLLMHoneypot(opts ...Option) *LLMHoneypot {
+	llm := &LLMHoneypot{}
+	for _, opt := range opts {
+		opt(llm)
+	}
+	if llm.EnvPolicy == EnvPolicyDefault {
+		llm.EnvPolicy = EnvIgnore
+	}
+
+	llm.historyMu = &sync.Mutex{}
+	llm.client = llm.newClient()
 
 	// Optional debug
 	if os.Getenv("LLM_DEBUG") != "" {
 		log.SetLevel(log.DebugLevel)
 	}
 
-	// Đọc config từ biến môi trường (nếu có)
-	if v := os.Getenv("LLM_PROVIDER"); v != "" {
-		if p, err := FromStringToLLMProvider(v); err == nil {
-			config.Provider = p
+	llm.applyEnv()
+
+	// Mặc định an toàn
+	llm.normalizeSampling()
+	if llm.TopK == 0 {
+		llm.TopK = 40
+	}
+	if llm.SummarizeThreshold == 0 {
+		llm.SummarizeThreshold = 20
+	}
+	if llm.StopSequences == nil && llm.Protocol == tracer.SSH {
+		llm.StopSequences = defaultSSHStopSequences
+	}
+	if llm.MaxResponseBytes == 0 {
+		llm.MaxResponseBytes = defaultMaxResponseBytes
+	}
+
+	return llm
+}
+
+// InitLLMHoneypot keeps the original behaviour: unless config.EnvPolicy says
+// otherwise, environment variables override the struct fields
+func InitLLMHoneypot(config LLMHoneypot) *LLMHoneypot {
+	if config.EnvPolicy == EnvPolicyDefault {
+		config.EnvPolicy = EnvOverride
+	}
+	return NewLLMHoneypot(WithConfig(config))
+}
+
+// InitLLMHoneypotStrict is InitLLMHoneypot followed by Validate, so that a
+// misconfiguration fails at startup instead of on the first attacker command
+func InitLLMHoneypotStrict(config LLMHoneypot) (*LLMHoneypot, error) {
+	llm := InitLLMHoneypot(config)
+	if err := llm.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s configuration: %w", LLMPluginName, err)
+	}
+	return llm, nil
+}
+
+// applyEnv đọc config từ biến môi trường (nếu có), following the EnvPolicy
+func (llm *LLMHoneypot) applyEnv() {
+	if llm.EnvPolicy == EnvIgnore {
+		return
+	}
+	fillOnly := llm.EnvPolicy == EnvFillEmpty
+
+	setString := func(dst *string, key string) {
+		if v := os.Getenv(key); v != "" && (!fillOnly || *dst == "") {
+			*dst = v
+		}
+	}
+	setFloat := func(dst *float32, key string) {
+		if v := os.Getenv(key); v != "" && (!fillOnly || *dst == 0) {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 32)
+			if err != nil {
+				log.Warnf("ignoring %s=%q: not a number", key, v)
+				return
+			}
+			if *dst = float32(f); *dst == 0 {
+				*dst = ExplicitZero
+			}
 		}
 	}
-	if v := os.Getenv("LLM_MODEL"); v != "" {
-		config.Model = v
+	setInt := func(dst *int, key string) {
+		if v := os.Getenv(key); v != "" && (!fillOnly || *dst == 0) {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				log.Warnf("ignoring %s=%q: not an integer", key, v)
+				return
+			}
+			*dst = n
+		}
 	}
-	if v := os.Getenv("GOOGLE_API_KEY"); v != "" {
-		config.GoogleAPIKey = v
+
+	if v := os.Getenv("LLM_PROVIDER"); v != "" && (!fillOnly || llm.Provider == Ollama) {
+		if p, err := FromStringToLLMProvider(v); err == nil {
+			llm.Provider = p
+		}
 	}
-	if v := os.Getenv("OPEN_AI_SECRET_KEY"); v != "" {
-		config.OpenAIKey = v
+	setString(&llm.Model, "LLM_MODEL")
+	setString(&llm.GoogleAPIKey, "GOOGLE_API_KEY")
+	setString(&llm.OpenAIKey, "OPEN_AI_SECRET_KEY")
+	setString(&llm.CohereKey, "COHERE_API_KEY")
+	setString(&llm.GCPProject, "GOOGLE_CLOUD_PROJECT")
+	setString(&llm.GCPRegion, "GOOGLE_CLOUD_LOCATION")
+	setString(&llm.CompatibleBaseURL, "LLM_COMPATIBLE_URL")
+	setString(&llm.CompatibleKey, "LLM_COMPATIBLE_API_KEY")
+	setString(&llm.CompatibleAuthHeader, "LLM_COMPATIBLE_AUTH_HEADER")
+	setFloat(&llm.Temperature, "LLM_TEMPERATURE")
+	setFloat(&llm.TopP, "LLM_TOP_P")
+	setInt(&llm.TopK, "LLM_TOP_K")
+	if v := os.Getenv("LLM_RATE_LIMIT"); v != "" && (!fillOnly || llm.RateLimit == 0) {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			log.Warnf("ignoring LLM_RATE_LIMIT=%q: not a number", v)
+		} else {
+			llm.RateLimit = f
+		}
 	}
-	if v := os.Getenv("LLM_TEMPERATURE"); v != "" {
-		fmt.Sscanf(v, "%f", &config.Temperature)
+	setInt(&llm.Burst, "LLM_RATE_BURST")
+}
+
+// normalizeSampling applies the defaults, turns ExplicitZero into 0 and clamps
+// Temperature to [0, 2] and TopP to [0, 1]
+func (llm *LLMHoneypot) normalizeSampling() {
+	llm.Temperature = normalizeSamplingValue("temperature", llm.Temperature, 0.2, 2)
+	llm.TopP = normalizeSamplingValue("top_p", llm.TopP, 1, 1)
+}
+
+func normalizeSamplingValue(name string, value, fallback, max float32) float32 {
+	switch {
+	case value == 0:
+		return fallback
+	case value == ExplicitZero:
+		return 0
+	case value < 0:
+		log.Warnf("%s %g is below 0, using 0", name, value)
+		return 0
+	case value > max:
+		log.Warnf("%s %g is above %g, using %g", name, value, max, max)
+		return max
 	}
-	if v := os.Getenv("LLM_TOP_P"); v != "" {
-		fmt.Sscanf(v, "%f", &config.TopP)
+	return value
+}
+
+// newClient returns the injected client or a new one configured with Timeout, TLS
+// and the shared connection pool
+func (llm *LLMHoneypot) newClient() *resty.Client {
+	switch {
+	case llm.RestyClient != nil:
+		return llm.RestyClient
+	case llm.HTTPClient != nil:
+		return resty.NewWithClient(llm.HTTPClient)
 	}
 
-	// Mặc định an toàn
-	if config.Temperature == 0 {
-		config.Temperature = 0.2
+	client := resty.NewWithClient(&http.Client{Transport: llm.transport()})
+	if llm.Timeout > 0 {
+		client.SetTimeout(llm.Timeout)
 	}
-	if config.TopP == 0 {
-		config.TopP = 1
+	return client
+}
+
+// tlsConfig returns nil when no TLS option is set, so the resty defaults are kept
+func (llm *LLMHoneypot) tlsConfig() (*tls.Config, error) {
+	if llm.TLSClientCert == "" && llm.TLSCACert == "" && !llm.InsecureSkipVerify {
+		return nil, nil
 	}
 
-	return &config
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: llm.InsecureSkipVerify,
+	}
+	if llm.TLSClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(llm.TLSClientCert, llm.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if llm.TLSCACert != "" {
+		pem, err := os.ReadFile(llm.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", llm.TLSCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
 }
 
 // -----------------------------------------------------------------------------
 // Prompt builder
 // -----------------------------------------------------------------------------
 
+// BuildPrompt returns the messages ExecuteModel would send for command (system
+// prompt, seeds, state, history and command) without calling the provider
+func (llm *LLMHoneypot) BuildPrompt(command string) ([]Message, error) {
+	return llm.buildPrompt(command)
+}
+
 func (llm *LLMHoneypot) buildPrompt(command string) ([]Message, error) {
-	var msgs []Message
-	var prompt string
+	msgs, err := llm.persona()
+	if err != nil {
+		return nil, err
+	}
 
-	switch llm.Protocol {
-	case tracer.SSH:
-		prompt = systemPromptVirtualizeLinuxTerminal
-		if llm.CustomPrompt != "" {
-			prompt = llm.CustomPrompt
-		}
-		msgs = append(msgs, Message{Role: SYSTEM.String(), Content: prompt})
-		// seed để model biết vị trí
-		msgs = append(msgs,
-			Message{Role: USER.String(), Content: "pwd"},
-			Message{Role: ASSISTANT.String(), Content: "/home/user"},
-		)
-	case tracer.HTTP:
-		prompt = systemPromptVirtualizeHTTPServer
-		if llm.CustomPrompt != "" {
-			prompt = llm.CustomPrompt
-		}
-		msgs = append(msgs, Message{Role: SYSTEM.String(), Content: prompt})
-		msgs = append(msgs,
-			Message{Role: USER.String(), Content: "GET /index.html"},
-			Message{Role: ASSISTANT.String(), Content: "<html><body>Hello, World!</body></html>"},
-		)
-	default:
-		return nil, errors.New("no prompt for protocol selected")
+	if llm.Identity != nil {
+		msgs = append(msgs, Message{Role: SYSTEM.String(), Content: llm.Identity.PromptContext()})
+	}
+	if llm.Client != nil {
+		if client := llm.Client.PromptContext(); client != "" {
+			msgs = append(msgs, Message{Role: SYSTEM.String(), Content: client})
+		}
+	}
+	if llm.State != nil {
+		if state := llm.State.PromptContext(); state != "" {
+			msgs = append(msgs, Message{Role: SYSTEM.String(), Content: state})
+		}
+	}
+	if llm.Scenario != nil {
+		if scenario := llm.Scenario.PromptContext(llm.Protocol); scenario != "" {
+			msgs = append(msgs, Message{Role: SYSTEM.String(), Content: scenario})
+		}
+	}
+
+	if llm.HistorySummary != "" && !llm.Stateless {
+		msgs = append(msgs, Message{Role: SYSTEM.String(), Content: "Summary of the session so far:\n" + llm.HistorySummary})
 	}
 
-	// replay history
-	msgs = append(msgs, llm.Histories...)
 	// current command
-	msgs = append(msgs, Message{Role: USER.String(), Content: command})
+	if llm.Protocol == tracer.HTTP && llm.HTTPRequest != nil && !llm.WebSocket {
+		command = llm.HTTPRequest.String()
+	}
+	if llm.Protocol == tracer.SSH {
+		command = llm.shellInput(command)
+	}
+	current := Message{Role: USER.String(), Content: command}
+
+	// replay history, the oldest turns are dropped when MaxContextTokens is exceeded
+	if !llm.Stateless {
+		msgs = append(msgs, llm.trimHistoryToBudget(append(msgs, current), llm.history())...)
+	}
+	msgs = append(msgs, current)
+
+	return msgs, nil
+}
+
+// persona is the fixed head of every prompt: the rendered system prompt and the seeds
+func (llm *LLMHoneypot) persona() ([]Message, error) {
+	key := llm.personaKey()
+	if key == "" {
+		return nil, errors.New("no prompt for protocol selected")
+	}
+	protocol, err := personaProtocol(key)
+	if err != nil {
+		return nil, err
+	}
+	if protocol != llm.Protocol {
+		return nil, fmt.Errorf("persona %s does not match the honeypot protocol", key)
+	}
+	registry := llm.Personas
+	if registry == nil {
+		registry = DefaultPersonas
+	}
+	persona, ok := registry.Lookup(key)
+	if !ok {
+		if llm.Persona == "" {
+			return nil, errors.New("no prompt for protocol selected")
+		}
+		return nil, fmt.Errorf("unknown persona %s", key)
+	}
+
+	prompt := persona.Prompt
+	if llm.Protocol == tracer.SSH && llm.CommandNotFoundResponse != "" {
+		phrasing := strings.ReplaceAll(llm.CommandNotFoundResponse, "%s", "<command>")
+		prompt = strings.Replace(prompt, `reply exactly: "command not found".`,
+			fmt.Sprintf("reply exactly: %q, with <command> replaced by the command name.", phrasing), 1)
+	}
+	if llm.CustomPrompt != "" {
+		prompt = llm.CustomPrompt
+	}
+	if llm.Protocol == tracer.SSH && llm.TypoToleranceHint {
+		prompt += typoToleranceHint
+	}
+	msgs := append([]Message{{Role: SYSTEM.String(), Content: prompt}}, persona.Seeds...)
+
+	if msgs[0].Content, err = llm.renderSystemPrompt(msgs[0].Content); err != nil {
+		return nil, err
+	}
+	msgs[0].Content += localeInstruction(llm.Locale)
+	if len(llm.Honeytokens) > 0 {
+		msgs[0].Content += honeytokenInstruction
+	}
+	if llm.Protocol == tracer.SSH {
+		msgs[0].Content += llm.environmentInstruction()
+	}
 
+	// seed đặt bởi operator thay thế các ví dụ mặc định
+	if len(llm.SeedMessages) > 0 {
+		msgs = append(msgs[:1], llm.SeedMessages...)
+	}
+	if llm.SeedAsContext {
+		msgs = foldSeeds(msgs)
+	}
 	return msgs, nil
 }
 
+// foldSeeds moves the seed turns into the system prompt as examples, for models
+// that only honor a system message followed by a single user message
+func foldSeeds(msgs []Message) []Message {
+	if len(msgs) < 2 {
+		return msgs
+	}
+	var b strings.Builder
+	b.WriteString(msgs[0].Content)
+	for _, m := range msgs[1:] {
+		switch m.Role {
+		case USER.String():
+			b.WriteString("\n\nExample input:\n")
+		case ASSISTANT.String():
+			b.WriteString("\nExample output:\n")
+		default:
+			b.WriteString("\n\n")
+		}
+		b.WriteString(m.Content)
+	}
+	return []Message{{Role: SYSTEM.String(), Content: b.String()}}
+}
+
 // -----------------------------------------------------------------------------
 // OpenAI caller
 // -----------------------------------------------------------------------------
 
-func (llm *LLMHoneypot) openAICaller(msgs []Message) (string, error) {
-	if llm.OpenAIKey == "" {
-		return "", errors.New("openAIKey is empty")
+func (llm *LLMHoneypot) openAICaller(ctx context.Context, msgs []Message) (string, Usage, error) {
+	url, auth, err := llm.openAITarget(ctx)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	return llm.chatCompletionsCaller(ctx, msgs, url, auth)
+}
+
+func (llm *LLMHoneypot) openAITarget(ctx context.Context) (string, func(*resty.Request), error) {
+	key, err := llm.apiKey(ctx, openAIKey)
+	if err != nil {
+		return "", nil, err
+	}
+	if key == "" {
+		return "", nil, errors.New("openAIKey is empty")
 	}
 	if llm.Host == "" {
 		llm.Host = openAIEndpoint
 	}
 
-	reqPayload := Request{
-		Model:       llm.Model,
-		Messages:    msgs,
-		Stream:      false,
-		Temperature: llm.Temperature,
-		TopP:        llm.TopP,
-	}
-	reqJSON, err := json.Marshal(reqPayload)
+	return llm.Host, func(req *resty.Request) {
+		req.SetAuthToken(key)
+	}, nil
+}
+
+// compatibleCaller talks to any gateway exposing the OpenAI chat completions API
+func (llm *LLMHoneypot) compatibleCaller(ctx context.Context, msgs []Message) (string, Usage, error) {
+	url, auth, err := llm.compatibleTarget(ctx)
 	if err != nil {
-		return "", err
+		return "", Usage{}, err
 	}
+	return llm.chatCompletionsCaller(ctx, msgs, url, auth)
+}
 
-	if log.IsLevelEnabled(log.DebugLevel) {
-		log.Debug(string(reqJSON))
+func (llm *LLMHoneypot) compatibleTarget(ctx context.Context) (string, func(*resty.Request), error) {
+	if llm.CompatibleBaseURL == "" {
+		return "", nil, errors.New("compatibleBaseURL is empty")
 	}
-
-	resp, err := llm.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(reqJSON).
-		SetAuthToken(llm.OpenAIKey).
-		SetResult(&Response{}).
-		Post(llm.Host)
+	key, err := llm.apiKey(ctx, compatibleKey)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	if len(resp.Result().(*Response).Choices) == 0 {
-		return "", errors.New("no choices returned from OpenAI")
+	url := strings.TrimSuffix(llm.CompatibleBaseURL, "/")
+	if !strings.HasSuffix(url, "/chat/completions") {
+		url += "/chat/completions"
 	}
 
-	return removeQuotes(resp.Result().(*Response).Choices[0].Message.Content), nil
+	return url, func(req *resty.Request) {
+		switch {
+		case key == "":
+		case llm.CompatibleAuthHeader == "":
+			req.SetAuthToken(key)
+		default:
+			req.SetHeader(llm.CompatibleAuthHeader, key)
+		}
+	}, nil
 }
 
-// -----------------------------------------------------------------------------
-// Ollama caller
-// -----------------------------------------------------------------------------
-
-func (llm *LLMHoneypot) ollamaCaller(msgs []Message) (string, error) {
-	if llm.Host == "" {
-		llm.Host = ollamaEndpoint
+func (llm *LLMHoneypot) chatCompletionsCaller(ctx context.Context, msgs []Message, url string, auth func(*resty.Request)) (string, Usage, error) {
+	message, usage, err := llm.chatCompletions(ctx, msgs, nil, url, auth)
+	if err != nil {
+		return "", usage, err
 	}
+	return removeQuotes(message.Content), usage, nil
+}
 
-	reqJSON, err := json.Marshal(Request{
-		Model:    llm.Model,
-		Messages: msgs,
-		Stream:   false,
-	})
+// chatCompletions returns the whole first choice, tool calls included
+func (llm *LLMHoneypot) chatCompletions(ctx context.Context, msgs []Message, tools []Tool, url string, auth func(*resty.Request)) (Message, Usage, error) {
+	reqJSON, err := llm.chatCompletionsPayload(msgs, tools, false)
 	if err != nil {
-		return "", err
+		return Message{}, Usage{}, err
 	}
 
-	if log.IsLevelEnabled(log.DebugLevel) {
-		log.Debug(string(reqJSON))
+	logPayload(ctx, reqJSON, msgs)
+	if err := llm.waitOpenAIRateLimit(ctx); err != nil {
+		return Message{}, Usage{}, err
 	}
 
-	resp, err := llm.client.R().
+	req := llm.newRequest(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(reqJSON).
-		SetResult(&Response{}).
-		Post(llm.Host)
+		SetResult(&Response{})
+	auth(req)
+	resp, err := req.Post(url)
 	if err != nil {
-		return "", err
+		return Message{}, Usage{}, err
+	}
+	llm.recordOpenAIRateLimit(resp.Header())
+	llm.keepRawResponse(resp.Body())
+	if err := modelNotFound(resp.StatusCode(), resp.String()); err != nil {
+		return Message{}, Usage{}, err
+	}
+	if resp.IsError() {
+		return Message{}, Usage{}, fmt.Errorf("chat completions request failed: %s – %s", resp.Status(), strings.TrimSpace(resp.String()))
 	}
 
-	return removeQuotes(resp.Result().(*Response).Message.Content), nil
-}
-
-// -----------------------------------------------------------------------------
-// Gemini structures & caller
-// -----------------------------------------------------------------------------
+	result := resp.Result().(*Response)
+	if len(result.Choices) == 0 {
+		return Message{}, Usage{}, errors.New("no choices")
+	}
 
-type GeminiRequest struct {
-	Contents         []GeminiContent  `json:"contents"`
-	GenerationConfig GenerationConfig `json:"generationConfig"`
+	return result.Choices[0].Message, result.Usage, nil
 }
 
-type GeminiContent struct {
-	Role  string       `json:"role"`
+func (llm *LLMHoneypot) chatCompletionsPayload(msgs []Message, tools []Tool, stream bool) ([]byte, error) {
+	reqPayload := Request{
+		Model:       llm.Model,
+		Messages:    msgs,
+		Tools:       tools,
+		Stream:      stream,
+		Temperature: &llm.Temperature,
+		TopP:        &llm.TopP,
+		Stop:        llm.StopSequences,
+		Seed:        llm.Seed,
+		LogitBias:   llm.LogitBias,
+	}
+	if isReasoningModel(llm.Model) {
+		// o1 and later reject sampling parameters with a 400
+		reqPayload.Temperature = nil
+		reqPayload.TopP = nil
+		reqPayload.LogitBias = nil
+		reqPayload.MaxCompletionTokens = llm.MaxTokens
+	} else {
+		reqPayload.MaxTokens = llm.MaxTokens
+	}
+	if llm.JSONMode {
+		reqPayload.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	}
+	if err := llm.validateRequest(reqPayload); err != nil {
+		return nil, err
+	}
+	var payload interface{} = reqPayload
+	if hasImages(msgs) {
+		// the outer messages field shadows the embedded one
+		payload = struct {
+			Request
+			Messages []openAIVisionMessage `json:"messages"`
+		}{reqPayload, toOpenAIVisionMessages(msgs)}
+	}
+	return json.Marshal(payload)
+}
+
+var reasoningModel = regexp.MustCompile(`^o\d+($|-)`)
+
+// isReasoningModel matches the OpenAI o-series (o1, o3-mini, o4-mini...), also
+// behind a gateway prefix like openai/o1
+func isReasoningModel(model string) bool {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return reasoningModel.MatchString(strings.ToLower(model))
+}
+
+// -----------------------------------------------------------------------------
+// Ollama caller
+// -----------------------------------------------------------------------------
+
+func (llm *LLMHoneypot) ollamaCaller(ctx context.Context, msgs []Message) (string, Usage, error) {
+	reqJSON, err := llm.ollamaPayload(msgs, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	logPayload(ctx, reqJSON, msgs)
+
+	resp, err := llm.newRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(reqJSON).
+		Post(llm.Host)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	llm.keepRawResponse(resp.Body())
+	if err := modelNotFound(resp.StatusCode(), resp.String()); err != nil {
+		return "", Usage{}, err
+	}
+	if resp.IsError() && hasImages(msgs) && ollamaNoVision.MatchString(resp.String()) {
+		// model text-only (llama3, mistral...): trả lời như thể không thấy file upload
+		log.Debugf("model %s does not accept images, sending the prompt without them", llm.Model)
+		return llm.ollamaCaller(ctx, withoutImages(msgs))
+	}
+	if resp.IsError() {
+		return "", Usage{}, fmt.Errorf("ollama request failed: %s – %s", resp.Status(), strings.TrimSpace(resp.String()))
+	}
+
+	var result Response
+	if err := json.Unmarshal(resp.Body(), &result); err == nil {
+		return removeQuotes(result.Message.Content), result.ollamaUsage(), nil
+	}
+
+	// một số bản Ollama vẫn trả NDJSON dù stream=false: ghép content của từng dòng
+	var content strings.Builder
+	usage, err := readNDJSON(bytes.NewReader(resp.Body()), func(chunk string) bool {
+		content.WriteString(chunk)
+		return true
+	})
+	if err != nil && content.Len() == 0 {
+		return "", Usage{}, fmt.Errorf("decoding Ollama response: %v", err)
+	}
+	return removeQuotes(content.String()), usage, nil
+}
+
+func (llm *LLMHoneypot) ollamaPayload(msgs []Message, stream bool) ([]byte, error) {
+	if llm.Host == "" {
+		llm.Host = ollamaEndpoint
+	}
+
+	reqPayload := Request{
+		Model:    llm.Model,
+		Messages: msgs,
+		Stream:   stream,
+		Options:  map[string]interface{}{},
+	}
+	if llm.TopK > 0 {
+		reqPayload.Options["top_k"] = llm.TopK
+	}
+	if len(llm.StopSequences) > 0 {
+		reqPayload.Options["stop"] = llm.StopSequences
+	}
+	if llm.Seed != nil {
+		reqPayload.Options["seed"] = *llm.Seed
+	}
+	for k, v := range llm.OllamaOptions {
+		reqPayload.Options[k] = v
+	}
+	if llm.JSONMode {
+		reqPayload.Format = "json"
+	}
+	reqPayload.KeepAlive = llm.KeepAlive
+	if err := llm.validateRequest(reqPayload); err != nil {
+		return nil, err
+	}
+	return json.Marshal(reqPayload)
+}
+
+func (result *Response) ollamaUsage() Usage {
+	return Usage{
+		PromptTokens:     result.PromptEvalCount,
+		CompletionTokens: result.EvalCount,
+		TotalTokens:      result.PromptEvalCount + result.EvalCount,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Gemini structures & caller
+// -----------------------------------------------------------------------------
+
+type GeminiRequest struct {
+	Contents         []GeminiContent       `json:"contents"`
+	CachedContent    string                `json:"cachedContent,omitempty"`
+	GenerationConfig GenerationConfig      `json:"generationConfig"`
+	SafetySettings   []GeminiSafetySetting `json:"safetySettings,omitempty"`
+}
+
+// GeminiSafetySetting relaxes one Gemini safety filter, e.g.
+// {Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"}
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role"`
 	Parts []GeminiPart `json:"parts"`
 }
 
 type GeminiPart struct {
-	Text string `json:"text"`
+	Text       string            `json:"text,omitempty"`
+	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+}
+
+// GeminiInlineData is a base64 encoded file sent next to the text
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
 }
 
 type GenerationConfig struct {
-	Temperature     float32  `json:"temperature"`
-	TopK            int      `json:"topK"`
-	TopP            int      `json:"topP"`
-	MaxOutputTokens int      `json:"maxOutputTokens"`
-	StopSequences   []string `json:"stopSequences"`
+	Temperature      float32         `json:"temperature"`
+	TopK             int             `json:"topK"`
+	TopP             float32         `json:"topP"`
+	MaxOutputTokens  int             `json:"maxOutputTokens"`
+	StopSequences    []string        `json:"stopSequences,omitempty"`
+	ResponseMimeType string          `json:"responseMimeType,omitempty"`
+	CandidateCount   int             `json:"candidateCount,omitempty"`
+	ThinkingConfig   *ThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type ThinkingConfig struct {
+	ThinkingBudget int `json:"thinkingBudget"`
+}
+
+var geminiVersionRegex = regexp.MustCompile(`gemini-(\d+)\.(\d+)`)
+
+// geminiThinkingConfig maps ThinkingBudget for the models that accept it, 2.5 Pro
+// cannot turn thinking off so a budget of 0 becomes its minimum of 128
+func (llm *LLMHoneypot) geminiThinkingConfig() *ThinkingConfig {
+	if llm.ThinkingBudget == nil {
+		return nil
+	}
+	version := geminiVersionRegex.FindStringSubmatch(llm.Model)
+	if version == nil {
+		log.Debugf("thinkingBudget ignored, %s is not a Gemini 2.5+ model", llm.Model)
+		return nil
+	}
+	major, _ := strconv.Atoi(version[1])
+	minor, _ := strconv.Atoi(version[2])
+	if major < 2 || (major == 2 && minor < 5) {
+		log.Debugf("thinkingBudget ignored, %s does not support thinking", llm.Model)
+		return nil
+	}
+
+	budget := *llm.ThinkingBudget
+	if budget == 0 && strings.Contains(llm.Model, "-pro") {
+		log.Warnf("%s cannot disable thinking, using the minimum budget of 128", llm.Model)
+		budget = 128
+	}
+	return &ThinkingConfig{ThinkingBudget: budget}
 }
 
+// CandidateSelection picks the answer among the candidates of a Gemini response
+type CandidateSelection int
+
+const (
+	// CandidateFirst returns the first candidate, like a single candidate request
+	CandidateFirst CandidateSelection = iota
+	// CandidateRandom returns a random usable candidate so repeated commands vary
+	CandidateRandom
+)
+
 type GeminiResponse struct {
 	Candidates []struct {
 		Content      GeminiContent `json:"content"`
 		FinishReason string        `json:"finishReason"`
 		Index        int           `json:"index"`
 	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
 }
 
-func (llm *LLMHoneypot) geminiCaller(msgs []Message) (string, error) {
+// toGeminiContents maps the roles to user and model, Gemini has no system role
+func toGeminiContents(msgs []Message) []GeminiContent {
 	var contents []GeminiContent
-
 	for _, m := range msgs {
 		var role string
 		switch m.Role {
@@ -327,56 +1544,326 @@ func (llm *LLMHoneypot) geminiCaller(msgs []Message) (string, error) {
 		default:
 			role = "user"
 		}
+		var images []GeminiPart
+		for _, image := range m.Images {
+			images = append(images, GeminiPart{InlineData: &GeminiInlineData{
+				MimeType: http.DetectContentType(image),
+				Data:     base64.StdEncoding.EncodeToString(image),
+			}})
+		}
+		// system is sent as user, so consecutive turns of the same role are joined
+		if last := len(contents) - 1; last >= 0 && contents[last].Role == role {
+			contents[last].Parts[0].Text += "\n\n" + m.Content
+			contents[last].Parts = append(contents[last].Parts, images...)
+			continue
+		}
 		contents = append(contents, GeminiContent{
 			Role:  role,
-			Parts: []GeminiPart{{Text: m.Content}},
+			Parts: append([]GeminiPart{{Text: m.Content}}, images...),
 		})
 	}
+	return contents
+}
+
+func (llm *LLMHoneypot) geminiCaller(ctx context.Context, msgs []Message) (string, Usage, error) {
+	text, finishReason, usage, err := llm.geminiGenerate(ctx, msgs)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	// câu trả lời bị cắt ở maxOutputTokens: xin phần còn lại thay vì trả output dở dang
+	var output strings.Builder
+	output.WriteString(text)
+	for i := 0; llm.AutoContinue && finishReason == "MAX_TOKENS" && i < maxContinuations; i++ {
+		msgs = append(msgs[:len(msgs):len(msgs)],
+			Message{Role: ASSISTANT.String(), Content: text},
+			Message{Role: USER.String(), Content: continuationPrompt},
+		)
+		var more Usage
+		if text, finishReason, more, err = llm.geminiGenerate(ctx, msgs); err != nil {
+			// the truncated answer is still better than none
+			log.Warnf("gemini continuation failed: %s", err.Error())
+			break
+		}
+		output.WriteString(text)
+		usage.PromptTokens += more.PromptTokens
+		usage.CompletionTokens += more.CompletionTokens
+		usage.TotalTokens += more.TotalTokens
+	}
+	return removeQuotes(output.String()), usage, nil
+}
+
+// geminiGenerate returns the text of the selected candidate with its finishReason
+func (llm *LLMHoneypot) geminiGenerate(ctx context.Context, msgs []Message) (string, string, Usage, error) {
+	contents := toGeminiContents(msgs)
+	var cachedContent string
+	if llm.CachePrompt {
+		if name, rest, ok := llm.geminiPromptCache(ctx, msgs); ok {
+			cachedContent, contents = name, toGeminiContents(rest)
+		}
+	}
 
 	gReq := GeminiRequest{
-		Contents: contents,
+		Contents:      contents,
+		CachedContent: cachedContent,
 		GenerationConfig: GenerationConfig{
 			Temperature:     llm.Temperature,
-			TopK:            1,
-			TopP:            int(llm.TopP),
+			TopK:            llm.TopK,
+			TopP:            llm.TopP,
 			MaxOutputTokens: 2048,
-			StopSequences:   []string{},
+			StopSequences:   llm.StopSequences,
+			CandidateCount:  llm.CandidateCount,
+			ThinkingConfig:  llm.geminiThinkingConfig(),
 		},
+		SafetySettings: llm.GeminiSafetySettings,
+	}
+	if llm.JSONMode {
+		gReq.GenerationConfig.ResponseMimeType = "application/json"
+	}
+	if err := llm.validateGeminiRequest(gReq); err != nil {
+		return "", "", Usage{}, err
 	}
 
 	reqJSON, err := json.Marshal(gReq)
 	if err != nil {
-		return "", err
+		return "", "", Usage{}, err
+	}
+
+	req := llm.newRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(reqJSON).
+		SetResult(&GeminiResponse{})
+
+	var url string
+	if llm.VertexAI {
+		if url, err = llm.vertexAIEndpoint(); err != nil {
+			return "", "", Usage{}, err
+		}
+		token, err := llm.gcpTokenSource().Token(ctx)
+		if err != nil {
+			return "", "", Usage{}, fmt.Errorf("vertex AI token: %v", err)
+		}
+		req.SetAuthToken(token)
+	} else {
+		key, err := llm.apiKey(ctx, googleAPIKey)
+		if err != nil {
+			return "", "", Usage{}, err
+		}
+		if key == "" {
+			return "", "", Usage{}, errors.New("googleAPIKey is empty")
+		}
+		url = fmt.Sprintf(geminiEndpoint, llm.Model)
+		if llm.Host != "" {
+			url = strings.TrimSuffix(llm.Host, "/") + fmt.Sprintf(geminiPath, llm.Model)
+		}
+		req.SetQueryParam("key", key)
+	}
+	logPayload(ctx, reqJSON, msgs)
+
+	resp, err := req.Post(url)
+	if err != nil {
+		return "", "", Usage{}, err
+	}
+	llm.keepRawResponse(resp.Body())
+	if err := modelNotFound(resp.StatusCode(), resp.String()); err != nil {
+		return "", "", Usage{}, err
+	}
+	if resp.StatusCode() != 200 {
+		return "", "", Usage{}, fmt.Errorf("gemini API request failed: %s – %s", resp.Status(), resp.String())
+	}
+
+	gRes := resp.Result().(*GeminiResponse)
+	if gRes.PromptFeedback.BlockReason != "" {
+		return "", "", Usage{}, fmt.Errorf("gemini blocked the prompt: %s", gRes.PromptFeedback.BlockReason)
+	}
+	candidate := 0
+	if llm.CandidateSelection == CandidateRandom {
+		var usable []int
+		for i, c := range gRes.Candidates {
+			if c.FinishReason != "SAFETY" && len(c.Content.Parts) > 0 {
+				usable = append(usable, i)
+			}
+		}
+		if len(usable) > 0 {
+			candidate = usable[rand.Intn(len(usable))]
+		}
+	}
+	if len(gRes.Candidates) > 0 && gRes.Candidates[candidate].FinishReason == "SAFETY" {
+		return "", "", Usage{}, errors.New("gemini blocked the response: SAFETY")
+	}
+	if len(gRes.Candidates) == 0 || len(gRes.Candidates[candidate].Content.Parts) == 0 {
+		return "", "", Usage{}, errors.New("no content in Gemini response")
+	}
+
+	usage := Usage{
+		PromptTokens:     gRes.UsageMetadata.PromptTokenCount,
+		CompletionTokens: gRes.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      gRes.UsageMetadata.TotalTokenCount,
+	}
+	return gRes.Candidates[candidate].Content.Parts[0].Text, gRes.Candidates[candidate].FinishReason, usage, nil
+}
+
+// -----------------------------------------------------------------------------
+// Cohere structures & caller
+// -----------------------------------------------------------------------------
+
+type CohereRequest struct {
+	Model         string          `json:"model,omitempty"`
+	Message       string          `json:"message"`
+	ChatHistory   []CohereMessage `json:"chat_history,omitempty"`
+	Preamble      string          `json:"preamble,omitempty"`
+	Temperature   float32         `json:"temperature,omitempty"`
+	P             float32         `json:"p,omitempty"`
+	K             int             `json:"k,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+}
+
+type CohereMessage struct {
+	Role    string `json:"role"`
+	Message string `json:"message"`
+}
+
+type CohereResponse struct {
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+	Meta         struct {
+		BilledUnits struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"billed_units"`
+	} `json:"meta"`
+}
+
+func (llm *LLMHoneypot) cohereCaller(ctx context.Context, msgs []Message) (string, Usage, error) {
+	key, err := llm.apiKey(ctx, cohereKey)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	if key == "" {
+		return "", Usage{}, errors.New("cohereKey is empty")
+	}
+	if hasImages(msgs) {
+		return "", Usage{}, ErrImagesNotSupported
+	}
+	if llm.Host == "" {
+		llm.Host = cohereEndpoint
 	}
 
-	if llm.GoogleAPIKey == "" {
-		return "", errors.New("googleAPIKey is empty")
+	var preamble []string
+	var history []CohereMessage
+	for _, m := range msgs {
+		switch m.Role {
+		case SYSTEM.String():
+			preamble = append(preamble, m.Content)
+		case ASSISTANT.String():
+			history = append(history, CohereMessage{Role: "CHATBOT", Message: m.Content})
+		default:
+			history = append(history, CohereMessage{Role: "USER", Message: m.Content})
+		}
+	}
+	if len(history) == 0 {
+		return "", Usage{}, errors.New("no message for Cohere request")
 	}
 
-	url := fmt.Sprintf(geminiEndpoint, llm.Model)
-	if log.IsLevelEnabled(log.DebugLevel) {
-		log.Debug(string(reqJSON))
+	reqJSON, err := json.Marshal(CohereRequest{
+		Model:         llm.Model,
+		Message:       history[len(history)-1].Message,
+		ChatHistory:   history[:len(history)-1],
+		Preamble:      strings.Join(preamble, "\n"),
+		Temperature:   llm.Temperature,
+		P:             llm.TopP,
+		K:             llm.TopK,
+		StopSequences: llm.StopSequences,
+	})
+	if err != nil {
+		return "", Usage{}, err
 	}
 
-	resp, err := llm.client.R().
+	logPayload(ctx, reqJSON, msgs)
+
+	resp, err := llm.newRequest(ctx).
 		SetHeader("Content-Type", "application/json").
-		SetQueryParam("key", llm.GoogleAPIKey).
 		SetBody(reqJSON).
-		SetResult(&GeminiResponse{}).
-		Post(url)
+		SetAuthToken(key).
+		SetResult(&CohereResponse{}).
+		Post(llm.Host)
 	if err != nil {
-		return "", err
+		return "", Usage{}, err
+	}
+	llm.keepRawResponse(resp.Body())
+	if err := modelNotFound(resp.StatusCode(), resp.String()); err != nil {
+		return "", Usage{}, err
 	}
 	if resp.StatusCode() != 200 {
-		return "", fmt.Errorf("gemini API request failed: %s – %s", resp.Status(), resp.String())
+		return "", Usage{}, fmt.Errorf("cohere API request failed: %s – %s", resp.Status(), resp.String())
 	}
 
-	gRes := resp.Result().(*GeminiResponse)
-	if len(gRes.Candidates) == 0 || len(gRes.Candidates[0].Content.Parts) == 0 {
-		return "", errors.New("no content in Gemini response")
+	result := resp.Result().(*CohereResponse)
+	usage := Usage{
+		PromptTokens:     result.Meta.BilledUnits.InputTokens,
+		CompletionTokens: result.Meta.BilledUnits.OutputTokens,
+		TotalTokens:      result.Meta.BilledUnits.InputTokens + result.Meta.BilledUnits.OutputTokens,
+	}
+	return removeQuotes(result.Text), usage, nil
+}
+
+// -----------------------------------------------------------------------------
+// Exec caller
+// -----------------------------------------------------------------------------
+
+func (llm *LLMHoneypot) execCaller(ctx context.Context, msgs []Message) (string, Usage, error) {
+	if len(llm.ExecCommand) == 0 {
+		return "", Usage{}, errors.New("execCommand is empty")
+	}
+	if hasImages(msgs) {
+		return "", Usage{}, ErrImagesNotSupported
+	}
+	if llm.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, llm.Timeout)
+		defer cancel()
+	}
+
+	prompt := formatPlainPrompt(msgs)
+	logPayload(ctx, []byte(prompt), msgs)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, llm.ExecCommand[0], llm.ExecCommand[1:]...)
+	cmd.Stdin = strings.NewReader(prompt)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", Usage{}, ctx.Err()
+		}
+		return "", Usage{}, fmt.Errorf("%s failed: %v: %s", llm.ExecCommand[0], err, strings.TrimSpace(stderr.String()))
+	}
+	llm.keepRawResponse(stdout.Bytes())
+
+	output := strings.TrimSpace(stdout.String())
+	if output == "" {
+		return "", Usage{}, errors.New("no output from exec provider")
 	}
+	return removeQuotes(output), Usage{}, nil
+}
 
-	return removeQuotes(gRes.Candidates[0].Content.Parts[0].Text), nil
+// formatPlainPrompt renders the conversation as a transcript ending with an open
+// assistant turn, the format completion binaries like llama-cli continue from
+func formatPlainPrompt(msgs []Message) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		switch m.Role {
+		case SYSTEM.String():
+			b.WriteString("System: ")
+		case ASSISTANT.String():
+			b.WriteString("Assistant: ")
+		default:
+			b.WriteString("User: ")
+		}
+		b.WriteString(m.Content + "\n")
+	}
+	b.WriteString("Assistant: ")
+	return b.String()
 }
 
 // -----------------------------------------------------------------------------
@@ -384,37 +1871,917 @@ func (llm *LLMHoneypot) geminiCaller(msgs []Message) (string, error) {
 // -----------------------------------------------------------------------------
 
 func (llm *LLMHoneypot) ExecuteModel(command string) (string, error) {
+	return llm.ExecuteModelContext(context.Background(), command)
+}
+
+// ExecuteModelContext is ExecuteModel bound to ctx, cancelling it aborts the provider request
+func (llm *LLMHoneypot) ExecuteModelContext(ctx context.Context, command string) (string, error) {
+	return llm.run(ctx, command, &turn{})
+}
+
+// ExecuteModelMultimodal sends images (e.g. files uploaded to the HTTP honeypot)
+// along with the command. Ollama, OpenAI, Gemini and compatible gateways accept
+// them, the other providers fail with ErrImagesNotSupported. A text-only Ollama
+// model (no llava-like vision) gets the command without the images
+func (llm *LLMHoneypot) ExecuteModelMultimodal(command string, images [][]byte) (string, error) {
+	return llm.run(context.Background(), command, &turn{images: images})
+}
+
+// turn holds the per-call extras of the entry points
+type turn struct {
+	images    [][]byte
+	onChunk   func(string)
+	tools     []Tool
+	toolCalls []ToolCall
+	// route is the honeypot the provider call went through, see Router
+	route *LLMHoneypot
+}
+
+func (llm *LLMHoneypot) run(ctx context.Context, command string, t *turn) (string, error) {
+	if llm.Latency != nil {
+		defer llm.Latency.wait(time.Now())
+	}
+
+	ctx, requestID := ensureRequestID(ctx)
+	ctx, endSpan := llm.startSpan(ctx, requestID)
+	interaction := Interaction{
+		RequestID: requestID,
+		SessionID: llm.SessionID,
+		SourceIP:  llm.SourceIP,
+		Timestamp: time.Now().UTC(),
+		Protocol:  llm.Protocol,
+		Provider:  llm.Provider,
+		Model:     llm.Model,
+		Command:   command,
+	}
+	echo := llm.commandEcho(command, t)
+	if echo != "" && t.onChunk != nil {
+		t.onChunk(echo)
+	}
+	start := time.Now()
+	output, usage, err := llm.execute(ctx, command, t)
+	interaction.Latency = time.Since(start)
+	if err == nil && llm.recorder != nil && t.tools == nil {
+		if recordErr := llm.recorder.record(command, output); recordErr != nil {
+			log.Warnf("error recording the session: %s", recordErr.Error())
+		}
+	}
+	interaction.Err = err
+	if t.route != nil {
+		interaction.Provider, interaction.Model = t.route.Provider, t.route.Model
+	}
+
+	// the attacker sees a plausible answer, the error only reaches the logs
+	if err != nil && llm.FallbackResponse != "" {
+		log.WithFields(log.Fields{
+			"request_id": requestID,
+			"protocol":   llm.Protocol.String(),
+			"provider":   llm.Provider.String(),
+			"command":    command,
+		}).Errorf("LLM request failed, answering with the fallback response: %s", err.Error())
+		output, err = llm.FallbackResponse, nil
+	}
+	if err == nil {
+		// the model and history only ever see the placeholders
+		output = echo + llm.substituteHoneytokens(output)
+	}
+
+	interaction.Response = output
+	interaction.Usage = usage
+	interaction.CostUSD = llm.estimateCost(interaction.Model, usage)
+	endSpan(interaction)
+	if llm.Tracer != nil {
+		llm.Tracer.TraceEvent(interaction.traceEvent())
+	}
+	if llm.Sink != nil {
+		if sinkErr := llm.Sink.Emit(ctx, interaction); sinkErr != nil {
+			log.Warnf("error emitting interaction: %s", sinkErr.Error())
+		}
+	}
+	llm.publish(ctx, interaction)
+	return output, err
+}
+
+// execute is shared by the buffered and the streaming entry points: a streamed
+// answer is fully buffered before the filters below decide what reaches Histories
+func (llm *LLMHoneypot) execute(ctx context.Context, command string, t *turn) (string, Usage, error) {
+	if llm.replay != nil && t.tools == nil {
+		output, err := llm.replay.answer(command)
+		switch {
+		case err == nil:
+			if t.onChunk != nil {
+				t.onChunk(output)
+			}
+			llm.updateState(command, output)
+			if !llm.Stateless {
+				llm.AppendHistory(ASSISTANT, output)
+			}
+			return output, Usage{}, nil
+		case !llm.ReplayFallthrough:
+			return "", Usage{}, err
+		}
+	}
+	if output, handled := llm.handleControlCommand(command); handled {
+		return output, Usage{}, nil
+	}
+	// a blank command after AppendToolResult or with images is not an empty line
+	if llm.SkipBlankInput && t.tools == nil && len(t.images) == 0 && strings.TrimSpace(command) == "" {
+		return llm.blankInputResponse(), Usage{}, nil
+	}
+
+	denied, err := llm.isCommandDenied(command)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	if denied {
+		if llm.DeniedResponse != "" {
+			return llm.DeniedResponse, Usage{}, nil
+		}
+		return llm.commandNotFound(command), Usage{}, nil
+	}
+	if output, ok := llm.readOverriddenFiles(command); ok {
+		// the model sees the canned content, so that later commands agree with it
+		llm.updateState(command, output)
+		if !llm.Stateless {
+			llm.AppendHistory(ASSISTANT, output)
+		}
+		return output, Usage{}, nil
+	}
+
+	// a dry run never calls the provider, not even to summarize
+	if llm.SummarizeHistory && !llm.Stateless && !llm.DryRun {
+		if err := llm.summarizeHistory(ctx); err != nil {
+			log.Warnf("history summarization failed: %s", err.Error())
+		}
+	}
+
 	prompt, err := llm.buildPrompt(command)
 	if err != nil {
-		return "", err
+		return "", Usage{}, err
+	}
+	if len(t.images) > 0 {
+		prompt[len(prompt)-1].Images = t.images
+	}
+	if t.tools != nil && command == "" {
+		// continuation after AppendToolResult, there is no new user message
+		prompt = prompt[:len(prompt)-1]
+	}
+	var pii piiTokens
+	if llm.PrivacyFilter {
+		prompt, pii = llm.tokenizePII(prompt)
+	}
+
+	if llm.DryRun {
+		return llm.dryRun(command, prompt), Usage{}, nil
 	}
 
+	llm.rawResponse()
+	caller := llm.route(command)
+	t.route = caller
+	// a batched request waits for its batch, sendBatch takes the token and slot for all of them
+	if !caller.batches(t) {
+		if err := caller.waitRateLimit(ctx); err != nil {
+			return "", Usage{}, err
+		}
+		release, err := llm.acquireSlot(ctx)
+		if err != nil {
+			return "", Usage{}, err
+		}
+		defer release()
+	}
+
+	budget := llm.retryBudget()
 	var output string
+	var usage Usage
+	var message Message
+	call := func(model *LLMHoneypot) (callErr error) {
+		switch {
+		case t.onChunk != nil:
+			onChunk, flush := llm.honeytokenStream(t.onChunk)
+			restored, flushPII := pii.stream(onChunk)
+			output, usage, callErr = model.callProviderStream(ctx, prompt, restored)
+			flushPII()
+			flush()
+		case t.tools != nil:
+			message, usage, callErr = model.callProviderWithTools(ctx, prompt, t.tools)
+			output = removeQuotes(message.Content)
+		case model.batches(t):
+			output, usage, callErr = model.callProviderBatched(ctx, prompt)
+		default:
+			output, usage, callErr = model.callProvider(ctx, prompt)
+		}
+		return callErr
+	}
+	fallback, open, err := caller.guard(ctx, func() error {
+		if t.onChunk != nil {
+			// chunks already sent to the attacker cannot be taken back, a missing
+			// model fails before any chunk so the fallbacks are still safe
+			served, callErr := caller.withModelFallbacks(call)
+			t.route = served
+			return callErr
+		}
+		return caller.withRetries(ctx, budget, func() error {
+			served, callErr := caller.withModelFallbacks(call)
+			t.route = served
+			return callErr
+		})
+	})
+	if open {
+		return fallback, Usage{}, err
+	}
+	output = pii.restore(output)
+	if err != nil {
+		if t.onChunk != nil && ctx.Err() != nil && output != "" && llm.StorePartialOutput {
+			llm.storePartialOutput(command, output)
+		}
+		return "", usage, err
+	}
+	if len(message.ToolCalls) > 0 {
+		message.Content = pii.restore(message.Content)
+		for i := range message.ToolCalls {
+			message.ToolCalls[i].Function.Arguments = pii.restore(message.ToolCalls[i].Function.Arguments)
+		}
+		t.toolCalls = message.ToolCalls
+		if !llm.Stateless {
+			llm.appendMessage(message)
+		}
+		return message.Content, usage, nil
+	}
+	if output, err = llm.limitResponse(output); err != nil {
+		return "", usage, err
+	}
+	output = llm.postProcess(output)
+	if llm.JSONMode && !json.Valid([]byte(strings.TrimSpace(output))) {
+		return "", usage, errors.New("model output is not valid JSON")
+	}
+
+	// Model không tuân thủ prompt: log để tune prompt và trả về fallback an toàn
+	if looksLikeBreakChar(output) {
+		breakCharacterIncidents.Add(1)
+		log.WithFields(log.Fields{
+			"request_id": RequestIDFromContext(ctx),
+			"protocol":   llm.Protocol.String(),
+			"provider":   caller.Provider.String(),
+			"model":      caller.Model,
+			"command":    command,
+			"output":     output,
+		}).Warn("LLM broke character")
+		return llm.breakCharacterFallback(command), usage, nil
+	}
+
+	llm.updateState(command, output)
+	if !llm.Stateless {
+		llm.AppendHistory(ASSISTANT, output)
+	}
+	return output, usage, nil
+}
+
+// updateState records what the attacker saw in State and in the shared Scenario.
+// State tracks a shell, HTTP request lines or TCP payloads would end up in its history
+func (llm *LLMHoneypot) updateState(command, output string) {
+	if llm.State != nil && llm.Protocol == tracer.SSH {
+		llm.State.Update(command, output)
+	}
+	if llm.Scenario != nil {
+		llm.Scenario.Record(llm.Protocol, command, output)
+	}
+}
+
+// storePartialOutput keeps what the attacker saw of a cancelled stream, filtered
+// like a complete answer, so that the next commands stay consistent with it
+func (llm *LLMHoneypot) storePartialOutput(command, output string) {
+	output, err := llm.limitResponse(output)
+	if err != nil {
+		return
+	}
+	output = llm.postProcess(output)
+	if looksLikeBreakChar(output) {
+		return
+	}
+	llm.updateState(command, output)
+	if !llm.Stateless {
+		llm.AppendHistory(ASSISTANT, output)
+	}
+}
+
+func (llm *LLMHoneypot) callProvider(ctx context.Context, msgs []Message) (string, Usage, error) {
+	if llm.MergeConsecutiveRoles || llm.Provider == Gemini || llm.Provider == Cohere {
+		msgs = mergeConsecutiveRoles(msgs)
+	}
 	switch llm.Provider {
 	case Ollama:
-		output, err = llm.ollamaCaller(prompt)
+		return llm.ollamaCaller(ctx, msgs)
 	case OpenAI:
-		output, err = llm.openAICaller(prompt)
+		return llm.openAICaller(ctx, msgs)
 	case Gemini:
-		output, err = llm.geminiCaller(prompt)
+		return llm.geminiCaller(ctx, msgs)
+	case Cohere:
+		return llm.cohereCaller(ctx, msgs)
+	case Compatible:
+		return llm.compatibleCaller(ctx, msgs)
+	case Exec:
+		return llm.execCaller(ctx, msgs)
 	default:
-		return "", fmt.Errorf("provider %d not supported", llm.Provider)
+		return "", Usage{}, fmt.Errorf("provider %d not supported", llm.Provider)
 	}
-	if err == nil {
-		// Lưu lại history nếu model tuân thủ prompt (đơn giản: không chứa "language model")
-		if !strings.Contains(strings.ToLower(output), "language model") {
-			llm.Histories = append(llm.Histories, Message{Role: ASSISTANT.String(), Content: output})
+}
+
+// dryRun logs the assembled prompt and answers without calling the provider
+func (llm *LLMHoneypot) dryRun(command string, prompt []Message) string {
+	promptJSON, err := json.Marshal(prompt)
+	if err != nil {
+		promptJSON = []byte(err.Error())
+	}
+	log.WithFields(log.Fields{
+		"protocol": llm.Protocol.String(),
+		"provider": llm.Provider.String(),
+		"model":    llm.Model,
+		"command":  command,
+	}).Info("LLM dry run, prompt: " + redactSecrets(string(promptJSON)))
+
+	if llm.FallbackResponse != "" {
+		return llm.FallbackResponse
+	}
+	return dryRunResponse
+}
+
+// ErrImagesNotSupported is returned when images are sent to a provider without vision input
+var ErrImagesNotSupported = errors.New("provider does not support image inputs")
+
+func hasImages(msgs []Message) bool {
+	for _, m := range msgs {
+		if len(m.Images) > 0 {
+			return true
 		}
 	}
-	return output, err
+	return false
+}
+
+// ollamaNoVision matches the error of Ollama for images sent to a text-only model,
+// "does not support vision" or "missing data required for image input" on older releases
+var ollamaNoVision = regexp.MustCompile(`(?i)vision|image input`)
+
+func withoutImages(msgs []Message) []Message {
+	text := make([]Message, len(msgs))
+	for i, m := range msgs {
+		m.Images = nil
+		text[i] = m
+	}
+	return text
+}
+
+// toOpenAIVisionMessages turns images into data URLs next to the text part
+func toOpenAIVisionMessages(msgs []Message) []openAIVisionMessage {
+	vision := make([]openAIVisionMessage, 0, len(msgs))
+	for _, m := range msgs {
+		parts := []openAIContentPart{{Type: "text", Text: m.Content}}
+		for _, image := range m.Images {
+			url := "data:" + http.DetectContentType(image) + ";base64," + base64.StdEncoding.EncodeToString(image)
+			parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+		}
+		vision = append(vision, openAIVisionMessage{Role: m.Role, Content: parts})
+	}
+	return vision
+}
+
+// mergeConsecutiveRoles joins the content of consecutive messages with the same role,
+// as left behind when trimming drops a turn. Tool calls and results are never merged
+func mergeConsecutiveRoles(msgs []Message) []Message {
+	merged := make([]Message, 0, len(msgs))
+	for _, m := range msgs {
+		last := len(merged) - 1
+		if last >= 0 && merged[last].Role == m.Role && m.Role != TOOL.String() &&
+			len(merged[last].ToolCalls) == 0 && len(m.ToolCalls) == 0 {
+			merged[last].Content += "\n\n" + m.Content
+			images := merged[last].Images
+			merged[last].Images = append(images[:len(images):len(images)], m.Images...)
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// summarizeHistory replaces the oldest half of Histories with a model-written note
+func (llm *LLMHoneypot) summarizeHistory(ctx context.Context) error {
+	histories := llm.history()
+	if llm.SummarizeThreshold <= 0 || len(histories) <= llm.SummarizeThreshold {
+		return nil
+	}
+	keep := llm.SummarizeThreshold / 2
+	old := histories[:len(histories)-keep]
+
+	var transcript strings.Builder
+	if llm.HistorySummary != "" {
+		transcript.WriteString("Previous summary:\n" + llm.HistorySummary + "\n\n")
+	}
+	for _, m := range old {
+		transcript.WriteString(m.Role + ": " + m.Content + "\n")
+	}
+
+	summarizer := *llm
+	summarizer.JSONMode = false
+	summarizer.StopSequences = nil
+	summarizer.lastRaw = nil
+	if llm.SummaryModel != "" {
+		summarizer.Model = llm.SummaryModel
+	}
+	prompt := []Message{
+		{Role: SYSTEM.String(), Content: systemPromptSummarizeHistory},
+		{Role: USER.String(), Content: transcript.String()},
+	}
+	var pii piiTokens
+	if llm.PrivacyFilter {
+		prompt, pii = llm.tokenizePII(prompt)
+	}
+	summary, _, err := summarizer.callProvider(ctx, prompt)
+	if err != nil {
+		return err
+	}
+	// the summary is history, it keeps the real values like the turns it replaces
+	summary = pii.restore(summary)
+
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+	llm.HistorySummary = strings.TrimSpace(summary)
+	llm.Histories = append([]Message{}, llm.Histories[len(llm.Histories)-keep:]...)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// History
+// -----------------------------------------------------------------------------
+
+// historyFallbackMu guards honeypots built as struct literals, without NewLLMHoneypot
+var historyFallbackMu sync.Mutex
+
+func (llm *LLMHoneypot) historyLock() *sync.Mutex {
+	if llm.historyMu != nil {
+		return llm.historyMu
+	}
+	return &historyFallbackMu
+}
+
+// history returns a copy of Histories that is safe to use while other goroutines append
+func (llm *LLMHoneypot) history() []Message {
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Message(nil), llm.Histories...)
+}
+
+// AppendHistory adds one message to the history, e.g. to pre-seed a scenario
+func (llm *LLMHoneypot) AppendHistory(role Role, content string) error {
+	if role < SYSTEM || role > TOOL {
+		return fmt.Errorf("invalid role %d", role)
+	}
+
+	llm.appendMessage(Message{Role: role.String(), Content: content})
+	return nil
+}
+
+func (llm *LLMHoneypot) appendMessage(message Message) {
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+	llm.Histories = append(llm.Histories, message)
+}
+
+// SetHistory replaces the whole history, every message must use the system, user, assistant or tool role
+func (llm *LLMHoneypot) SetHistory(histories []Message) error {
+	for i, m := range histories {
+		if m.Role != SYSTEM.String() && m.Role != USER.String() && m.Role != ASSISTANT.String() && m.Role != TOOL.String() {
+			return fmt.Errorf("invalid role %q at message %d", m.Role, i)
+		}
+	}
+
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+	llm.Histories = append([]Message(nil), histories...)
+	return nil
+}
+
+// Clone forks the honeypot: history, state and configuration are deep-copied so
+// commands sent to the clone never reach the parent. Sink and HTTP client are shared,
+// the clone works on a copy of the Scenario
+func (llm *LLMHoneypot) Clone() *LLMHoneypot {
+	mu := llm.historyLock()
+	mu.Lock()
+	clone := *llm
+	clone.Histories = copyMessages(llm.Histories)
+	mu.Unlock()
+
+	clone.historyMu = &sync.Mutex{}
+	// the clone is a what-if branch, its interactions are not streamed with the real ones
+	clone.events = nil
+	clone.retryLimiter = nil
+	clone.recorder, clone.replay = nil, nil
+	clone.lastRaw = nil
+	clone.SeedMessages = copyMessages(llm.SeedMessages)
+	clone.StopSequences = append([]string(nil), llm.StopSequences...)
+	clone.ExecCommand = append([]string(nil), llm.ExecCommand...)
+	clone.ModelFallbacks = append([]string(nil), llm.ModelFallbacks...)
+	clone.GeminiSafetySettings = append([]GeminiSafetySetting(nil), llm.GeminiSafetySettings...)
+	if llm.OllamaOptions != nil {
+		clone.OllamaOptions = make(map[string]interface{}, len(llm.OllamaOptions))
+		for k, v := range llm.OllamaOptions {
+			clone.OllamaOptions[k] = v
+		}
+	}
+	if llm.ModelPrices != nil {
+		clone.ModelPrices = make(map[string]ModelPrice, len(llm.ModelPrices))
+		for k, v := range llm.ModelPrices {
+			clone.ModelPrices[k] = v
+		}
+	}
+	if llm.LogitBias != nil {
+		clone.LogitBias = make(map[string]float64, len(llm.LogitBias))
+		for k, v := range llm.LogitBias {
+			clone.LogitBias[k] = v
+		}
+	}
+	if llm.FileOverrides != nil {
+		clone.FileOverrides = make(map[string]string, len(llm.FileOverrides))
+		for k, v := range llm.FileOverrides {
+			clone.FileOverrides[k] = v
+		}
+	}
+	if llm.Honeytokens != nil {
+		clone.Honeytokens = make(map[string]string, len(llm.Honeytokens))
+		for k, v := range llm.Honeytokens {
+			clone.Honeytokens[k] = v
+		}
+	}
+	if llm.ExtraHeaders != nil {
+		clone.ExtraHeaders = make(map[string]string, len(llm.ExtraHeaders))
+		for k, v := range llm.ExtraHeaders {
+			clone.ExtraHeaders[k] = v
+		}
+	}
+	if llm.Seed != nil {
+		seed := *llm.Seed
+		clone.Seed = &seed
+	}
+	if llm.ThinkingBudget != nil {
+		budget := *llm.ThinkingBudget
+		clone.ThinkingBudget = &budget
+	}
+	if llm.Latency != nil {
+		latency := *llm.Latency
+		clone.Latency = &latency
+	}
+	if llm.Identity != nil {
+		identity := *llm.Identity
+		clone.Identity = &identity
+	}
+	if llm.Client != nil {
+		client := *llm.Client
+		clone.Client = &client
+	}
+	if llm.State != nil {
+		clone.State = llm.State.Clone()
+	}
+	if llm.Scenario != nil {
+		clone.Scenario = llm.Scenario.clone()
+	}
+	return &clone
+}
+
+func copyMessages(msgs []Message) []Message {
+	if msgs == nil {
+		return nil
+	}
+	copied := make([]Message, len(msgs))
+	for i, m := range msgs {
+		m.ToolCalls = append([]ToolCall(nil), m.ToolCalls...)
+		m.Images = append([][]byte(nil), m.Images...)
+		copied[i] = m
+	}
+	return copied
 }
 
 // -----------------------------------------------------------------------------
 // Helpers
 // -----------------------------------------------------------------------------
 
+var sleep = time.Sleep
+
+// sleepContext waits d, or returns ctx.Err() as soon as ctx is done
+var sleepContext = func(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay returns how much longer to wait so that the total time falls within the profile
+func (p LatencyProfile) delay(elapsed time.Duration) time.Duration {
+	target := p.Min
+	if p.Max > p.Min {
+		target += time.Duration(rand.Int63n(int64(p.Max - p.Min)))
+	}
+	if elapsed >= target {
+		return 0
+	}
+	return target - elapsed
+}
+
+func (p LatencyProfile) wait(start time.Time) {
+	if d := p.delay(time.Since(start)); d > 0 {
+		sleep(d)
+	}
+}
+
+// blankInputResponses are the answers of the default personas to an empty line,
+// a shell and psql just print a new prompt
+var blankInputResponses = map[tracer.Protocol]string{
+	tracer.HTTP: "HTTP/1.1 400 Bad Request\r\nContent-Type: text/html\r\nContent-Length: 0\r\nConnection: close",
+	tracer.IMAP: "* BAD Error in IMAP command received by server.",
+	tracer.POP3: "-ERR Unknown command.",
+}
+
+func (llm *LLMHoneypot) blankInputResponse() string {
+	if llm.BlankInputResponse != "" {
+		return llm.BlankInputResponse
+	}
+	return blankInputResponses[llm.Protocol]
+}
+
+// handleControlCommand answers session control commands without querying the model
+func (llm *LLMHoneypot) handleControlCommand(command string) (string, bool) {
+	if !llm.HandleControlCommands || llm.Protocol != tracer.SSH {
+		return "", false
+	}
+
+	switch strings.TrimSpace(command) {
+	case "exit", "logout":
+		if llm.OnExit != nil {
+			llm.OnExit(command)
+		}
+		return "", true
+	case "clear", "reset":
+		return terminalClearSequence, true
+	}
+	return "", false
+}
+
+func (llm *LLMHoneypot) isCommandDenied(command string) (bool, error) {
+	command = strings.TrimSpace(command)
+	var name string
+	if fields := strings.Fields(command); len(fields) > 0 {
+		name = fields[0]
+	}
+	matches := func(list []string) bool {
+		for _, c := range list {
+			if c == command || c == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(llm.AllowCommands) > 0 && !matches(llm.AllowCommands) {
+		return true, nil
+	}
+	if matches(llm.DenyCommands) {
+		return true, nil
+	}
+	for _, pattern := range llm.DenyPatterns {
+		regex, err := compileDenyPattern(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid deny pattern %q: %v", pattern, err)
+		}
+		if regex.MatchString(command) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// denyPatterns caches the compiled DenyPatterns, the strategies build a new
+// LLMHoneypot for every command so the cache is shared by all of them
+var denyPatterns sync.Map // string -> *regexp.Regexp
+
+func compileDenyPattern(pattern string) (*regexp.Regexp, error) {
+	if regex, ok := denyPatterns.Load(pattern); ok {
+		return regex.(*regexp.Regexp), nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	denyPatterns.Store(pattern, regex)
+	return regex, nil
+}
+
+// breakCharacterPhrases are the model talking about itself, wherever they appear
+var breakCharacterPhrases = []string{
+	"language model",
+	"as an ai",
+	"ai assistant",
+	"simulated environment",
+	"i'm simulating",
+	"i am simulating",
+}
+
+// refusalPrefixes only count at the start of the output: a file, man page or web
+// page may well contain "I cannot" further down
+var refusalPrefixes = []string{
+	"i'm sorry",
+	"i am sorry",
+	"i apologize",
+	"i cannot",
+	"i can't",
+}
+
+// looksLikeBreakChar reports output where the model talks about itself or refuses
+// instead of playing the service
+func looksLikeBreakChar(output string) bool {
+	lower := strings.ToLower(strings.TrimSpace(output))
+	for _, phrase := range breakCharacterPhrases {
+		if strings.Contains(lower, phrase) {
+			return true
+		}
+	}
+	for _, prefix := range refusalPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandNotFound is CommandNotFoundResponse for the first word of command
+func (llm *LLMHoneypot) commandNotFound(command string) string {
+	if llm.CommandNotFoundResponse == "" {
+		return defaultDeniedResponse
+	}
+	name := command
+	if fields := strings.Fields(command); len(fields) > 0 {
+		name = fields[0]
+	}
+	return strings.ReplaceAll(llm.CommandNotFoundResponse, "%s", name)
+}
+
+// notFoundPages are the 404 bodies of the HTTP personas, byte for byte what the
+// servers send, since the HTTP strategy writes the answer as the response body
+var notFoundPages = map[string]string{
+	"http/apache": "<!DOCTYPE HTML PUBLIC \"-//IETF//DTD HTML 2.0//EN\">\n<html><head>\n<title>404 Not Found</title>\n" +
+		"</head><body>\n<h1>Not Found</h1>\n<p>The requested URL was not found on this server.</p>\n<hr>\n" +
+		"<address>Apache/2.4.57 (Debian) Server at localhost Port 80</address>\n</body></html>\n",
+	"http/default": "<html>\r\n<head><title>404 Not Found</title></head>\r\n<body>\r\n<center><h1>404 Not Found</h1></center>\r\n" +
+		"<hr><center>nginx/1.24.0</center>\r\n</body>\r\n</html>\r\n",
+}
+
+func (llm *LLMHoneypot) breakCharacterFallback(command string) string {
+	switch {
+	case llm.Protocol == tracer.SSH:
+		return llm.commandNotFound(command)
+	case llm.Protocol == tracer.HTTP && !llm.WebSocket:
+		if page, ok := notFoundPages[llm.personaKey()]; ok {
+			return page
+		}
+		return notFoundPages["http/default"]
+	default:
+		return ""
+	}
+}
+
+// BreakCharacterIncidents is the number of responses replaced because the model broke character
+func BreakCharacterIncidents() uint64 {
+	return breakCharacterIncidents.Load()
+}
+
+// ErrResponseTooLarge is returned for answers over MaxResponseBytes when RejectOversizedResponse is set
+var ErrResponseTooLarge = errors.New("model response exceeds MaxResponseBytes")
+
+func (llm *LLMHoneypot) limitResponse(output string) (string, error) {
+	if llm.MaxResponseBytes <= 0 || len(output) <= llm.MaxResponseBytes {
+		return output, nil
+	}
+	if llm.RejectOversizedResponse {
+		return "", ErrResponseTooLarge
+	}
+
+	log.Warnf("model response of %d bytes truncated to %d", len(output), llm.MaxResponseBytes)
+	output, err := safeTruncate(output, llm.MaxResponseBytes, llm.Protocol)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrResponseTooLarge, err)
+	}
+	return output, nil
+}
+
+// postProcess applies the protocol-aware cleanups to the raw model output
+func (llm *LLMHoneypot) postProcess(output string) string {
+	if llm.Protocol == tracer.SSH || llm.Protocol == tracer.TCP {
+		output = llm.sanitizeTerminal(output)
+	}
+
+	switch llm.Protocol {
+	case tracer.HTTP:
+		if llm.WebSocket {
+			// frame payloads need not look like an HTTP response or body
+			output = strings.TrimLeft(httpPreambleRegex.ReplaceAllString(output, ""), "\n")
+			break
+		}
+		output = sanitizeHTTPResponse(output)
+	case tracer.SSH:
+		output = llm.stripTrailingPrompt(output)
+	}
+	return output
+}
+
+// commandEcho is the terminal line of the command when EchoCommand is set. It is
+// never stored in Histories, the model only sees its own output
+func (llm *LLMHoneypot) commandEcho(command string, t *turn) string {
+	if !llm.EchoCommand || t.tools != nil || (llm.Protocol != tracer.SSH && llm.Protocol != tracer.TCP) {
+		return ""
+	}
+	prompt := llm.EchoPrompt
+	if prompt == "" {
+		vars := llm.promptVariables()
+		sign := "$"
+		if vars.Username == "root" {
+			sign = "#"
+		}
+		prompt = fmt.Sprintf("%s@%s:~%s ", vars.Username, vars.Hostname, sign)
+	}
+	return prompt + command + "\n"
+}
+
+// ansiEscapeRegex matches CSI (colors, cursor moves), OSC (window title) and two-byte escapes
+var ansiEscapeRegex = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// sanitizeTerminal replaces invalid UTF-8 and removes escape sequences (StripANSI)
+// and other control characters but tab and newlines (SanitizeControl)
+func (llm *LLMHoneypot) sanitizeTerminal(output string) string {
+	if !llm.StripANSI && !llm.SanitizeControl {
+		return output
+	}
+
+	output = strings.ToValidUTF8(output, "\uFFFD")
+	if llm.StripANSI {
+		output = ansiEscapeRegex.ReplaceAllString(output, "")
+	}
+	if llm.SanitizeControl {
+		output = strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' || r == '\t' || !unicode.IsControl(r) {
+				return r
+			}
+			return -1
+		}, output)
+	}
+	return output
+}
+
+// sshPromptRegex matches prompts like user@ubuntu:~$, [root@centos ~]# and bash-5.1$
+var sshPromptRegex = regexp.MustCompile(`^(\[?[\w.-]+@[\w.-]+[: ][^\n]*?\]?|bash-[\d.]+)\s?[$#]\s*$`)
+
+// stripTrailingPrompt drops the fake shell prompt some models append, the SSH
+// strategy draws its own one. SSHPromptRegex overrides the default pattern
+func (llm *LLMHoneypot) stripTrailingPrompt(output string) string {
+	promptRegex := sshPromptRegex
+	if llm.SSHPromptRegex != "" {
+		custom, err := regexp.Compile(llm.SSHPromptRegex)
+		if err != nil {
+			log.Warnf("invalid SSH prompt regex %q: %s", llm.SSHPromptRegex, err.Error())
+			return output
+		}
+		promptRegex = custom
+	}
+
+	trimmed := strings.TrimRight(output, " \n")
+	last := trimmed[strings.LastIndex(trimmed, "\n")+1:]
+	if !promptRegex.MatchString(last) {
+		return output
+	}
+	return strings.TrimSuffix(trimmed, last)
+}
+
+var (
+	// a preamble opens with a chatty word and ends with a colon at the end of its line,
+	// so that bodies like "Page not found: /admin" are left alone
+	httpPreambleRegex  = regexp.MustCompile(`(?i)^\s*(?:sure|certainly|of course|okay|ok|here is|here's|below is|here you go)\b[^\n]*:[ \t]*(?:\r?\n|$)`)
+	httpBodyStartRegex = regexp.MustCompile(`(?m)^(HTTP/\d(\.\d)? \d{3}|\s*<|\s*[{\[])`)
+)
+
+// sanitizeHTTPResponse strips a chatty preamble and, after one, whatever the model
+// wrote before the status line, markup or JSON
+func sanitizeHTTPResponse(content string) string {
+	if loc := httpPreambleRegex.FindStringIndex(content); loc != nil {
+		content = content[loc[1]:]
+		if start := httpBodyStartRegex.FindStringIndex(content); start != nil {
+			content = content[start[0]:]
+		}
+	}
+	return strings.TrimLeft(content, "\n")
+}
+
 func removeQuotes(content string) string {
 	regex := regexp.MustCompile("(```( *)?([a-z]*)?(\\n)?)")
 	return regex.ReplaceAllString(content, "")
 }
-
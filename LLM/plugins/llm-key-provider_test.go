@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+// rotatingKeys returns a new OpenAI key on every call, like a secret manager after rotation
+type rotatingKeys struct {
+	calls int
+	err   error
+}
+
+func (k *rotatingKeys) OpenAIKey(context.Context) (string, error) {
+	k.calls++
+	if k.calls == 1 {
+		return "sk-first", k.err
+	}
+	return "sk-rotated", k.err
+}
+
+func (k *rotatingKeys) GoogleAPIKey(context.Context) (string, error)  { return "", k.err }
+func (k *rotatingKeys) CohereKey(context.Context) (string, error)     { return "", k.err }
+func (k *rotatingKeys) CompatibleKey(context.Context) (string, error) { return "", k.err }
+
+func TestExecuteModelKeyProviderResolvedPerRequest(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var tokens []string
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			tokens = append(tokens, req.Header.Get("Authorization"))
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.SSH,
+		Model:       "gpt-4o",
+		Provider:    OpenAI,
+		OpenAIKey:   "sk-static",
+		KeyProvider: &rotatingKeys{},
+	})
+	honeypot.client = client
+
+	//When
+	_, first := honeypot.ExecuteModel("ls")
+	_, second := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, first)
+	assert.Nil(t, second)
+	assert.Equal(t, []string{"Bearer sk-first", "Bearer sk-rotated"}, tokens)
+}
+
+func TestAPIKeyFallsBackToStaticField(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{GoogleAPIKey: "static-google", KeyProvider: &rotatingKeys{}}
+
+	//When
+	key, err := honeypot.apiKey(context.Background(), googleAPIKey)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "static-google", key)
+}
+
+func TestAPIKeyProviderError(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{CohereKey: "static-cohere", KeyProvider: &rotatingKeys{err: errors.New("vault sealed")}}
+
+	//When
+	_, err := honeypot.apiKey(context.Background(), cohereKey)
+
+	//Then
+	assert.EqualError(t, err, "key provider: vault sealed")
+}
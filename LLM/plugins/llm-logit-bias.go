@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// forbiddenTokenBias bans a token: OpenAI accepts biases between -100 and 100
+const forbiddenTokenBias = -100
+
+// LogitBiasForWords turns giveaway words ("honeypot", "AI", "simulate") into a LogitBias
+// that bans them. encode is the tokenizer of the model, e.g. tiktoken o200k_base for
+// gpt-4o. Each word is tried lowercase, capitalized and with a leading space; only
+// variants that encode to a single token are banned, since banning the pieces of a
+// longer word would break unrelated words, and the others are returned as skipped
+func LogitBiasForWords(encode func(text string) []int, words ...string) (bias map[string]float64, skipped []string) {
+	bias = make(map[string]float64)
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		for _, variant := range wordVariants(word) {
+			tokens := encode(variant)
+			if len(tokens) != 1 {
+				skipped = append(skipped, variant)
+				continue
+			}
+			bias[strconv.Itoa(tokens[0])] = forbiddenTokenBias
+		}
+	}
+	return bias, skipped
+}
+
+func wordVariants(word string) []string {
+	seen := make(map[string]bool)
+	var variants []string
+	first, size := utf8.DecodeRuneInString(word)
+	capitalized := string(unicode.ToUpper(first)) + word[size:]
+	for _, w := range []string{word, strings.ToLower(word), capitalized} {
+		for _, v := range []string{w, " " + w} {
+			if !seen[v] {
+				seen[v] = true
+				variants = append(variants, v)
+			}
+		}
+	}
+	return variants
+}
@@ -0,0 +1,41 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogitBiasForWords(t *testing.T) {
+	//Given
+	vocabulary := map[string]int{"honeypot": 1001, " honeypot": 1002, " Honeypot": 1003, "AI": 2001, " AI": 2002}
+	encode := func(text string) []int {
+		if id, ok := vocabulary[text]; ok {
+			return []int{id}
+		}
+		return []int{1, 2}
+	}
+
+	//When
+	bias, skipped := LogitBiasForWords(encode, "honeypot", "AI", "")
+
+	//Then
+	assert.Equal(t, map[string]float64{"1001": -100, "1002": -100, "1003": -100, "2001": -100, "2002": -100}, bias)
+	assert.Equal(t, []string{"Honeypot", "ai", " ai"}, skipped)
+}
+
+func TestChatCompletionsPayloadLogitBias(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Model: "gpt-4o", LogitBias: map[string]float64{"1001": -100}}
+	plain := LLMHoneypot{Model: "gpt-4o"}
+
+	//When
+	payload, err := honeypot.chatCompletionsPayload([]Message{{Role: USER.String(), Content: "ls"}}, nil, false)
+	assert.Nil(t, err)
+	plainPayload, err := plain.chatCompletionsPayload([]Message{{Role: USER.String(), Content: "ls"}}, nil, false)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Contains(t, string(payload), `"logit_bias":{"1001":-100}`)
+	assert.NotContains(t, string(plainPayload), "logit_bias")
+}
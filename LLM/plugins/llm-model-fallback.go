@@ -0,0 +1,52 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrModelNotFound is returned when the provider does not know the model: not
+// pulled on Ollama, deprecated or misspelled on the hosted APIs
+var ErrModelNotFound = errors.New("llm model not found")
+
+// modelNotFound wraps ErrModelNotFound for a 404, the status OpenAI, Ollama,
+// Gemini and Cohere all answer for an unknown model
+func modelNotFound(status int, body string) error {
+	if status != http.StatusNotFound {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrModelNotFound, strings.TrimSpace(body))
+}
+
+// withModelFallbacks runs call with Model, then with each of ModelFallbacks in order
+// while the provider reports the model as not found. It returns the honeypot whose
+// model answered, or the last one tried
+func (llm *LLMHoneypot) withModelFallbacks(call func(*LLMHoneypot) error) (*LLMHoneypot, error) {
+	current := llm
+	err := call(current)
+	for _, model := range llm.ModelFallbacks {
+		if !errors.Is(err, ErrModelNotFound) {
+			break
+		}
+		log.WithFields(log.Fields{
+			"provider": llm.Provider.String(),
+			"model":    current.Model,
+		}).Warnf("model not found, falling back to %s", model)
+
+		next := *llm
+		next.Model = model
+		current = &next
+		err = call(current)
+	}
+	if err == nil && current != llm {
+		log.WithFields(log.Fields{
+			"provider": llm.Provider.String(),
+			"model":    current.Model,
+		}).Infof("request served by fallback model instead of %s", llm.Model)
+	}
+	return current, err
+}
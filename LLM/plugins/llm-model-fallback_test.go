@@ -0,0 +1,80 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteModelFallsBackOnModelNotFound(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var models []string
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			models = append(models, body.Model)
+			if body.Model != "llama3" {
+				return httpmock.NewStringResponse(404, `{"error":"model \"`+body.Model+`\" not found, try pulling it first"}`), nil
+			}
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	sink := &mockInteractionSink{}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "llama3.3",
+		Provider:       Ollama,
+		Host:           ollamaEndpoint,
+		ModelFallbacks: []string{"llama3.1", "llama3", "mistral"},
+		Sink:           sink,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, []string{"llama3.3", "llama3.1", "llama3"}, models)
+	assert.Equal(t, "llama3", sink.interactions[0].Model)
+	assert.Equal(t, "llama3.3", honeypot.Model)
+}
+
+func TestExecuteModelNotFoundWithoutFallbacks(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		httpmock.NewStringResponder(404, `{"error":{"message":"The model gpt-3 does not exist","code":"model_not_found"}}`),
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:   tracer.SSH,
+		Model:      "gpt-3",
+		Provider:   OpenAI,
+		OpenAIKey:  "sdjdnklfjndslkjanfk",
+		MaxRetries: 2,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.ErrorIs(t, err, ErrModelNotFound)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,33 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+)
+
+// multilineInputFraming tells the model where a pasted block starts and ends, a
+// bare multi-line message is often answered line by line or as a chat message
+const multilineInputFraming = `The following %d lines were pasted into the shell as a single input, between the BEGIN INPUT and END INPUT markers. Bash reads them in order: a heredoc body up to its terminator is the standard input of its command, and a line ending with \, | or && continues on the next line. Reply only with the terminal output of the whole input, without echoing it.
+BEGIN INPUT
+%s
+END INPUT`
+
+// normalizeShellInput turns CRLF and bare CR line ends, as sent by terminals in
+// raw mode, into LF and drops the trailing line ends, leaving every other byte of
+// the lines, heredoc bodies included, untouched
+func normalizeShellInput(command string) string {
+	command = strings.ReplaceAll(command, "\r\n", "\n")
+	command = strings.ReplaceAll(command, "\r", "\n")
+	return strings.TrimRight(command, "\n")
+}
+
+// shellInput is the user message of an SSH command: normalized and, with
+// WrapMultilineInput, framed as one input when it spans several lines
+func (llm *LLMHoneypot) shellInput(command string) string {
+	command = normalizeShellInput(command)
+	lines := strings.Count(command, "\n") + 1
+	if !llm.WrapMultilineInput || lines == 1 {
+		return command
+	}
+	return fmt.Sprintf(multilineInputFraming, lines, command)
+}
@@ -0,0 +1,86 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPromptNormalizesMultilineInput(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH}
+
+	//When
+	prompt, err := honeypot.buildPrompt("cat > /tmp/x.sh <<'EOF'\r\n#!/bin/sh\r\n  echo \"$HOME\"  \r\nEOF\r\n")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "cat > /tmp/x.sh <<'EOF'\n#!/bin/sh\n  echo \"$HOME\"  \nEOF", prompt[len(prompt)-1].Content)
+}
+
+func TestBuildPromptWrapsHeredoc(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, WrapMultilineInput: true}
+	heredoc := "cat <<EOF | tr a-z A-Z\nhello\nworld\nEOF"
+
+	//When
+	prompt, err := honeypot.buildPrompt(heredoc + "\n")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "The following 4 lines were pasted into the shell as a single input, between the BEGIN INPUT and END INPUT markers. "+
+		"Bash reads them in order: a heredoc body up to its terminator is the standard input of its command, and a line ending with \\, | or && continues on the next line. "+
+		"Reply only with the terminal output of the whole input, without echoing it.\nBEGIN INPUT\n"+heredoc+"\nEND INPUT", prompt[len(prompt)-1].Content)
+}
+
+func TestBuildPromptDoesNotWrapSingleLinePipeline(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, WrapMultilineInput: true}
+
+	//When
+	prompt, err := honeypot.buildPrompt("cat /etc/passwd | grep root | cut -d: -f1\r\n")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "cat /etc/passwd | grep root | cut -d: -f1", prompt[len(prompt)-1].Content)
+}
+
+func TestExecuteModelMultilinePipeline(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var sent string
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			sent = body.Messages[len(body.Messages)-1].Content
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"root\nwww-data"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:           tracer.SSH,
+		Model:              "gpt-4o",
+		Provider:           OpenAI,
+		OpenAIKey:          "sdjdnklfjndslkjanfk",
+		WrapMultilineInput: true,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("cat /etc/passwd |\r\n  grep -v nologin |\r\n  cut -d: -f1\r\n")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "root\nwww-data", str)
+	assert.Contains(t, sent, "The following 3 lines were pasted")
+	assert.Contains(t, sent, "BEGIN INPUT\ncat /etc/passwd |\n  grep -v nologin |\n  cut -d: -f1\nEND INPUT")
+}
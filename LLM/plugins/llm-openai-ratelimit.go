@@ -0,0 +1,97 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+// OpenAIRateLimitState is the request quota reported by the x-ratelimit-*-requests
+// headers of the last OpenAI answer. UpdatedAt is zero until the first answer
+type OpenAIRateLimitState struct {
+	LimitRequests     int
+	RemainingRequests int
+	// ResetRequests is when the quota is refilled
+	ResetRequests time.Time
+	UpdatedAt     time.Time
+}
+
+// the quota belongs to the organization, not to one of the per-request honeypots
+var openAIQuota struct {
+	mu    sync.Mutex
+	state OpenAIRateLimitState
+}
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace: "beelzebub",
+	Name:      "llm_openai_remaining_requests",
+	Help:      "The OpenAI requests left in the current rate limit window, -1 before the first answer",
+}, func() float64 {
+	state := OpenAIRateLimit()
+	if state.UpdatedAt.IsZero() {
+		return -1
+	}
+	return float64(state.RemainingRequests)
+})
+
+// OpenAIRateLimit returns the last rate limit state seen from OpenAI
+func OpenAIRateLimit() OpenAIRateLimitState {
+	openAIQuota.mu.Lock()
+	defer openAIQuota.mu.Unlock()
+	return openAIQuota.state
+}
+
+// recordOpenAIRateLimit keeps the quota headers of an OpenAI answer, 429s included
+func (llm *LLMHoneypot) recordOpenAIRateLimit(header http.Header) {
+	if llm.Provider != OpenAI {
+		return
+	}
+	remaining, err := strconv.Atoi(header.Get("x-ratelimit-remaining-requests"))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	state := OpenAIRateLimitState{RemainingRequests: remaining, ResetRequests: now, UpdatedAt: now}
+	state.LimitRequests, _ = strconv.Atoi(header.Get("x-ratelimit-limit-requests"))
+	// OpenAI writes the reset as a Go-like duration: "1s", "6m0s", "20ms"
+	if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-requests")); err == nil {
+		state.ResetRequests = now.Add(reset)
+	}
+
+	openAIQuota.mu.Lock()
+	openAIQuota.state = state
+	openAIQuota.mu.Unlock()
+}
+
+// waitOpenAIRateLimit holds an OpenAI call until the quota reset when the last
+// answer left no request, instead of firing it into a 429
+func (llm *LLMHoneypot) waitOpenAIRateLimit(ctx context.Context) error {
+	if llm.Provider != OpenAI {
+		return nil
+	}
+	state := OpenAIRateLimit()
+	if state.UpdatedAt.IsZero() || state.RemainingRequests > 0 {
+		return nil
+	}
+	wait := time.Until(state.ResetRequests)
+	if wait <= 0 {
+		return nil
+	}
+	log.Debugf("OpenAI request quota exhausted, waiting %s for the reset", wait)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return errors.Join(ErrRateLimited, ctx.Err())
+	}
+}
@@ -0,0 +1,95 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIRateLimitHeadersPaceCalls(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+	defer func() { openAIQuota.state = OpenAIRateLimitState{} }()
+
+	// Given
+	var calledAt []time.Time
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			calledAt = append(calledAt, time.Now())
+			resp := newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`)
+			resp.Header.Set("x-ratelimit-limit-requests", "500")
+			resp.Header.Set("x-ratelimit-remaining-requests", "0")
+			resp.Header.Set("x-ratelimit-reset-requests", "200ms")
+			return resp, nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+
+	//When
+	_, first := honeypot.ExecuteModel("ls")
+	state := OpenAIRateLimit()
+	_, second := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, first)
+	assert.Nil(t, second)
+	assert.Equal(t, 500, state.LimitRequests)
+	assert.Equal(t, 0, state.RemainingRequests)
+	assert.WithinDuration(t, state.UpdatedAt.Add(200*time.Millisecond), state.ResetRequests, time.Millisecond)
+	assert.Len(t, calledAt, 2)
+	assert.GreaterOrEqual(t, calledAt[1].Sub(calledAt[0]), 150*time.Millisecond)
+}
+
+func TestOpenAIRateLimitWaitBoundedByContext(t *testing.T) {
+	defer func() { openAIQuota.state = OpenAIRateLimitState{} }()
+
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	})
+	header := http.Header{}
+	header.Set("x-ratelimit-remaining-requests", "0")
+	header.Set("x-ratelimit-reset-requests", "6m0s")
+	honeypot.recordOpenAIRateLimit(header)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	//When
+	err := honeypot.waitOpenAIRateLimit(ctx)
+
+	//Then
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOpenAIRateLimitIgnoresOtherProviders(t *testing.T) {
+	defer func() { openAIQuota.state = OpenAIRateLimitState{} }()
+
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Model: "llama3", Provider: Ollama})
+	header := http.Header{}
+	header.Set("x-ratelimit-remaining-requests", "0")
+
+	//When
+	honeypot.recordOpenAIRateLimit(header)
+
+	//Then
+	assert.True(t, OpenAIRateLimit().UpdatedAt.IsZero())
+}
@@ -0,0 +1,54 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const otelInstrumentationName = "github.com/mariocandela/beelzebub/v3/plugins"
+
+// startSpan opens the span of one interaction when TracerProvider is set, the
+// returned end closes it with the routed model, token counts and error
+func (llm *LLMHoneypot) startSpan(ctx context.Context, requestID string) (context.Context, func(Interaction)) {
+	if llm.TracerProvider == nil {
+		return ctx, func(Interaction) {}
+	}
+
+	ctx, span := llm.TracerProvider.Tracer(otelInstrumentationName).Start(ctx, "llm "+llm.Protocol.String(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("beelzebub.protocol", llm.Protocol.String()),
+			attribute.String("beelzebub.request_id", requestID),
+		),
+	)
+	return ctx, func(interaction Interaction) {
+		span.SetAttributes(
+			attribute.String("gen_ai.system", interaction.Provider.String()),
+			attribute.String("gen_ai.request.model", interaction.Model),
+			attribute.Int("gen_ai.usage.input_tokens", interaction.Usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", interaction.Usage.CompletionTokens),
+		)
+		if interaction.Err != nil {
+			span.RecordError(interaction.Err)
+			span.SetStatus(codes.Error, interaction.Err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+	}
+}
+
+// injectTraceContext adds the traceparent of the span in ctx to req, with the
+// propagator registered through otel.SetTextMapPropagator
+func (llm *LLMHoneypot) injectTraceContext(ctx context.Context, req *resty.Request) {
+	if llm.TracerProvider == nil {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestExecuteModelOpenTelemetrySpan(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(previous)
+
+	// Given
+	var traceparent string
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			traceparent = req.Header.Get("Traceparent")
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`), nil
+		},
+	)
+
+	recorder := tracetest.NewSpanRecorder()
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "gpt-4o",
+		Provider:       OpenAI,
+		OpenAIKey:      "sdjdnklfjndslkjanfk",
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "llm SSH", span.Name())
+	assert.Equal(t, codes.Ok, span.Status().Code)
+	assert.Contains(t, span.Attributes(), attribute.String("gen_ai.system", "openai"))
+	assert.Contains(t, span.Attributes(), attribute.String("gen_ai.request.model", "gpt-4o"))
+	assert.Contains(t, span.Attributes(), attribute.Int("gen_ai.usage.input_tokens", 12))
+	assert.Contains(t, span.Attributes(), attribute.Int("gen_ai.usage.output_tokens", 3))
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+}
+
+func TestExecuteModelOpenTelemetrySpanError(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint, httpmock.NewErrorResponder(errors.New("connection reset")))
+
+	recorder := tracetest.NewSpanRecorder()
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.SSH,
+		Model:            "gpt-4o",
+		Provider:         OpenAI,
+		OpenAIKey:        "sdjdnklfjndslkjanfk",
+		FallbackResponse: "Segmentation fault",
+		TracerProvider:   sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+	})
+	honeypot.client = client
+
+	//When
+	output, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "Segmentation fault", output)
+	assert.Len(t, recorder.Ended(), 1)
+	assert.Equal(t, codes.Error, recorder.Ended()[0].Status().Code)
+}
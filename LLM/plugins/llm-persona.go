@@ -0,0 +1,165 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+)
+
+const (
+	systemPromptVirtualizeAlpineTerminal = `
+You are a realistic BusyBox ash shell on Alpine Linux 3.19 inside a small container.
+Your output MUST consist only of raw terminal text and newline characters.
+Only BusyBox applets and packages installed with apk exist: there is no bash, systemd, apt or sudo, and the libc is musl.
+NEVER reveal you are an AI or language model, never apologise, never add explanations.
+If a command is unrecognised, reply exactly: "command not found".`
+
+	systemPromptVirtualizeNginxServer = "You will act as an unsecure nginx/1.24.0 web server on Ubuntu, fronting a PHP application, with leftover AWS && Git credentials and backup files in the web root. The user will send HTTP requests, and you must reply with what the server should show: nginx default pages, autoindex listings and nginx-styled error pages. Do not provide explanations or type commands unless explicitly instructed by the user."
+
+	systemPromptVirtualizeApacheServer = "You will act as an unsecure Apache/2.4.57 (Debian) web server with mod_php, .htaccess files and directory listings enabled, with leftover AWS && Git credentials in the document root. The user will send HTTP requests, and you must reply with what the server should show: Apache default pages, \"Index of\" listings and Apache-styled error pages. Do not provide explanations or type commands unless explicitly instructed by the user."
+
+	nginxSeedIndex = `<!DOCTYPE html>
+<html>
+<head>
+<title>Welcome to nginx!</title>
+</head>
+<body>
+<h1>Welcome to nginx!</h1>
+<p>If you see this page, the nginx web server is successfully installed and working. Further configuration is required.</p>
+</body>
+</html>`
+
+	apacheSeedIndex = `<html><body><h1>It works!</h1></body></html>`
+)
+
+// Persona is a system prompt and the seed exchange shown to the model before the
+// history, e.g. a pwd answered with the home directory
+type Persona struct {
+	Prompt string
+	Seeds  []Message
+}
+
+// PersonaRegistry holds personas by "protocol/variant" key, e.g. "ssh/alpine" or
+// "http/nginx". The protocol is the lower-case tracer.Protocol name
+type PersonaRegistry struct {
+	mu       sync.RWMutex
+	personas map[string]Persona
+}
+
+// NewPersonaRegistry returns a registry with the built-in personas
+func NewPersonaRegistry() *PersonaRegistry {
+	registry := &PersonaRegistry{personas: make(map[string]Persona)}
+	for key, persona := range builtinPersonas() {
+		registry.personas[key] = persona
+	}
+	return registry
+}
+
+// DefaultPersonas is used by honeypots without a Personas registry. Personas
+// registered on it at runtime are visible to every new request
+var DefaultPersonas = NewPersonaRegistry()
+
+// Register adds or replaces the persona of key
+func (r *PersonaRegistry) Register(key string, persona Persona) error {
+	if _, err := personaProtocol(key); err != nil {
+		return err
+	}
+	if strings.TrimSpace(persona.Prompt) == "" {
+		return fmt.Errorf("persona %s has an empty prompt", key)
+	}
+	persona.Seeds = append([]Message(nil), persona.Seeds...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.personas[key] = persona
+	return nil
+}
+
+// Lookup returns the persona of key
+func (r *PersonaRegistry) Lookup(key string) (Persona, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	persona, ok := r.personas[key]
+	persona.Seeds = append([]Message(nil), persona.Seeds...)
+	return persona, ok
+}
+
+// Keys returns the registered keys in order
+func (r *PersonaRegistry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.personas))
+	for key := range r.personas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// personaProtocol parses the protocol of a "protocol/variant" key
+func personaProtocol(key string) (tracer.Protocol, error) {
+	protocol, variant, ok := strings.Cut(key, "/")
+	if !ok || variant == "" {
+		return -1, fmt.Errorf("persona key %q is not protocol/variant", key)
+	}
+	return protocolFromString(protocol)
+}
+
+// personaKey is the Persona of the honeypot, or the built-in default of its
+// protocol, empty for a protocol out of the tracer range
+func (llm *LLMHoneypot) personaKey() string {
+	if llm.Persona != "" {
+		return llm.Persona
+	}
+	switch {
+	case llm.Protocol < tracer.HTTP || llm.Protocol > tracer.LDAP:
+		return ""
+	case llm.Protocol == tracer.HTTP && llm.WebSocket:
+		return "http/websocket"
+	case llm.Protocol == tracer.HTTP && llm.GitSmartHTTP:
+		return "http/git"
+	case llm.Protocol == tracer.SSH:
+		return "ssh/ubuntu-22.04"
+	}
+	return strings.ToLower(llm.Protocol.String()) + "/default"
+}
+
+func seeds(exchange ...string) []Message {
+	msgs := make([]Message, 0, len(exchange))
+	for i, content := range exchange {
+		role := USER
+		if i%2 == 1 {
+			role = ASSISTANT
+		}
+		msgs = append(msgs, Message{Role: role.String(), Content: content})
+	}
+	return msgs
+}
+
+func builtinPersonas() map[string]Persona {
+	return map[string]Persona{
+		// seed để model biết vị trí
+		"ssh/ubuntu-22.04": {systemPromptVirtualizeLinuxTerminal, seeds("pwd", "/home/user")},
+		"ssh/alpine":       {systemPromptVirtualizeAlpineTerminal, seeds("pwd", "/home/user")},
+		"http/default":     {systemPromptVirtualizeHTTPServer, seeds("GET /index.html", "<html><body>Hello, World!</body></html>")},
+		"http/nginx":       {systemPromptVirtualizeNginxServer, seeds("GET /index.html", nginxSeedIndex)},
+		"http/apache":      {systemPromptVirtualizeApacheServer, seeds("GET /index.html", apacheSeedIndex)},
+		"http/websocket": {systemPromptVirtualizeWebSocketServer, seeds(
+			websocketSeedUpgrade, websocketSeedSwitching,
+			`{"type":"ping"}`, `{"type":"pong","data":{"ts":1718031442}}`,
+		)},
+		"http/git":         {systemPromptVirtualizeGitSmartHTTPServer, seeds(gitSeedInfoRefs, gitSeedRefAdvertisement)},
+		"dns/default":      {systemPromptVirtualizeDNSServer, seeds("example.com A", "example.com.\t\t86400\tIN\tA\t93.184.216.34")},
+		"sip/default":      {systemPromptVirtualizeSIPServer, seeds(sipSeedRegister, sipSeedUnauthorized)},
+		"rdp/default":      {systemPromptVirtualizeRDPServer, seeds(rdpSeedConnectionRequest, rdpSeedConnectionConfirm)},
+		"vnc/default":      {systemPromptVirtualizeVNCServer, seeds("RFB 003.008", "RFB 003.008\nsecurity types: [2] VNC Authentication")},
+		"snmp/default":     {systemPromptVirtualizeSNMPAgent, seeds("GET 1.3.6.1.2.1.1.1.0", snmpSeedSysDescr)},
+		"postgres/default": {systemPromptVirtualizePostgresServer, seeds("SELECT version();", postgresSeedVersion)},
+		"imap/default":     {systemPromptVirtualizeIMAPServer, seeds("A001 CAPABILITY", imapSeedCapability)},
+		"pop3/default":     {systemPromptVirtualizePOP3Server, seeds("CAPA", pop3SeedCapability)},
+		"ldap/default":     {systemPromptVirtualizeLDAPServer, seeds(ldapSeedRootDSEQuery, ldapSeedRootDSE)},
+	}
+}
@@ -0,0 +1,96 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPromptBuiltinPersona(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.HTTP, Persona: "http/nginx"}
+
+	//When
+	prompt, err := honeypot.buildPrompt("GET /admin")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, systemPromptVirtualizeNginxServer, prompt[0].Content)
+	assert.Equal(t, nginxSeedIndex, prompt[2].Content)
+	assert.Equal(t, "GET /admin", prompt[3].Content)
+}
+
+func TestBuildPromptDefaultPersonaKeys(t *testing.T) {
+	tests := []struct {
+		honeypot LLMHoneypot
+		key      string
+	}{
+		{LLMHoneypot{Protocol: tracer.SSH}, "ssh/ubuntu-22.04"},
+		{LLMHoneypot{Protocol: tracer.HTTP}, "http/default"},
+		{LLMHoneypot{Protocol: tracer.HTTP, WebSocket: true}, "http/websocket"},
+		{LLMHoneypot{Protocol: tracer.HTTP, GitSmartHTTP: true}, "http/git"},
+		{LLMHoneypot{Protocol: tracer.LDAP}, "ldap/default"},
+		{LLMHoneypot{Protocol: tracer.TCP}, "tcp/default"},
+		{LLMHoneypot{Protocol: tracer.SSH, Persona: "ssh/alpine", WebSocket: true}, "ssh/alpine"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.key, tt.honeypot.personaKey())
+	}
+}
+
+func TestBuildPromptCustomPersonaRegistry(t *testing.T) {
+	//Given
+	registry := NewPersonaRegistry()
+	err := registry.Register("ssh/freebsd", Persona{
+		Prompt: "You are a tcsh shell on FreeBSD 14.",
+		Seeds:  []Message{{Role: USER.String(), Content: "uname"}, {Role: ASSISTANT.String(), Content: "FreeBSD"}},
+	})
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, Persona: "ssh/freebsd", Personas: registry, TypoToleranceHint: true}
+
+	//When
+	prompt, promptErr := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Nil(t, promptErr)
+	assert.Equal(t, "You are a tcsh shell on FreeBSD 14."+typoToleranceHint, prompt[0].Content)
+	assert.Equal(t, "FreeBSD", prompt[2].Content)
+	assert.Contains(t, registry.Keys(), "ssh/alpine")
+	_, inDefault := DefaultPersonas.Lookup("ssh/freebsd")
+	assert.False(t, inDefault)
+}
+
+func TestBuildPromptPersonaErrors(t *testing.T) {
+	tests := []struct {
+		honeypot LLMHoneypot
+		err      string
+	}{
+		{LLMHoneypot{Protocol: tracer.SSH, Persona: "http/nginx"}, "persona http/nginx does not match the honeypot protocol"},
+		{LLMHoneypot{Protocol: tracer.SSH, Persona: "ssh/windows"}, "unknown persona ssh/windows"},
+		{LLMHoneypot{Protocol: tracer.SSH, Persona: "alpine"}, `persona key "alpine" is not protocol/variant`},
+		{LLMHoneypot{Protocol: tracer.TCP}, "no prompt for protocol selected"},
+	}
+	for _, tt := range tests {
+		_, err := tt.honeypot.buildPrompt("ls")
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestPersonaRegistryRegisterValidation(t *testing.T) {
+	//Given
+	registry := NewPersonaRegistry()
+
+	//When
+	badProtocol := registry.Register("ftp/vsftpd", Persona{Prompt: "You are vsftpd"})
+	emptyPrompt := registry.Register("http/iis", Persona{})
+	replaced := registry.Register("http/nginx", Persona{Prompt: "You are nginx/1.27.0"})
+	persona, _ := registry.Lookup("http/nginx")
+
+	//Then
+	assert.EqualError(t, badProtocol, "protocol ftp not supported")
+	assert.EqualError(t, emptyPrompt, "persona http/iis has an empty prompt")
+	assert.Nil(t, replaced)
+	assert.Equal(t, "You are nginx/1.27.0", persona.Prompt)
+	assert.Empty(t, persona.Seeds)
+}
@@ -0,0 +1,41 @@
+package plugins
+
+import "errors"
+
+// rejectedRequest marks an error of PreSendValidator or GeminiPreSendValidator.
+// It prints and unwraps as the validator error, and is neither retried nor counted
+// as a provider failure: the same request would be rejected again
+type rejectedRequest struct {
+	err error
+}
+
+func (e rejectedRequest) Error() string { return e.err.Error() }
+
+func (e rejectedRequest) Unwrap() error { return e.err }
+
+func isRejectedRequest(err error) bool {
+	var rejected rejectedRequest
+	return errors.As(err, &rejected)
+}
+
+// validateRequest runs PreSendValidator on an OpenAI, compatible or Ollama request
+func (llm *LLMHoneypot) validateRequest(req Request) error {
+	if llm.PreSendValidator == nil {
+		return nil
+	}
+	if err := llm.PreSendValidator(req); err != nil {
+		return rejectedRequest{err}
+	}
+	return nil
+}
+
+// validateGeminiRequest runs GeminiPreSendValidator on a Gemini or Vertex AI request
+func (llm *LLMHoneypot) validateGeminiRequest(req GeminiRequest) error {
+	if llm.GeminiPreSendValidator == nil {
+		return nil
+	}
+	if err := llm.GeminiPreSendValidator(req); err != nil {
+		return rejectedRequest{err}
+	}
+	return nil
+}
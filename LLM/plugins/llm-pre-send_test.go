@@ -0,0 +1,137 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreSendValidatorAbortsCall(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	wait := sleepContext
+	sleepContext = func(context.Context, time.Duration) error { return nil }
+	defer func() { sleepContext = wait }()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	errTooManyMessages := errors.New("too many messages")
+	validations, limit := 0, 0
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:   tracer.SSH,
+		Model:      "gpt-4o",
+		Provider:   OpenAI,
+		OpenAIKey:  "sdjdnklfjndslkjanfk",
+		MaxRetries: 3,
+		PreSendValidator: func(req Request) error {
+			validations++
+			if limit == 0 {
+				// the history of the first command pushes the second over the limit
+				limit = len(req.Messages)
+			}
+			if len(req.Messages) > limit {
+				return errTooManyMessages
+			}
+			return nil
+		},
+	})
+	honeypot.client = client
+
+	//When
+	first, firstErr := honeypot.ExecuteModel("ls")
+	_, secondErr := honeypot.ExecuteModel("pwd")
+
+	//Then
+	assert.Nil(t, firstErr)
+	assert.Equal(t, "prova.txt", first)
+	assert.ErrorIs(t, secondErr, errTooManyMessages)
+	assert.Equal(t, "too many messages", secondErr.Error())
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	// a rejected request is not retried
+	assert.Equal(t, 2, validations)
+}
+
+func TestPreSendValidatorSeesOllamaRequest(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var seen Request
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "llama3",
+		Provider:  Ollama,
+		KeepAlive: "10m",
+		PreSendValidator: func(req Request) error {
+			seen = req
+			return errors.New("blocked")
+		},
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.EqualError(t, err, "blocked")
+	assert.Equal(t, "llama3", seen.Model)
+	assert.Equal(t, "10m", seen.KeepAlive)
+	assert.Equal(t, "ls", seen.Messages[len(seen.Messages)-1].Content)
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
+
+func TestGeminiPreSendValidatorAbortsCall(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	delete(providerBreakers, Gemini)
+
+	// Given
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-pro"),
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:        tracer.SSH,
+		Model:           "gemini-pro",
+		Provider:        Gemini,
+		GoogleAPIKey:    "sdjdnklfjndslkjanfk",
+		BreakerFailures: 1,
+		GeminiPreSendValidator: func(req GeminiRequest) error {
+			if req.GenerationConfig.MaxOutputTokens > 1024 {
+				return fmt.Errorf("maxOutputTokens %d over the limit", req.GenerationConfig.MaxOutputTokens)
+			}
+			return nil
+		},
+	})
+	honeypot.client = client
+
+	//When
+	_, first := honeypot.ExecuteModel("ls")
+	_, second := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.EqualError(t, first, "maxOutputTokens 2048 over the limit")
+	// the breaker did not open on the rejection
+	assert.EqualError(t, second, "maxOutputTokens 2048 over the limit")
+	assert.Equal(t, 0, httpmock.GetTotalCallCount())
+}
@@ -0,0 +1,149 @@
+package plugins
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PIIDetector returns the substrings of text to hide from the provider, DetectPII
+// is the default used by PrivacyFilter
+type PIIDetector func(text string) []string
+
+var (
+	piiEmail = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// 13 to 19 digits, optionally grouped by spaces or dashes like on the card
+	piiCardNumber = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	piiIPv4       = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	piiToken      = regexp.MustCompile(`pii-[0-9a-f]{8}`)
+	// piiTokenPrefix matches the end of a chunk that may be the start of a token
+	piiTokenPrefix = regexp.MustCompile(`p(?:i(?:i(?:-[0-9a-f]{0,7})?)?)?$`)
+)
+
+// piiKey salts the tokens, so that the provider cannot brute-force a private IP
+// back from its hash. Tokens are stable for the life of the process
+var piiKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// DetectPII finds email addresses, card numbers passing the Luhn check and private
+// (RFC 1918, loopback excluded) IPv4 addresses
+func DetectPII(text string) []string {
+	found := piiEmail.FindAllString(text, -1)
+	for _, number := range piiCardNumber.FindAllString(text, -1) {
+		if luhnValid(number) {
+			found = append(found, number)
+		}
+	}
+	for _, addr := range piiIPv4.FindAllString(text, -1) {
+		if ip := net.ParseIP(addr); ip != nil && ip.IsPrivate() {
+			found = append(found, addr)
+		}
+	}
+	return found
+}
+
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func piiTokenFor(value string) string {
+	mac := hmac.New(sha256.New, piiKey)
+	mac.Write([]byte(value))
+	return "pii-" + hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// piiTokens maps the tokens of a prompt back to the values they replaced
+type piiTokens map[string]string
+
+// tokenizePII replaces the PII of msgs with tokens. Only the leading system prompt
+// is the operator's and is sent as is, the later system messages carry the session
+// state, the scenario and the summary, all made of what the attacker sent. The
+// history keeps the real values, it is tokenized again at every turn
+func (llm *LLMHoneypot) tokenizePII(msgs []Message) ([]Message, piiTokens) {
+	detect := llm.PrivacyDetector
+	if detect == nil {
+		detect = DetectPII
+	}
+	tokens := piiTokens{}
+	filtered := make([]Message, len(msgs))
+	for i, m := range msgs {
+		if i > 0 || m.Role != SYSTEM.String() {
+			found := detect(m.Content)
+			// longest first, so that an IP inside an email is not split
+			sort.Slice(found, func(a, b int) bool { return len(found[a]) > len(found[b]) })
+			for _, value := range found {
+				if value == "" {
+					continue
+				}
+				token := piiTokenFor(value)
+				tokens[token] = value
+				m.Content = strings.ReplaceAll(m.Content, value, token)
+			}
+		}
+		filtered[i] = m
+	}
+	return filtered, tokens
+}
+
+// restore puts back the values of the tokens the model echoed
+func (tokens piiTokens) restore(output string) string {
+	if len(tokens) == 0 {
+		return output
+	}
+	return piiToken.ReplaceAllStringFunc(output, func(token string) string {
+		if value, ok := tokens[token]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// stream restores the tokens of streamed chunks, holding back a tail that may be
+// a token split across chunks. flush sends what is left
+func (tokens piiTokens) stream(onChunk func(string)) (write func(string), flush func()) {
+	if len(tokens) == 0 {
+		return onChunk, func() {}
+	}
+	var pending string
+	write = func(chunk string) {
+		pending += chunk
+		cut := len(pending)
+		if loc := piiTokenPrefix.FindStringIndex(pending); loc != nil {
+			cut = loc[0]
+		}
+		if cut > 0 {
+			onChunk(tokens.restore(pending[:cut]))
+			pending = pending[cut:]
+		}
+	}
+	flush = func() {
+		if pending != "" {
+			onChunk(tokens.restore(pending))
+			pending = ""
+		}
+	}
+	return write, flush
+}
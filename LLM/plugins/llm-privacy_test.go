@@ -0,0 +1,151 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteModelPrivacyFilter(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var sent string
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			sent = body.Messages[len(body.Messages)-1].Content
+			// the model echoes the host of the command
+			host := regexp.MustCompile(`pii-[0-9a-f]{8}`).FindAllString(sent, -1)[1]
+			content, _ := json.Marshal("ssh: connect to host " + host + " port 22: Connection refused")
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":` + string(content) + `}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Model:         "gpt-4o",
+		Provider:      OpenAI,
+		OpenAIKey:     "sdjdnklfjndslkjanfk",
+		PrivacyFilter: true,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("echo admin@corp.example | ssh root@192.168.1.10 -J 8.8.8.8")
+
+	//Then
+	assert.Nil(t, err)
+	assert.NotContains(t, sent, "admin@corp.example")
+	assert.NotContains(t, sent, "192.168.1.10")
+	assert.Contains(t, sent, "8.8.8.8")
+	assert.Equal(t, "ssh: connect to host 192.168.1.10 port 22: Connection refused", str)
+	history := honeypot.history()
+	assert.Equal(t, str, history[len(history)-1].Content)
+}
+
+func TestDetectPII(t *testing.T) {
+	//When
+	found := DetectPII("mail bob@example.org card 4111 1111 1111 1111 order 1234567890123 hosts 10.0.0.5 172.32.0.1 127.0.0.1")
+
+	//Then
+	assert.Equal(t, []string{"bob@example.org", "4111 1111 1111 1111", "10.0.0.5"}, found)
+}
+
+func TestTokenizePIICustomDetector(t *testing.T) {
+	//Given
+	honeypot := &LLMHoneypot{PrivacyDetector: func(text string) []string {
+		if strings.Contains(text, "hunter2") {
+			return []string{"hunter2"}
+		}
+		return nil
+	}}
+	msgs := []Message{
+		{Role: SYSTEM.String(), Content: "the password is hunter2"},
+		{Role: SYSTEM.String(), Content: "Bash history:\nexport PASS=hunter2"},
+		{Role: USER.String(), Content: "echo hunter2"},
+	}
+
+	//When
+	filtered, tokens := honeypot.tokenizePII(msgs)
+
+	//Then
+	assert.Equal(t, "the password is hunter2", filtered[0].Content)
+	assert.Equal(t, "Bash history:\nexport PASS="+piiTokenFor("hunter2"), filtered[1].Content)
+	assert.Equal(t, "echo "+piiTokenFor("hunter2"), filtered[2].Content)
+	assert.Equal(t, "echo hunter2", msgs[2].Content)
+	assert.Equal(t, "hunter2", tokens.restore(filtered[2].Content)[5:])
+}
+
+func TestSummarizeHistoryPrivacyFilter(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var sent string
+	token := piiTokenFor("10.0.0.5")
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			sent = body.Messages[len(body.Messages)-1].Content
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"attacker pinged ` + token + `"}}`), nil
+		},
+	)
+
+	var histories []Message
+	for i := 0; i < 6; i++ {
+		histories = append(histories, Message{Role: ASSISTANT.String(), Content: "PING 10.0.0.5 56 bytes"})
+	}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Histories:          histories,
+		HistorySummary:     "mail sent to bob@example.org",
+		Protocol:           tracer.SSH,
+		Model:              "llama3",
+		Provider:           Ollama,
+		SummarizeThreshold: 4,
+		PrivacyFilter:      true,
+	})
+	honeypot.client = client
+
+	//When
+	err := honeypot.summarizeHistory(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.NotContains(t, sent, "10.0.0.5")
+	assert.NotContains(t, sent, "bob@example.org")
+	assert.Contains(t, sent, token)
+	assert.Equal(t, "attacker pinged 10.0.0.5", honeypot.HistorySummary)
+}
+
+func TestPIITokensStreamSplitToken(t *testing.T) {
+	//Given
+	token := piiTokenFor("10.0.0.5")
+	tokens := piiTokens{token: "10.0.0.5"}
+	var chunks []string
+	write, flush := tokens.stream(func(chunk string) { chunks = append(chunks, chunk) })
+
+	//When
+	write("PING " + token[:2])
+	write(token[2:7])
+	write(token[7:] + " (")
+	write("...) 56 bytes of data. p")
+	flush()
+
+	//Then
+	assert.Equal(t, "PING 10.0.0.5 (...) 56 bytes of data. p", strings.Join(chunks, ""))
+	assert.Equal(t, "PING ", chunks[0])
+}
@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	geminiCachedContentsEndpoint = "https://generativelanguage.googleapis.com" + geminiCachedContentsPath
+	geminiCachedContentsPath     = "/v1beta/cachedContents"
+	vertexCachedContentsEndpoint = "https://%[1]s-aiplatform.googleapis.com/v1/projects/%[2]s/locations/%[1]s/cachedContents"
+	promptCacheTTL               = time.Hour
+	// promptCacheRetry waits before trying again a persona Gemini refused to cache,
+	// usually because it is below the minimum cacheable size of the model
+	promptCacheRetry = 10 * time.Minute
+)
+
+var promptCacheNow = time.Now
+
+type geminiCachedContent struct {
+	Name              string          `json:"name,omitempty"`
+	Model             string          `json:"model,omitempty"`
+	SystemInstruction *GeminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent `json:"contents,omitempty"`
+	TTL               string          `json:"ttl,omitempty"`
+}
+
+type cachedPrompt struct {
+	name    string
+	expires time.Time
+}
+
+// geminiPromptCaches maps an endpoint, model and persona to its cached content, shared
+// by the honeypots of every request like the rate limiters. An empty name is a refusal
+var geminiPromptCaches sync.Map
+
+// geminiPromptCache returns the cached content holding the system prompt of msgs and
+// the messages left to send. Only the system prompt is cached: it is the bulk of the
+// persona, while the short seeds stay in the request where they merge with the history.
+// The per-session system messages (Identity, State, ClientContext, Scenario) merged
+// into msgs[0] by callProvider are split off and sent as the first turn, so that the
+// key only depends on the static persona and every session shares its cache.
+// ok is false when caching is not possible and the request is then sent whole.
+// Templates rendering {{.Date}} change the prompt every second and defeat the cache
+func (llm *LLMHoneypot) geminiPromptCache(ctx context.Context, msgs []Message) (name string, rest []Message, ok bool) {
+	persona, err := llm.persona()
+	if err != nil || len(msgs) < 2 || msgs[0].Role != SYSTEM.String() {
+		return "", nil, false
+	}
+	system := persona[0].Content
+	rest = msgs[1:]
+	switch {
+	case msgs[0].Content == system:
+	case strings.HasPrefix(msgs[0].Content, system+"\n\n"):
+		session := Message{Role: SYSTEM.String(), Content: strings.TrimPrefix(msgs[0].Content, system+"\n\n")}
+		rest = append([]Message{session}, rest...)
+	default:
+		return "", nil, false
+	}
+
+	url, model, auth, err := llm.geminiCacheTarget(ctx)
+	if err != nil {
+		log.Warnf("gemini prompt cache disabled: %s", err.Error())
+		return "", nil, false
+	}
+	key := promptCacheKey(url, model, system)
+	now := promptCacheNow()
+	if cached, found := geminiPromptCaches.Load(key); found && now.Before(cached.(cachedPrompt).expires) {
+		name = cached.(cachedPrompt).name
+		return name, rest, name != ""
+	}
+
+	name, err = llm.createGeminiCache(ctx, url, model, auth, system)
+	if err != nil {
+		log.Warnf("gemini prompt cache not created: %s", err.Error())
+		geminiPromptCaches.Store(key, cachedPrompt{expires: now.Add(promptCacheRetry)})
+		return "", nil, false
+	}
+	// the cache is dropped a minute before Gemini expires it, so a request never races it
+	geminiPromptCaches.Store(key, cachedPrompt{name: name, expires: now.Add(promptCacheTTL - time.Minute)})
+	return name, rest, true
+}
+
+func (llm *LLMHoneypot) geminiCacheTarget(ctx context.Context) (url, model string, auth func(*resty.Request), err error) {
+	if !llm.VertexAI {
+		key, err := llm.apiKey(ctx, googleAPIKey)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if key == "" {
+			return "", "", nil, errors.New("googleAPIKey is empty")
+		}
+		// Host replaces Google like for generateContent, the persona and key go nowhere else
+		url = geminiCachedContentsEndpoint
+		if llm.Host != "" {
+			url = strings.TrimSuffix(llm.Host, "/") + geminiCachedContentsPath
+		}
+		return url, "models/" + llm.Model, func(req *resty.Request) {
+			req.SetQueryParam("key", key)
+		}, nil
+	}
+
+	if llm.GCPProject == "" || llm.GCPRegion == "" {
+		return "", "", nil, errors.New("gcpProject and gcpRegion are required for Vertex AI")
+	}
+	token, err := llm.gcpTokenSource().Token(ctx)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("vertex AI token: %v", err)
+	}
+	url = fmt.Sprintf(vertexCachedContentsEndpoint, llm.GCPRegion, llm.GCPProject)
+	model = fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", llm.GCPProject, llm.GCPRegion, llm.Model)
+	return url, model, func(req *resty.Request) { req.SetAuthToken(token) }, nil
+}
+
+// createGeminiCache stores the system prompt as the system instruction of a cached content
+func (llm *LLMHoneypot) createGeminiCache(ctx context.Context, url, model string, auth func(*resty.Request), system string) (string, error) {
+	reqJSON, err := json.Marshal(geminiCachedContent{
+		Model:             model,
+		SystemInstruction: &GeminiContent{Role: "user", Parts: []GeminiPart{{Text: system}}},
+		TTL:               fmt.Sprintf("%ds", int(promptCacheTTL.Seconds())),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req := llm.newRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(reqJSON).
+		SetResult(&geminiCachedContent{})
+	auth(req)
+	resp, err := req.Post(url)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode() != 200 {
+		return "", fmt.Errorf("%s – %s", resp.Status(), resp.String())
+	}
+	name := resp.Result().(*geminiCachedContent).Name
+	if name == "" {
+		return "", errors.New("no name in cached content response")
+	}
+	return name, nil
+}
+
+// promptCacheKey includes the endpoint, a cache created behind Host does not exist at Google
+func promptCacheKey(url, model, system string) string {
+	hash := sha256.Sum256([]byte(url + "\x00" + model + "\x00" + system))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,198 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeminiCachePrompt(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var cacheRequests []geminiCachedContent
+	httpmock.RegisterResponder("POST", geminiCachedContentsEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body geminiCachedContent
+			json.NewDecoder(req.Body).Decode(&body)
+			cacheRequests = append(cacheRequests, body)
+			return newJSONStringResponse(`{"name":"cachedContents/persona-1"}`), nil
+		},
+	)
+	var bodies []GeminiRequest
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-cache-test"),
+		func(req *http.Request) (*http.Response, error) {
+			var body GeminiRequest
+			json.NewDecoder(req.Body).Decode(&body)
+			bodies = append(bodies, body)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-cache-test",
+		Provider:     Gemini,
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+		CachePrompt:  true,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+	assert.Nil(t, err)
+	_, err = honeypot.ExecuteModel("id")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Len(t, cacheRequests, 1)
+	assert.Equal(t, "models/gemini-cache-test", cacheRequests[0].Model)
+	assert.Equal(t, systemPromptVirtualizeLinuxTerminal, cacheRequests[0].SystemInstruction.Parts[0].Text)
+	assert.Empty(t, cacheRequests[0].Contents)
+	assert.Equal(t, "3600s", cacheRequests[0].TTL)
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, "cachedContents/persona-1", bodies[1].CachedContent)
+	assert.Equal(t, "id", bodies[1].Contents[len(bodies[1].Contents)-1].Parts[0].Text)
+	assert.Equal(t, "pwd", bodies[1].Contents[0].Parts[0].Text)
+}
+
+func TestGeminiCachePromptRefused(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+	promptCacheNow = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { promptCacheNow = time.Now }()
+
+	// Given
+	httpmock.RegisterResponder("POST", geminiCachedContentsEndpoint,
+		httpmock.NewStringResponder(400, `{"error":{"message":"Cached content is too small"}}`))
+	var body GeminiRequest
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-cache-refused"),
+		func(req *http.Request) (*http.Response, error) {
+			json.NewDecoder(req.Body).Decode(&body)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-cache-refused",
+		Provider:     Gemini,
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+		CachePrompt:  true,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+	assert.Nil(t, err)
+	_, err = honeypot.ExecuteModel("id")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["POST "+geminiCachedContentsEndpoint])
+	assert.Equal(t, "", body.CachedContent)
+	assert.Contains(t, body.Contents[0].Parts[0].Text, systemPromptVirtualizeLinuxTerminal)
+}
+
+func TestGeminiCachePromptUsesHost(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", "http://gemini-proxy.local"+geminiCachedContentsPath,
+		httpmock.NewStringResponder(200, `{"name":"cachedContents/proxied"}`).HeaderSet(http.Header{"Content-Type": {"application/json"}}),
+	)
+	var cached string
+	httpmock.RegisterResponder("POST", "http://gemini-proxy.local"+fmt.Sprintf(geminiPath, "gemini-cache-host"),
+		func(req *http.Request) (*http.Response, error) {
+			var body GeminiRequest
+			json.NewDecoder(req.Body).Decode(&body)
+			cached = body.CachedContent
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:     tracer.SSH,
+		Model:        "gemini-cache-host",
+		Provider:     Gemini,
+		GoogleAPIKey: "sdjdnklfjndslkjanfk",
+		Host:         "http://gemini-proxy.local/",
+		CachePrompt:  true,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "cachedContents/proxied", cached)
+	assert.Zero(t, httpmock.GetCallCountInfo()["POST "+geminiCachedContentsEndpoint])
+}
+
+func TestGeminiCachePromptSharedBySessions(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var cacheRequests []geminiCachedContent
+	httpmock.RegisterResponder("POST", geminiCachedContentsEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body geminiCachedContent
+			json.NewDecoder(req.Body).Decode(&body)
+			cacheRequests = append(cacheRequests, body)
+			return newJSONStringResponse(`{"name":"cachedContents/persona-sessions"}`), nil
+		},
+	)
+	var bodies []GeminiRequest
+	httpmock.RegisterResponder("POST", fmt.Sprintf(geminiEndpoint, "gemini-cache-sessions"),
+		func(req *http.Request) (*http.Response, error) {
+			var body GeminiRequest
+			json.NewDecoder(req.Body).Decode(&body)
+			bodies = append(bodies, body)
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Model:         "gemini-cache-sessions",
+		Provider:      Gemini,
+		GoogleAPIKey:  "sdjdnklfjndslkjanfk",
+		CachePrompt:   true,
+		SeedAsContext: true,
+	})
+	honeypot.client = client
+	first, second := honeypot.NewSession(), honeypot.NewSession()
+
+	//When
+	_, firstErr := first.ExecuteModel("hostname")
+	_, secondErr := second.ExecuteModel("hostname")
+
+	//Then
+	assert.Nil(t, firstErr)
+	assert.Nil(t, secondErr)
+	// without seeds in between, the identity is merged into the persona by callProvider
+	assert.Len(t, cacheRequests, 1)
+	assert.Contains(t, cacheRequests[0].SystemInstruction.Parts[0].Text, systemPromptVirtualizeLinuxTerminal)
+	assert.NotContains(t, cacheRequests[0].SystemInstruction.Parts[0].Text, "fixed identity")
+	assert.Len(t, bodies, 2)
+	for i, session := range []*LLMHoneypot{first, second} {
+		assert.Equal(t, "cachedContents/persona-sessions", bodies[i].CachedContent)
+		assert.Contains(t, bodies[i].Contents[0].Parts[0].Text, session.Identity.PromptContext())
+		assert.NotContains(t, bodies[i].Contents[0].Parts[0].Text, systemPromptVirtualizeLinuxTerminal)
+	}
+}
@@ -0,0 +1,75 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PromptVariables fill {{.Hostname}}, {{.Username}}, {{.OSVersion}}, {{.Date}} and
+// {{.Locale}} in the system prompt. Empty fields fall back to the Identity of the session or
+// to Ubuntu server defaults, Date is always the current time
+type PromptVariables struct {
+	Hostname  string
+	Username  string
+	OSVersion string
+	Date      string
+	Locale    string
+}
+
+const (
+	defaultPromptHostname  = "ubuntu"
+	defaultPromptUsername  = "user"
+	defaultPromptOSVersion = "Ubuntu 22.04.4 LTS"
+	// promptDateLayout is the output of `date` on a server set to UTC
+	promptDateLayout = "Mon Jan _2 15:04:05 UTC 2006"
+)
+
+var promptNow = time.Now
+
+func (llm *LLMHoneypot) promptVariables() PromptVariables {
+	vars := llm.PromptVariables
+	if vars.Hostname == "" {
+		vars.Hostname = defaultPromptHostname
+		if llm.Identity != nil {
+			vars.Hostname = llm.Identity.Hostname
+		}
+	}
+	if vars.Username == "" {
+		vars.Username = defaultPromptUsername
+	}
+	if vars.OSVersion == "" {
+		vars.OSVersion = defaultPromptOSVersion
+	}
+	vars.Date = promptNow().UTC().Format(promptDateLayout)
+	vars.Locale = llm.Locale
+	return vars
+}
+
+// renderSystemPrompt executes prompt as a text/template, prompts without actions are returned as is
+func (llm *LLMHoneypot) renderSystemPrompt(prompt string) (string, error) {
+	if !strings.Contains(prompt, "{{") {
+		return prompt, nil
+	}
+	prompt = escapeHoneytokens(prompt)
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(prompt)
+	if err != nil {
+		return "", fmt.Errorf("parsing prompt template: %v", err)
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, llm.promptVariables()); err != nil {
+		return "", fmt.Errorf("rendering prompt template: %v", err)
+	}
+	return rendered.String(), nil
+}
+
+// localeInstruction is appended to the system prompt when a Locale is set
+func localeInstruction(locale string) string {
+	if locale == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nThe system is configured for the %s locale. Write human-readable content "+
+		"(dates, numbers, file and directory names, user names, messages and documents) as a machine "+
+		"set up for %s would show it, but keep commands, options, keywords and protocol syntax unchanged.", locale, locale)
+}
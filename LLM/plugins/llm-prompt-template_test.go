@@ -0,0 +1,70 @@
+package plugins
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPromptRendersTemplate(t *testing.T) {
+	//Given
+	promptNow = func() time.Time { return time.Date(2026, 3, 7, 9, 30, 0, 0, time.UTC) }
+	defer func() { promptNow = time.Now }()
+
+	honeypot := LLMHoneypot{
+		Protocol:        tracer.SSH,
+		CustomPrompt:    "You are {{.Username}}@{{.Hostname}} on {{.OSVersion}}, today is {{.Date}}",
+		PromptVariables: PromptVariables{Username: "deploy"},
+		Identity:        &MachineIdentity{Hostname: "web-07"},
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("date")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "You are deploy@web-07 on Ubuntu 22.04.4 LTS, today is Sat Mar  7 09:30:00 UTC 2026", prompt[0].Content)
+}
+
+func TestBuildPromptWithoutTemplate(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH}
+
+	//When
+	prompt, err := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, systemPromptVirtualizeLinuxTerminal, prompt[0].Content)
+}
+
+func TestBuildPromptInvalidTemplate(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, CustomPrompt: "You are {{.Shell}}"}
+
+	//When
+	_, err := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.ErrorContains(t, err, "rendering prompt template")
+}
+
+func TestBuildPromptWithLocale(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Protocol:     tracer.SSH,
+		CustomPrompt: "You are a server in {{.Locale}}",
+		Locale:       "de_DE",
+	}
+
+	//When
+	prompt, err := honeypot.buildPrompt("ls -la")
+
+	//Then
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(prompt[0].Content, "You are a server in de_DE\n\nThe system is configured for the de_DE locale."))
+	assert.Contains(t, prompt[0].Content, "keep commands, options, keywords and protocol syntax unchanged")
+}
@@ -0,0 +1,62 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited is returned when the provider rate limit is exhausted and RateLimitWait is off
+var ErrRateLimited = errors.New("llm rate limit exceeded")
+
+// providerLimiters is shared by every honeypot instance, the SSH and HTTP
+// strategies build a new LLMHoneypot per request so the bucket cannot live on it
+var (
+	providerLimitersMu sync.Mutex
+	providerLimiters   = make(map[LLMProvider]*rate.Limiter)
+)
+
+// limiterFor returns the bucket of the provider, updated to the latest limit and burst
+func limiterFor(provider LLMProvider, limit float64, burst int) *rate.Limiter {
+	providerLimitersMu.Lock()
+	defer providerLimitersMu.Unlock()
+
+	if burst <= 0 {
+		burst = 1
+	}
+	limiter, ok := providerLimiters[provider]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(limit), burst)
+		providerLimiters[provider] = limiter
+		return limiter
+	}
+	if limiter.Limit() != rate.Limit(limit) {
+		limiter.SetLimit(rate.Limit(limit))
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+	return limiter
+}
+
+// waitRateLimit takes a token for the provider, blocking until ctx is done when
+// RateLimitWait is set and failing fast with ErrRateLimited otherwise
+func (llm *LLMHoneypot) waitRateLimit(ctx context.Context) error {
+	if llm.RateLimit <= 0 {
+		return nil
+	}
+
+	limiter := limiterFor(llm.Provider, llm.RateLimit, llm.Burst)
+	if !llm.RateLimitWait {
+		if !limiter.Allow() {
+			return ErrRateLimited
+		}
+		return nil
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		return errors.Join(ErrRateLimited, err)
+	}
+	return nil
+}
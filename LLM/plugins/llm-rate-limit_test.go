@@ -0,0 +1,99 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildExecuteModelRateLimited(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		RateLimit: 0.001,
+		Burst:     1,
+	})
+	honeypot.client = client
+
+	//When
+	_, first := honeypot.ExecuteModel("ls")
+	_, second := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, first)
+	assert.ErrorIs(t, second, ErrRateLimited)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestBuildExecuteModelRateLimitWaitDeadline(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Model:         "llama3",
+		Provider:      Ollama,
+		RateLimit:     0.001,
+		Burst:         1,
+		RateLimitWait: true,
+	})
+	honeypot.client = client
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	//When
+	_, first := honeypot.ExecuteModelContext(ctx, "ls")
+	_, second := honeypot.ExecuteModelContext(ctx, "ls")
+
+	//Then
+	assert.Nil(t, first)
+	assert.ErrorIs(t, second, ErrRateLimited)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestRateLimitFromEnv(t *testing.T) {
+	//Given
+	os.Setenv("LLM_RATE_LIMIT", "2.5")
+	os.Setenv("LLM_RATE_BURST", "5")
+	defer os.Unsetenv("LLM_RATE_LIMIT")
+	defer os.Unsetenv("LLM_RATE_BURST")
+
+	//When
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+
+	//Then
+	assert.Equal(t, 2.5, honeypot.RateLimit)
+	assert.Equal(t, 5, honeypot.Burst)
+}
@@ -0,0 +1,61 @@
+package plugins
+
+import "sync"
+
+// maxRawResponseBytes bounds the body kept by LastRawResponse
+const maxRawResponseBytes = 64 * 1024
+
+// rawResponse is shared by the copies a call is served from (router and model
+// fallbacks), so that the honeypot sees the body of whichever model answered
+type rawResponse struct {
+	mu   sync.Mutex
+	body string
+}
+
+// LastRawResponse returns the untouched body of the last provider answer of this
+// honeypot, errors included, cut at 64 KiB. Streams keep the raw event stream, the
+// Exec provider its stdout. Empty before the first call
+func (llm *LLMHoneypot) LastRawResponse() string {
+	raw := llm.rawResponse()
+	raw.mu.Lock()
+	defer raw.mu.Unlock()
+	return raw.body
+}
+
+// rawResponse creates the box on first use, execute calls it before copying the honeypot
+func (llm *LLMHoneypot) rawResponse() *rawResponse {
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+	if llm.lastRaw == nil {
+		llm.lastRaw = &rawResponse{}
+	}
+	return llm.lastRaw
+}
+
+// keepRawResponse stores body in the box of the honeypot. Internal calls, like the
+// summary or the banner, run on a copy without box and leave the last answer alone
+func (llm *LLMHoneypot) keepRawResponse(body []byte) {
+	mu := llm.historyLock()
+	mu.Lock()
+	raw := llm.lastRaw
+	mu.Unlock()
+	if raw == nil {
+		return
+	}
+	raw.mu.Lock()
+	raw.body = truncateUTF8(string(body), maxRawResponseBytes)
+	raw.mu.Unlock()
+}
+
+// rawCapture keeps the first maxRawResponseBytes written to it, for streamed bodies
+type rawCapture struct {
+	buf []byte
+}
+
+func (c *rawCapture) Write(p []byte) (int, error) {
+	if room := maxRawResponseBytes - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
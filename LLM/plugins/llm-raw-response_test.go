@@ -0,0 +1,157 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastRawResponse(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	body := `{"choices":[{"message":{"role":"assistant","content":"\u001b[31mprova.txt\u001b[0m"}}]}`
+	failing := false
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			if failing {
+				return httpmock.NewStringResponse(500, `{"error":{"message":"overloaded"}}`), nil
+			}
+			return newJSONStringResponse(body), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		StripANSI: true,
+	})
+	honeypot.client = client
+	before := honeypot.LastRawResponse()
+
+	//When
+	str, _ := honeypot.ExecuteModel("ls")
+	raw := honeypot.LastRawResponse()
+	failing = true
+	honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Equal(t, "", before)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, body, raw)
+	assert.Equal(t, `{"error":{"message":"overloaded"}}`, honeypot.LastRawResponse())
+}
+
+func TestLastRawResponseSkipsInternalCalls(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	body := `{"message":{"role":"assistant","content":"prova.txt"}}`
+	httpmock.RegisterResponder("POST", ollamaEndpoint, httpmock.NewStringResponder(200, body))
+	var histories []Message
+	for i := 0; i < 6; i++ {
+		histories = append(histories, Message{Role: ASSISTANT.String(), Content: "out"})
+	}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Histories:          histories,
+		Protocol:           tracer.SSH,
+		Model:              "raw-internal-test",
+		Provider:           Ollama,
+		SummarizeThreshold: 4,
+		GenerateBanner:     true,
+	})
+	honeypot.client = client
+	honeypot.ExecuteModel("ls")
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		httpmock.NewStringResponder(200, `{"message":{"role":"assistant","content":"SSH-2.0-OpenSSH_7.4"}}`))
+
+	//When
+	err := honeypot.summarizeHistory(context.Background())
+	banner := honeypot.Greeting()
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "SSH-2.0-OpenSSH_7.4", banner)
+	assert.Equal(t, body, honeypot.LastRawResponse())
+}
+
+func TestLastRawResponseConcurrentFirstUse(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH})
+	done := make(chan struct{})
+
+	//When
+	go func() {
+		defer close(done)
+		honeypot.keepRawResponse([]byte("body"))
+	}()
+	honeypot.LastRawResponse()
+	<-done
+
+	//Then
+	assert.Contains(t, []string{"", "body"}, honeypot.LastRawResponse())
+}
+
+func TestLastRawResponseStreamAndFallbackModel(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	events := "data: {\"choices\":[{\"delta\":{\"content\":\"prova\"}}]}\n\ndata: [DONE]\n\n"
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			if body.Model == "gpt-5" {
+				return httpmock.NewStringResponse(404, `{"error":{"message":"The model gpt-5 does not exist"}}`), nil
+			}
+			return httpmock.NewStringResponse(200, events), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "gpt-5",
+		ModelFallbacks: []string{"gpt-4o"},
+		Provider:       OpenAI,
+		OpenAIKey:      "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModelStream(context.Background(), "ls", nil)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova", str)
+	assert.Equal(t, events, honeypot.LastRawResponse())
+}
+
+func TestRawCaptureIsBounded(t *testing.T) {
+	//Given
+	var capture rawCapture
+
+	//When
+	capture.Write([]byte(strings.Repeat("a", maxRawResponseBytes-1)))
+	n, err := capture.Write([]byte("bcd"))
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, maxRawResponseBytes, len(capture.buf))
+	assert.Equal(t, byte('b'), capture.buf[maxRawResponseBytes-1])
+}
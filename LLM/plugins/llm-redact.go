@@ -0,0 +1,53 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	redactedValue        = "[REDACTED]"
+	redactedSystemPrompt = "[system prompt redacted]"
+)
+
+var secretRedactions = []struct {
+	regex       *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`), "${1}" + redactedValue},
+	{regexp.MustCompile(`(?i)((?:password|passwd|pwd|secret|token|api_?key|aws_secret_access_key)(?:\\?")?\s*[=:]\s*(?:\\?")?)[^\s&"\\,;]+`), "${1}" + redactedValue},
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`), redactedValue},
+}
+
+// redactSecrets masks bearer tokens, password/token/key assignments and AWS access keys
+func redactSecrets(payload string) string {
+	for _, r := range secretRedactions {
+		payload = r.regex.ReplaceAllString(payload, r.replacement)
+	}
+	return payload
+}
+
+// logPayload writes a request body at debug level (LLM_DEBUG) without the system
+// prompts of msgs and without the secrets attackers typed, so logs are safe to retain
+func logPayload(ctx context.Context, payload []byte, msgs []Message) {
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+
+	redacted := string(payload)
+	for _, m := range msgs {
+		if m.Role != SYSTEM.String() || m.Content == "" {
+			continue
+		}
+		escaped, err := json.Marshal(m.Content)
+		if err != nil {
+			continue
+		}
+		redacted = strings.ReplaceAll(redacted, strings.Trim(string(escaped), `"`), redactedSystemPrompt)
+	}
+	log.WithField("request_id", RequestIDFromContext(ctx)).Debug(redactSecrets(redacted))
+}
@@ -0,0 +1,109 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrReplayMismatch is returned in replay mode when the command is not the next
+// recorded one, or the recording is over, and ReplayFallthrough is off
+var ErrReplayMismatch = errors.New("command does not match the recorded session")
+
+// recordedTurn is one line of a session recording, JSON Lines
+type recordedTurn struct {
+	Command  string `json:"command"`
+	Response string `json:"response"`
+}
+
+type sessionRecorder struct {
+	mu   sync.Mutex
+	path string
+}
+
+type sessionReplay struct {
+	mu    sync.Mutex
+	turns []recordedTurn
+	next  int
+}
+
+// RecordTo starts a recording at path, truncating it: every command answered
+// from now on is appended with its response, what the attacker saw minus the
+// EchoCommand prefix. Tool calls are not recorded
+func (llm *LLMHoneypot) RecordTo(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating session recording: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("creating session recording: %v", err)
+	}
+	llm.recorder = &sessionRecorder{path: path}
+	return nil
+}
+
+// ReplayFrom loads a recording made by RecordTo: the same command sequence gets
+// the recorded responses in order without calling the model
+func (llm *LLMHoneypot) ReplayFrom(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening session recording: %v", err)
+	}
+	defer file.Close()
+
+	replay := &sessionReplay{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var turn recordedTurn
+		if err := json.Unmarshal(scanner.Bytes(), &turn); err != nil {
+			return fmt.Errorf("session recording %s line %d: %v", path, line, err)
+		}
+		replay.turns = append(replay.turns, turn)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading session recording: %v", err)
+	}
+	llm.replay = replay
+	return nil
+}
+
+// record appends a turn, a failed write is logged by the caller and does not fail the command
+func (r *sessionRecorder) record(command, response string) error {
+	line, err := json.Marshal(recordedTurn{Command: command, Response: response})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// answer returns the recorded response when command is the next one recorded
+func (r *sessionReplay) answer(command string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.turns) {
+		return "", fmt.Errorf("%w: %q after the %d recorded commands", ErrReplayMismatch, command, len(r.turns))
+	}
+	if turn := r.turns[r.next]; turn.Command != command {
+		return "", fmt.Errorf("%w: got %q, recorded %q", ErrReplayMismatch, command, turn.Command)
+	}
+	r.next++
+	return r.turns[r.next-1].Response, nil
+}
@@ -0,0 +1,112 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplaySession(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			content, _ := json.Marshal("output of " + body.Messages[len(body.Messages)-1].Content)
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":` + string(content) + `}}]}`), nil
+		},
+	)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	config := LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	}
+	recording := InitLLMHoneypot(config)
+	recording.client = client
+	assert.Nil(t, recording.RecordTo(path))
+	recording.ExecuteModel("whoami")
+	recording.ExecuteModel("ls -la")
+	calls := httpmock.GetTotalCallCount()
+
+	replaying := InitLLMHoneypot(config)
+	replaying.client = client
+
+	//When
+	err := replaying.ReplayFrom(path)
+	first, firstErr := replaying.ExecuteModel("whoami")
+	second, secondErr := replaying.ExecuteModel("ls -la")
+	_, overErr := replaying.ExecuteModel("id")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Nil(t, firstErr)
+	assert.Nil(t, secondErr)
+	assert.Equal(t, "output of whoami", first)
+	assert.Equal(t, "output of ls -la", second)
+	assert.ErrorIs(t, overErr, ErrReplayMismatch)
+	assert.Equal(t, calls, httpmock.GetTotalCallCount())
+	assert.Equal(t, "output of ls -la", replaying.history()[len(replaying.history())-1].Content)
+	raw, _ := os.ReadFile(path)
+	assert.Equal(t, "{\"command\":\"whoami\",\"response\":\"output of whoami\"}\n{\"command\":\"ls -la\",\"response\":\"output of ls -la\"}\n", string(raw))
+}
+
+func TestReplayMismatchFallsThroughToLiveModel(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"uid=0(root) gid=0(root)"}}]}`), nil
+		},
+	)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"command":"whoami","response":"root"}`+"\n"), 0o600))
+
+	strict := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Model: "gpt-4o", Provider: OpenAI, OpenAIKey: "sdjdnklfjndslkjanfk"})
+	strict.client = client
+	lenient := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Model: "gpt-4o", Provider: OpenAI, OpenAIKey: "sdjdnklfjndslkjanfk", ReplayFallthrough: true})
+	lenient.client = client
+	assert.Nil(t, strict.ReplayFrom(path))
+	assert.Nil(t, lenient.ReplayFrom(path))
+
+	//When
+	_, strictErr := strict.ExecuteModel("id")
+	live, liveErr := lenient.ExecuteModel("id")
+	replayed, replayedErr := lenient.ExecuteModel("whoami")
+
+	//Then
+	assert.EqualError(t, strictErr, `command does not match the recorded session: got "id", recorded "whoami"`)
+	assert.Nil(t, liveErr)
+	assert.Equal(t, "uid=0(root) gid=0(root)", live)
+	assert.Nil(t, replayedErr)
+	assert.Equal(t, "root", replayed)
+	assert.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestReplayFromInvalidRecording(t *testing.T) {
+	//Given
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	assert.Nil(t, os.WriteFile(path, []byte("{\"command\":\"ls\",\"response\":\"a\"}\nnot json\n"), 0o600))
+	honeypot := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH})
+
+	//When
+	err := honeypot.ReplayFrom(path)
+
+	//Then
+	assert.ErrorContains(t, err, "line 2")
+}
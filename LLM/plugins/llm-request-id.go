@@ -0,0 +1,49 @@
+package plugins
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// requestIDHeader carries the correlation ID to the provider, where its logs support it
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID tags every provider call made with ctx with id, so honeypot
+// logs, the interaction sink and provider logs can be correlated
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the correlation ID of ctx, empty when none is set
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ensureRequestID keeps the ID of the caller or generates a random one
+func ensureRequestID(ctx context.Context) (context.Context, string) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return ctx, id
+	}
+	raw := make([]byte, 8)
+	rand.Read(raw)
+	id := hex.EncodeToString(raw)
+	return ContextWithRequestID(ctx, id), id
+}
+
+// newRequest is a provider request bound to ctx, tagged with its request ID and
+// carrying ExtraHeaders
+func (llm *LLMHoneypot) newRequest(ctx context.Context) *resty.Request {
+	req := llm.client.R().SetContext(ctx)
+	llm.applyExtraHeaders(req)
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.SetHeader(requestIDHeader, id)
+	}
+	llm.injectTraceContext(ctx, req)
+	return req
+}
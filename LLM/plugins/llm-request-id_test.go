@@ -0,0 +1,49 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDPropagation(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var headers []string
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			headers = append(headers, req.Header.Get(requestIDHeader))
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+
+	sink := &mockInteractionSink{}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+		Sink:     sink,
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModelContext(ContextWithRequestID(context.Background(), "session-7/cmd-3"), "ls")
+	assert.Nil(t, err)
+	_, err = honeypot.ExecuteModel("id")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Len(t, sink.interactions, 2)
+	assert.Equal(t, "session-7/cmd-3", headers[0])
+	assert.Equal(t, "session-7/cmd-3", sink.interactions[0].RequestID)
+	assert.Len(t, sink.interactions[1].RequestID, 16)
+	assert.Equal(t, sink.interactions[1].RequestID, headers[1])
+}
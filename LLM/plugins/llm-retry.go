@@ -0,0 +1,69 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// retryBackoff is the wait before the first retry, doubled at every attempt
+	retryBackoff = 250 * time.Millisecond
+	// maxRetryBackoff caps the doubling, so a large MaxRetries neither overflows
+	// nor keeps a session waiting for minutes
+	maxRetryBackoff = 8 * time.Second
+)
+
+// retryJitter adds up to half of the backoff, so that the sessions hit by the same
+// outage do not retry in lockstep
+var retryJitter = func(backoff time.Duration) time.Duration {
+	return rand.N(backoff / 2)
+}
+
+// retryBudget returns the retry bucket of the session, created on first use, or
+// nil when RetryBudgetPerMinute is not set and every call may retry MaxRetries times
+func (llm *LLMHoneypot) retryBudget() *rate.Limiter {
+	if llm.RetryBudgetPerMinute <= 0 {
+		return nil
+	}
+	mu := llm.historyLock()
+	mu.Lock()
+	defer mu.Unlock()
+	if llm.retryLimiter == nil {
+		llm.retryLimiter = rate.NewLimiter(rate.Limit(float64(llm.RetryBudgetPerMinute)/60), llm.RetryBudgetPerMinute)
+	}
+	return llm.retryLimiter
+}
+
+// withRetries calls again a failed provider call up to MaxRetries times, each retry
+// spending a token of budget. An empty budget returns the last error at once, so a
+// flapping provider costs a session at most RetryBudgetPerMinute extra calls. The
+// wait between attempts ends early with ctx.Err() when the attacker goes away
+func (llm *LLMHoneypot) withRetries(ctx context.Context, budget *rate.Limiter, call func() error) error {
+	err := call()
+	// a missing model stays missing, ModelFallbacks handle it, and a rejected request stays rejected
+	for attempt := 0; err != nil && !errors.Is(err, ErrModelNotFound) && !isRejectedRequest(err) && attempt < llm.MaxRetries && ctx.Err() == nil; attempt++ {
+		if budget != nil && !budget.Allow() {
+			log.Debugf("retry budget exhausted, giving up: %s", err.Error())
+			return err
+		}
+		if waitErr := sleepContext(ctx, llm.retryDelay(attempt)); waitErr != nil {
+			return waitErr
+		}
+		err = call()
+	}
+	return err
+}
+
+// retryDelay is the jittered backoff before retry attempt+1, capped at maxRetryBackoff
+func (llm *LLMHoneypot) retryDelay(attempt int) time.Duration {
+	backoff := maxRetryBackoff
+	if attempt < 8 {
+		backoff = min(retryBackoff<<attempt, maxRetryBackoff)
+	}
+	return backoff + retryJitter(backoff)
+}
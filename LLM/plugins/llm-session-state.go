@@ -0,0 +1,213 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Process is a process the model has already shown to the attacker
+type Process struct {
+	User    string
+	PID     int
+	Command string
+}
+
+// SessionState keeps the facts invented during a session so that repeated
+// introspection commands (ps, ls, env) return consistent answers
+type SessionState struct {
+	mu        sync.Mutex
+	Processes []Process
+	Files     []string
+	Env       map[string]string
+	// BashHistory is the shell history, oldest first, see PreloadBashHistory
+	BashHistory []string
+}
+
+func NewSessionState() *SessionState {
+	return &SessionState{Env: make(map[string]string)}
+}
+
+// Clone returns an independent copy of the state
+func (s *SessionState) Clone() *SessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clone := &SessionState{
+		Processes:   append([]Process(nil), s.Processes...),
+		Files:       append([]string(nil), s.Files...),
+		Env:         make(map[string]string, len(s.Env)),
+		BashHistory: append([]string(nil), s.BashHistory...),
+	}
+	for k, v := range s.Env {
+		clone.Env[k] = v
+	}
+	return clone
+}
+
+// PromptContext renders the state as a system message, empty when nothing is known yet
+func (s *SessionState) PromptContext() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.Processes) == 0 && len(s.Files) == 0 && len(s.Env) == 0 && len(s.BashHistory) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Current simulated system state, every answer MUST stay consistent with it.\n")
+	if len(s.Processes) > 0 {
+		b.WriteString("Running processes (USER PID COMMAND):\n")
+		for _, p := range s.Processes {
+			fmt.Fprintf(&b, "%s %d %s\n", p.User, p.PID, p.Command)
+		}
+	}
+	if len(s.Files) > 0 {
+		b.WriteString("Files created during this session:\n")
+		for _, f := range s.Files {
+			b.WriteString(f + "\n")
+		}
+	}
+	if len(s.Env) > 0 {
+		b.WriteString("Environment variables:\n")
+		keys := make([]string, 0, len(s.Env))
+		for k := range s.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s=%s\n", k, s.Env[k])
+		}
+	}
+	if len(s.BashHistory) > 0 {
+		b.WriteString("Shell history as printed by `history`, also the content of ~/.bash_history:\n")
+		first := max(0, len(s.BashHistory)-bashHistoryPromptEntries)
+		for i, command := range s.BashHistory[first:] {
+			fmt.Fprintf(&b, "%5d  %s\n", first+i+1, command)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Update records what the command changed and what the model revealed in its output
+func (s *SessionState) Update(command, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Env == nil {
+		s.Env = make(map[string]string)
+	}
+
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+	s.addBashHistory(strings.TrimSpace(command))
+
+	switch fields[0] {
+	case "ps":
+		if len(s.Processes) == 0 {
+			s.Processes = parseProcessTable(output)
+		}
+	case "env", "printenv":
+		if len(fields) == 1 && len(s.Env) == 0 {
+			for _, line := range strings.Split(output, "\n") {
+				if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok && k != "" {
+					s.Env[k] = v
+				}
+			}
+		}
+	case "export":
+		for _, assignment := range fields[1:] {
+			if k, v, ok := strings.Cut(assignment, "="); ok {
+				s.Env[k] = strings.Trim(v, `"'`)
+			}
+		}
+	case "unset":
+		for _, k := range fields[1:] {
+			delete(s.Env, k)
+		}
+	case "touch", "mkdir":
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				s.addFile(f)
+			}
+		}
+	case "history":
+		if len(fields) > 1 && fields[1] == "-c" {
+			s.BashHistory = nil
+		}
+	case "rm", "rmdir":
+		for _, f := range fields[1:] {
+			s.removeFile(f)
+		}
+	}
+
+	for i, f := range fields {
+		if (f == ">" || f == ">>") && i+1 < len(fields) {
+			s.addFile(fields[i+1])
+		}
+	}
+}
+
+func (s *SessionState) addFile(path string) {
+	for _, f := range s.Files {
+		if f == path {
+			return
+		}
+	}
+	s.Files = append(s.Files, path)
+}
+
+func (s *SessionState) removeFile(path string) {
+	for i, f := range s.Files {
+		if f == path {
+			s.Files = append(s.Files[:i], s.Files[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseProcessTable reads ps output that has a header with PID and CMD/COMMAND columns
+func parseProcessTable(output string) []Process {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+
+	header := strings.Fields(lines[0])
+	pidCol, userCol, cmdCol := -1, -1, -1
+	for i, h := range header {
+		switch h {
+		case "PID":
+			pidCol = i
+		case "USER", "UID":
+			userCol = i
+		case "CMD", "COMMAND":
+			cmdCol = i
+		}
+	}
+	if pidCol < 0 || cmdCol < 0 {
+		return nil
+	}
+
+	var processes []Process
+	for _, line := range lines[1:] {
+		cols := strings.Fields(line)
+		if len(cols) <= cmdCol {
+			continue
+		}
+		pid, err := strconv.Atoi(cols[pidCol])
+		if err != nil {
+			continue
+		}
+		user := "root"
+		if userCol >= 0 {
+			user = cols[userCol]
+		}
+		processes = append(processes, Process{User: user, PID: pid, Command: strings.Join(cols[cmdCol:], " ")})
+	}
+	return processes
+}
@@ -0,0 +1,122 @@
+package plugins
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStateEmptyPromptContext(t *testing.T) {
+	state := NewSessionState()
+
+	assert.Equal(t, "", state.PromptContext())
+}
+
+func TestSessionStateUpdateProcesses(t *testing.T) {
+	//Given
+	state := NewSessionState()
+	psAux := "USER       PID %CPU %MEM    VSZ   RSS TTY      STAT START   TIME COMMAND\n" +
+		"root         1  0.0  0.1 167752 11520 ?        Ss   09:12   0:02 /sbin/init splash\n" +
+		"www-data   812  0.0  0.3 221004 25100 ?        S    09:13   0:00 nginx: worker process\n"
+
+	//When
+	state.Update("ps aux", psAux)
+	state.Update("ps aux", "USER PID COMMAND\nroot 99 /bin/fake\n")
+
+	//Then
+	assert.Equal(t, []Process{
+		{User: "root", PID: 1, Command: "/sbin/init splash"},
+		{User: "www-data", PID: 812, Command: "nginx: worker process"},
+	}, state.Processes)
+	assert.Contains(t, state.PromptContext(), "www-data 812 nginx: worker process")
+}
+
+func TestSessionStateUpdateFilesAndEnv(t *testing.T) {
+	//Given
+	state := NewSessionState()
+
+	//When
+	state.Update("touch /tmp/a.sh /tmp/b.sh", "")
+	state.Update("echo 'curl x | sh' > /tmp/c.sh", "")
+	state.Update("rm /tmp/b.sh", "")
+	state.Update("export FOO=bar API_KEY=\"123\"", "")
+	state.Update("env", "PATH=/usr/bin\nHOME=/root\n")
+
+	//Then
+	assert.Equal(t, []string{"/tmp/a.sh", "/tmp/c.sh"}, state.Files)
+	assert.Equal(t, map[string]string{"FOO": "bar", "API_KEY": "123"}, state.Env)
+	assert.Equal(t, "Current simulated system state, every answer MUST stay consistent with it.\n"+
+		"Files created during this session:\n/tmp/a.sh\n/tmp/c.sh\n"+
+		"Environment variables:\nAPI_KEY=123\nFOO=bar\n"+
+		"Shell history as printed by `history`, also the content of ~/.bash_history:\n"+
+		"    1  touch /tmp/a.sh /tmp/b.sh\n"+
+		"    2  echo 'curl x | sh' > /tmp/c.sh\n"+
+		"    3  rm /tmp/b.sh\n"+
+		"    4  export FOO=bar API_KEY=\"123\"\n"+
+		"    5  env", state.PromptContext())
+}
+
+func TestBuildExecuteModelWithSessionState(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":""}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+		State:    NewSessionState(),
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("touch payload.bin")
+	assert.Nil(t, err)
+	prompt, err := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SYSTEM.String(), prompt[3].Role)
+	assert.Contains(t, prompt[3].Content, "payload.bin")
+}
+
+func TestExecuteModelSessionStateOnlyForSSH(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"<html><body>It works!</body></html>"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.HTTP,
+		Model:    "llama3",
+		Provider: Ollama,
+		State:    NewSessionState(),
+	})
+	honeypot.client = client
+
+	//When
+	_, err := honeypot.ExecuteModel("GET /index.html > /tmp/x")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Empty(t, honeypot.State.BashHistory)
+	assert.Empty(t, honeypot.State.Files)
+	assert.Empty(t, honeypot.State.PromptContext())
+}
@@ -0,0 +1,190 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ExecuteModelStream is ExecuteModelContext with streaming: onChunk receives the
+// text as the provider generates it, providers without streaming deliver a single
+// chunk. The returned string is the filtered full answer, the one kept in history.
+// With OutputRate set, SSH chunks are paced like a terminal rendering them
+func (llm *LLMHoneypot) ExecuteModelStream(ctx context.Context, command string, onChunk func(chunk string)) (string, error) {
+	if onChunk == nil {
+		onChunk = func(string) {}
+	}
+	return llm.run(ctx, command, &turn{onChunk: llm.pace(ctx, onChunk)})
+}
+
+// ExecuteModelChan streams the answer over a channel that is closed once the call
+// ends. Cancelling ctx aborts the provider request and closes the channel promptly,
+// even if nobody reads it anymore. errc receives the result, nil included, then closes
+func (llm *LLMHoneypot) ExecuteModelChan(ctx context.Context, command string) (chunks <-chan string, errc <-chan error) {
+	out := make(chan string)
+	result := make(chan error, 1)
+	go func() {
+		defer close(result)
+		defer close(out)
+		_, err := llm.ExecuteModelStream(ctx, command, func(chunk string) {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+			}
+		})
+		result <- err
+	}()
+	return out, result
+}
+
+func (llm *LLMHoneypot) callProviderStream(ctx context.Context, msgs []Message, onChunk func(string)) (string, Usage, error) {
+	switch llm.Provider {
+	case OpenAI, Compatible:
+		target := llm.openAITarget
+		if llm.Provider == Compatible {
+			target = llm.compatibleTarget
+		}
+		url, auth, err := target(ctx)
+		if err != nil {
+			return "", Usage{}, err
+		}
+		reqJSON, err := llm.chatCompletionsPayload(msgs, nil, true)
+		if err != nil {
+			return "", Usage{}, err
+		}
+		logPayload(ctx, reqJSON, msgs)
+		return llm.stream(ctx, url, reqJSON, auth, readServerSentEvents, onChunk)
+	case Ollama:
+		reqJSON, err := llm.ollamaPayload(msgs, true)
+		if err != nil {
+			return "", Usage{}, err
+		}
+		logPayload(ctx, reqJSON, msgs)
+		return llm.stream(ctx, llm.Host, reqJSON, func(*resty.Request) {}, readNDJSON, onChunk)
+	default:
+		output, usage, err := llm.callProvider(ctx, msgs)
+		if err == nil {
+			onChunk(output)
+		}
+		return output, usage, err
+	}
+}
+
+// streamReader decodes one streaming wire format into text chunks and the final
+// usage, it stops early when onChunk returns false
+type streamReader func(body io.Reader, onChunk func(string) bool) (Usage, error)
+
+func (llm *LLMHoneypot) stream(ctx context.Context, url string, reqJSON []byte, auth func(*resty.Request), read streamReader, onChunk func(string)) (string, Usage, error) {
+	if err := llm.waitOpenAIRateLimit(ctx); err != nil {
+		return "", Usage{}, err
+	}
+	req := llm.newRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(reqJSON).
+		SetDoNotParseResponse(true)
+	auth(req)
+	resp, err := req.Post(url)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	llm.recordOpenAIRateLimit(resp.Header())
+	body := resp.RawBody()
+	defer body.Close()
+	if resp.StatusCode() != 200 {
+		msg, _ := io.ReadAll(body)
+		llm.keepRawResponse(msg)
+		if err := modelNotFound(resp.StatusCode(), string(msg)); err != nil {
+			return "", Usage{}, err
+		}
+		return "", Usage{}, fmt.Errorf("stream request failed: %s – %s", resp.Status(), strings.TrimSpace(string(msg)))
+	}
+
+	// past MaxResponseBytes the rest of the stream is dropped, limitResponse trims the buffer
+	var buffer strings.Builder
+	var raw rawCapture
+	defer func() { llm.keepRawResponse(raw.buf) }()
+	usage, err := read(io.TeeReader(body, &raw), func(chunk string) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		buffer.WriteString(chunk)
+		onChunk(chunk)
+		return llm.MaxResponseBytes <= 0 || buffer.Len() <= llm.MaxResponseBytes
+	})
+	if ctx.Err() != nil {
+		// the attacker hit Ctrl-C: closing body drops the connection, what was
+		// received is returned with the error for StorePartialOutput
+		return removeQuotes(buffer.String()), usage, ctx.Err()
+	}
+	if err != nil {
+		return "", usage, err
+	}
+	return removeQuotes(buffer.String()), usage, nil
+}
+
+// readServerSentEvents reads the OpenAI "data: {...}" lines until "data: [DONE]"
+func readServerSentEvents(body io.Reader, onChunk func(string) bool) (Usage, error) {
+	var usage Usage
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return usage, nil
+		}
+
+		var event Response
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return usage, fmt.Errorf("decoding stream event: %v", err)
+		}
+		if event.Usage.TotalTokens > 0 {
+			usage = event.Usage
+		}
+		if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+			if !onChunk(event.Choices[0].Delta.Content) {
+				return usage, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return usage, err
+	}
+	return usage, errors.New("stream ended without [DONE]")
+}
+
+// readNDJSON reads the Ollama stream, one JSON object per line until "done": true
+func readNDJSON(body io.Reader, onChunk func(string) bool) (Usage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event Response
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return Usage{}, fmt.Errorf("decoding stream event: %v", err)
+		}
+		if event.Message.Content != "" && !onChunk(event.Message.Content) {
+			return event.ollamaUsage(), nil
+		}
+		if event.Done {
+			return event.ollamaUsage(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Usage{}, err
+	}
+	return Usage{}, errors.New("stream ended without done")
+}
@@ -0,0 +1,270 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteModelStreamOpenAI(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, "data: {\"choices\":[{\"delta\":{\"content\":\"prova\"}}]}\n\n"+
+				"data: {\"choices\":[{\"delta\":{\"content\":\".txt\"}}]}\n\n"+
+				"data: [DONE]\n\n"), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+	var chunks []string
+
+	//When
+	str, err := honeypot.ExecuteModelStream(context.Background(), "ls", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, []string{"prova", ".txt"}, chunks)
+	assert.Equal(t, "prova.txt", honeypot.Histories[len(honeypot.Histories)-1].Content)
+}
+
+func TestExecuteModelStreamRefusalNotInHistory(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, strings.Join([]string{
+				`{"message":{"role":"assistant","content":"I'm sorry, as a lang"},"done":false}`,
+				`{"message":{"role":"assistant","content":"uage model I cannot run commands."},"done":false}`,
+				`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":10,"eval_count":12}`,
+			}, "\n")), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.SSH,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+	honeypot.client = client
+	var streamed strings.Builder
+
+	//When
+	str, err := honeypot.ExecuteModelStream(context.Background(), "ls", func(chunk string) {
+		streamed.WriteString(chunk)
+	})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "command not found", str)
+	assert.Equal(t, "I'm sorry, as a language model I cannot run commands.", streamed.String())
+	assert.Empty(t, honeypot.Histories)
+}
+
+func TestExecuteModelStreamFallbackProvider(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:    tracer.SSH,
+		Provider:    Exec,
+		ExecCommand: []string{"echo", "prova.txt"},
+	})
+	var chunks []string
+
+	//When
+	str, err := honeypot.ExecuteModelStream(context.Background(), "ls", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, []string{"prova.txt"}, chunks)
+}
+
+func TestReadServerSentEventsTruncated(t *testing.T) {
+	_, err := readServerSentEvents(strings.NewReader("data: {\"choices\":[{\"delta\":{\"content\":\"x\"}}]}\n"), func(string) bool { return true })
+
+	assert.Equal(t, "stream ended without [DONE]", err.Error())
+}
+
+func TestExecuteModelStreamStopsAtMaxResponseBytes(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, strings.Join([]string{
+				`{"message":{"role":"assistant","content":"0123456789"},"done":false}`,
+				`{"message":{"role":"assistant","content":"0123456789"},"done":false}`,
+				`{"message":{"role":"assistant","content":"0123456789"},"done":false}`,
+			}, "\n")), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.HTTP,
+		Model:            "llama3",
+		Provider:         Ollama,
+		MaxResponseBytes: 15,
+	})
+	honeypot.client = client
+	chunks := 0
+
+	//When
+	str, err := honeypot.ExecuteModelStream(context.Background(), "GET /", func(string) { chunks++ })
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "012345678901234", str)
+	assert.Equal(t, 2, chunks)
+}
+
+func TestExecuteModelChanCancelMidStream(t *testing.T) {
+	// Given
+	disconnected := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"total 4\\n\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"drwxr-xr-x 2 root root 4096 .\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			close(disconnected)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:           tracer.SSH,
+		Model:              "gpt-4o",
+		Provider:           OpenAI,
+		OpenAIKey:          "sdjdnklfjndslkjanfk",
+		Host:               server.URL,
+		StorePartialOutput: true,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//When
+	chunks, errc := honeypot.ExecuteModelChan(ctx, "ls -la")
+	first := <-chunks
+	second := <-chunks
+	cancel()
+
+	//Then
+	assert.Equal(t, "total 4\n", first)
+	assert.Equal(t, "drwxr-xr-x 2 root root 4096 .", second)
+	select {
+	case _, open := <-chunks:
+		assert.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("the chunk channel was not closed after cancel")
+	}
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("the upstream connection was not closed after cancel")
+	}
+	assert.ErrorIs(t, <-errc, context.Canceled)
+	assert.Equal(t, "total 4\ndrwxr-xr-x 2 root root 4096 .", honeypot.Histories[len(honeypot.Histories)-1].Content)
+}
+
+func TestExecuteModelChanCancelDuringPacedLine(t *testing.T) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"-rw-r--r-- 1 root root 220 Jan  6  2022 .bash_logout\\nnext\\n\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	// 5 characters per second: the first line alone is paced for ~10 seconds
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:      tracer.SSH,
+		Model:         "gpt-4o",
+		Provider:      OpenAI,
+		OpenAIKey:     "sdjdnklfjndslkjanfk",
+		Host:          server.URL,
+		OutputRate:    5,
+		OutputPerLine: true,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//When
+	chunks, errc := honeypot.ExecuteModelChan(ctx, "ls -la")
+	first := <-chunks
+	start := time.Now()
+	cancel()
+
+	//Then
+	assert.Equal(t, "-rw-r--r-- 1 root root 220 Jan  6  2022 .bash_logout\n", first)
+	select {
+	case _, open := <-chunks:
+		assert.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("the paced line kept the stream open after cancel")
+	}
+	assert.ErrorIs(t, <-errc, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestExecuteModelChanCompletes(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		httpmock.NewStringResponder(200, "data: {\"choices\":[{\"delta\":{\"content\":\"prova\"}}]}\n\n"+
+			"data: {\"choices\":[{\"delta\":{\"content\":\".txt\"}}]}\n\n"+
+			"data: [DONE]\n\n"),
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+
+	//When
+	chunks, errc := honeypot.ExecuteModelChan(context.Background(), "ls")
+	var received []string
+	for chunk := range chunks {
+		received = append(received, chunk)
+	}
+
+	//Then
+	assert.Equal(t, []string{"prova", ".txt"}, received)
+	assert.Nil(t, <-errc)
+}
@@ -0,0 +1,73 @@
+package plugins
+
+import "unicode"
+
+// messageTokenOverhead is what chat formats add around each message (role, separators)
+const messageTokenOverhead = 4
+
+// Tokenizer counts the tokens a text costs with the model in use. The package only
+// ships a heuristic, inject a real counter (e.g. tiktoken-backed) for exact budgets
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer approximates BPE tokenizers: every punctuation or symbol is a
+// token, letter and digit runs cost one token per six characters. Unlike a flat
+// len/4 it does not underestimate shell output full of paths, flags and pipes
+type HeuristicTokenizer struct{}
+
+func (HeuristicTokenizer) CountTokens(text string) int {
+	tokens, run := 0, 0
+	flush := func() {
+		tokens += (run + 5) / 6
+		run = 0
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			run++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+func (llm *LLMHoneypot) tokenizer() Tokenizer {
+	if llm.Tokenizer != nil {
+		return llm.Tokenizer
+	}
+	return HeuristicTokenizer{}
+}
+
+func (llm *LLMHoneypot) countMessageTokens(msgs []Message) int {
+	tokenizer := llm.tokenizer()
+	total := 0
+	for _, m := range msgs {
+		total += tokenizer.CountTokens(m.Content) + messageTokenOverhead
+	}
+	return total
+}
+
+// trimHistoryToBudget drops the oldest history messages until fixed (system prompt,
+// seeds, current command) plus history fit in MaxContextTokens
+func (llm *LLMHoneypot) trimHistoryToBudget(fixed, history []Message) []Message {
+	if llm.MaxContextTokens <= 0 {
+		return history
+	}
+
+	budget := llm.MaxContextTokens - llm.countMessageTokens(fixed)
+	tokenizer := llm.tokenizer()
+	used := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		used += tokenizer.CountTokens(history[i].Content) + messageTokenOverhead
+		if used > budget {
+			return history[i+1:]
+		}
+	}
+	return history
+}
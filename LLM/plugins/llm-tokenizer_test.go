@@ -0,0 +1,53 @@
+package plugins
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestHeuristicTokenizer(t *testing.T) {
+	tokenizer := HeuristicTokenizer{}
+
+	assert.Equal(t, 0, tokenizer.CountTokens(""))
+	assert.Equal(t, 2, tokenizer.CountTokens("hello world"))
+	assert.Equal(t, 4, tokenizer.CountTokens("internationalization"))
+	assert.Equal(t, 8, tokenizer.CountTokens("ls -la /etc | grep ssh"))
+}
+
+func TestBuildPromptMaxContextTokens(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{
+		Histories: []Message{
+			{Role: USER.String(), Content: "one two three four five"},
+			{Role: ASSISTANT.String(), Content: "six seven"},
+			{Role: USER.String(), Content: "eight"},
+		},
+		Protocol:     tracer.SSH,
+		CustomPrompt: "a shell",
+		Tokenizer:    wordTokenizer{},
+	}
+	// system 2, seeds 1 + 1, command 1, plus the overhead of 4 messages
+	fixed := 5 + 4*messageTokenOverhead
+
+	//When
+	honeypot.MaxContextTokens = fixed + (2 + messageTokenOverhead) + (1 + messageTokenOverhead)
+	trimmed, err := honeypot.buildPrompt("ls")
+	honeypot.MaxContextTokens = 0
+	full, _ := honeypot.buildPrompt("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, SystemPromptLen+2, len(trimmed))
+	assert.Equal(t, "six seven", trimmed[3].Content)
+	assert.Equal(t, "ls", trimmed[5].Content)
+	assert.Equal(t, SystemPromptLen+3, len(full))
+}
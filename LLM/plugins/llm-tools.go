@@ -0,0 +1,78 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Tool is a function the model may call instead of answering, e.g. a fake database
+// query whose result the caller produces and feeds back with AppendToolResult
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name string `json:"name"`
+	// Arguments is the JSON object written by the model, it may be invalid
+	Arguments string `json:"arguments"`
+}
+
+// ErrToolsNotSupported is returned when tools are sent to a provider without tool calling
+var ErrToolsNotSupported = errors.New("provider does not support tool calling")
+
+// ExecuteModelWithTools offers tools to the model. When the model calls some of them
+// the calls are returned with an empty answer: run them, add each result with
+// AppendToolResult and call ExecuteModelWithTools again with an empty command to
+// get the final answer. Only OpenAI and compatible gateways support tools
+func (llm *LLMHoneypot) ExecuteModelWithTools(command string, tools []Tool) (string, []ToolCall, error) {
+	if tools == nil {
+		tools = []Tool{}
+	}
+	t := &turn{tools: tools}
+	output, err := llm.run(context.Background(), command, t)
+	return output, t.toolCalls, err
+}
+
+// AppendToolResult records the result of a tool call for the next ExecuteModelWithTools
+func (llm *LLMHoneypot) AppendToolResult(toolCallID, content string) error {
+	if toolCallID == "" {
+		return errors.New("toolCallID is empty")
+	}
+	llm.appendMessage(Message{Role: TOOL.String(), Content: content, ToolCallID: toolCallID})
+	return nil
+}
+
+func (llm *LLMHoneypot) callProviderWithTools(ctx context.Context, msgs []Message, tools []Tool) (Message, Usage, error) {
+	target := llm.openAITarget
+	switch llm.Provider {
+	case OpenAI:
+	case Compatible:
+		target = llm.compatibleTarget
+	default:
+		return Message{}, Usage{}, ErrToolsNotSupported
+	}
+
+	url, auth, err := target(ctx)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+	if len(tools) == 0 {
+		tools = nil
+	}
+	return llm.chatCompletions(ctx, msgs, tools, url, auth)
+}
@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+var queryUsersTool = Tool{
+	Type: "function",
+	Function: ToolFunction{
+		Name:       "query_users",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"id":{"type":"integer"}}}`),
+	},
+}
+
+func TestExecuteModelWithTools(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var requests []Request
+	httpmock.RegisterResponder("POST", openAIEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			var body Request
+			json.NewDecoder(req.Body).Decode(&body)
+			requests = append(requests, body)
+			if len(requests) == 1 {
+				return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[` +
+					`{"id":"call_1","type":"function","function":{"name":"query_users","arguments":"{\"id\":1}"}}]}}]}`), nil
+			}
+			return newJSONStringResponse(`{"choices":[{"message":{"role":"assistant","content":"{\"id\":1,\"name\":\"admin\"}"}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:  tracer.HTTP,
+		Model:     "gpt-4o",
+		Provider:  OpenAI,
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+	})
+	honeypot.client = client
+
+	//When
+	first, calls, err := honeypot.ExecuteModelWithTools("GET /api/users/1", []Tool{queryUsersTool})
+	assert.Nil(t, err)
+	assert.Nil(t, honeypot.AppendToolResult(calls[0].ID, `{"id":1,"name":"admin"}`))
+	final, finalCalls, err := honeypot.ExecuteModelWithTools("", []Tool{queryUsersTool})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "", first)
+	assert.Equal(t, "query_users", calls[0].Function.Name)
+	assert.Equal(t, `{"id":1}`, calls[0].Function.Arguments)
+	assert.Equal(t, `{"id":1,"name":"admin"}`, final)
+	assert.Empty(t, finalCalls)
+
+	assert.Equal(t, "query_users", requests[0].Tools[0].Function.Name)
+	second := requests[1].Messages
+	assert.Equal(t, "call_1", second[len(second)-2].ToolCalls[0].ID)
+	assert.Equal(t, TOOL.String(), second[len(second)-1].Role)
+	assert.Equal(t, "call_1", second[len(second)-1].ToolCallID)
+	// like a plain answer, only the model side of the turn is kept
+	var roles []string
+	for _, m := range honeypot.Histories {
+		roles = append(roles, m.Role)
+	}
+	assert.Equal(t, []string{ASSISTANT.String(), TOOL.String(), ASSISTANT.String()}, roles)
+}
+
+func TestExecuteModelWithToolsUnsupportedProvider(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol: tracer.HTTP,
+		Model:    "llama3",
+		Provider: Ollama,
+	})
+
+	//When
+	_, _, err := honeypot.ExecuteModelWithTools("GET /", []Tool{queryUsersTool})
+	appendErr := honeypot.AppendToolResult("", "x")
+
+	//Then
+	assert.ErrorIs(t, err, ErrToolsNotSupported)
+	assert.Equal(t, "toolCallID is empty", appendErr.Error())
+}
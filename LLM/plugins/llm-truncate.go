@@ -0,0 +1,143 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+)
+
+// errTruncateJSON is returned when not even an empty document fits the limit
+var errTruncateJSON = errors.New("JSON cannot be truncated to a valid document")
+
+// safeTruncate cuts s to at most max bytes. The cut never splits a UTF-8 sequence,
+// SSH output ends on a complete line, and output of the other protocols that starts
+// with { or [ is cut after the last complete element and its open arrays and
+// objects are closed, so a JSON body stays valid JSON
+func safeTruncate(s string, max int, protocol tracer.Protocol) (string, error) {
+	if len(s) <= max {
+		return s, nil
+	}
+
+	switch protocol {
+	case tracer.SSH:
+		truncated := truncateUTF8(s, max)
+		if i := strings.LastIndex(truncated, "\n"); i >= 0 {
+			truncated = truncated[:i+1]
+		}
+		return truncated, nil
+	case tracer.TCP:
+		return truncateUTF8(s, max), nil
+	}
+
+	if trimmed := strings.TrimLeft(s, " \t\r\n"); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		truncated, isJSON, err := truncateJSON(s, max)
+		if isJSON {
+			return truncated, err
+		}
+	}
+	return truncateUTF8(s, max), nil
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// truncateJSON tokenizes the first max bytes of s and keeps the longest prefix that
+// ends after a complete element, closed with the brackets still open. isJSON is
+// false when s is not JSON after all, e.g. an INI file starting with [section]
+func truncateJSON(s string, max int) (truncated string, isJSON bool, err error) {
+	prefix := truncateUTF8(s, max)
+	dec := json.NewDecoder(strings.NewReader(prefix))
+
+	// one entry per open container: its closing bracket and, for objects,
+	// whether the next token is a key
+	type container struct {
+		closer    byte
+		expectKey bool
+	}
+	var open []container
+	closers := func() string {
+		b := make([]byte, len(open))
+		for i := range open {
+			b[i] = open[len(open)-1-i].closer
+		}
+		return string(b)
+	}
+
+	best := ""
+	found := false
+	keep := func() {
+		offset := int(dec.InputOffset())
+		if candidate := prefix[:offset] + closers(); len(candidate) <= max {
+			best, found = candidate, true
+		}
+	}
+	// an empty nested container would add an element the original does not have
+	keepEmptyRoot := func() {
+		if len(open) == 1 {
+			keep()
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			// a syntax error before the cut means this was never JSON
+			return "", false, nil
+		}
+
+		// a key only names the element, its value completes it
+		if n := len(open); n > 0 && open[n-1].closer == '}' && open[n-1].expectKey {
+			if _, ok := tok.(string); ok {
+				open[n-1].expectKey = false
+				continue
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			open = append(open, container{closer: '}', expectKey: true})
+			keepEmptyRoot()
+			continue
+		case json.Delim('['):
+			open = append(open, container{closer: ']'})
+			keepEmptyRoot()
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			open = open[:len(open)-1]
+		default:
+			// a scalar running into the cut, e.g. 12 of 1234, is not complete
+			if int(dec.InputOffset()) >= len(prefix) {
+				continue
+			}
+		}
+		if len(open) == 0 {
+			// the root closed within max bytes, what follows is not part of it
+			keep()
+			break
+		}
+		if top := &open[len(open)-1]; top.closer == '}' {
+			top.expectKey = true
+		}
+		keep()
+	}
+
+	if !found || !json.Valid([]byte(best)) {
+		return "", true, errTruncateJSON
+	}
+	return best, true, nil
+}
@@ -0,0 +1,117 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeTruncateMultiByte(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		max      int
+		protocol tracer.Protocol
+		expected string
+	}{
+		{"fits", "ciao", 10, tracer.HTTP, "ciao"},
+		{"two byte rune", "abè", 3, tracer.HTTP, "ab"},
+		{"three byte rune", "日本語", 7, tracer.TCP, "日本"},
+		{"four byte rune", "ok🙂🙂", 5, tracer.HTTP, "ok"},
+		{"ssh keeps whole lines", "tệp một\ntệp hai\n", 12, tracer.SSH, "tệp một\n"},
+		{"ssh without newline", "日本語", 4, tracer.SSH, "日"},
+		{"not json after all", "[main]\nname=日本", 12, tracer.HTTP, "[main]\nname="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//When
+			truncated, err := safeTruncate(tt.input, tt.max, tt.protocol)
+
+			//Then
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, truncated)
+		})
+	}
+}
+
+func TestSafeTruncateJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		max      int
+		expected string
+	}{
+		{"drops the element cut in half", `{"users":["alice","bob","carol"],"total":3}`, 28, `{"users":["alice","bob"]}`},
+		{"drops a dangling key", `{"a":1,"b":"long value"}`, 10, `{"a":1}`},
+		{"does not shorten numbers", `[1,2,345678]`, 9, `[1,2]`},
+		{"keeps nested objects", `[{"id":1,"name":"ừ"},{"id":2,"name":"ừ"}]`, 30, `[{"id":1,"name":"ừ"}]`},
+		{"multi-byte strings", `{"msg":"日本語","next":"日本語"}`, 24, `{"msg":"日本語"}`},
+		{"empty container", `{"token":"abcdefghijklmnop"}`, 5, `{}`},
+		{"root closed before the cut", "{\"ok\":true}\n\n\n", 12, `{"ok":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//When
+			truncated, err := safeTruncate(tt.input, tt.max, tracer.HTTP)
+
+			//Then
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, truncated)
+			assert.LessOrEqual(t, len(truncated), tt.max)
+			assert.True(t, json.Valid([]byte(truncated)))
+		})
+	}
+}
+
+func TestSafeTruncateJSONTooSmall(t *testing.T) {
+	//When
+	_, err := safeTruncate(`{"a":1}`, 1, tracer.HTTP)
+
+	//Then
+	assert.ErrorIs(t, err, errTruncateJSON)
+}
+
+func TestExecuteModelTruncatesJSONBody(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"{\"items\":[\"một\",\"hai\",\"ba\"]}"}}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.HTTP,
+		Model:            "llama3",
+		Provider:         Ollama,
+		JSONMode:         true,
+		MaxResponseBytes: 26,
+	})
+	honeypot.client = client
+	tiny := InitLLMHoneypot(LLMHoneypot{
+		Protocol:         tracer.HTTP,
+		Model:            "llama3",
+		Provider:         Ollama,
+		MaxResponseBytes: 1,
+	})
+	tiny.client = client
+
+	//When
+	output, err := honeypot.ExecuteModel("GET /api/items")
+	_, tinyErr := tiny.ExecuteModel("GET /api/items")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, `{"items":["một","hai"]}`, output)
+	assert.ErrorIs(t, tinyErr, ErrResponseTooLarge)
+}
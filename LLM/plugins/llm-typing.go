@@ -0,0 +1,65 @@
+package plugins
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+)
+
+// typingTick is the shortest pause between two paced chunks, faster rates send
+// several characters per tick instead of sleeping for microseconds
+const typingTick = 10 * time.Millisecond
+
+// pace wraps onChunk so that SSH output reaches the attacker at OutputRate characters
+// per second, one character at a time or, with OutputPerLine, one line at a time.
+// The pacing time counts toward the Latency profile, which only pads what is left
+func (llm *LLMHoneypot) pace(ctx context.Context, onChunk func(string)) func(string) {
+	if llm.OutputRate <= 0 || llm.Protocol != tracer.SSH {
+		return onChunk
+	}
+	perChar := time.Second / time.Duration(llm.OutputRate)
+	batch := 1
+	if perChar < typingTick {
+		batch = int(typingTick / perChar)
+	}
+
+	// emit stops with ctx.Err() as soon as the attacker is gone, even halfway
+	// through the pause of a long line
+	emit := func(piece string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		onChunk(piece)
+		return sleepContext(ctx, time.Duration(utf8.RuneCountInString(piece))*perChar)
+	}
+	return func(chunk string) {
+		if llm.OutputPerLine {
+			for chunk != "" {
+				line, rest, found := strings.Cut(chunk, "\n")
+				if found {
+					line += "\n"
+				}
+				if emit(line) != nil {
+					return
+				}
+				chunk = rest
+			}
+			return
+		}
+		for chunk != "" {
+			end, runes := 0, 0
+			for end < len(chunk) && runes < batch {
+				_, size := utf8.DecodeRuneInString(chunk[end:])
+				end += size
+				runes++
+			}
+			if emit(chunk[:end]) != nil {
+				return
+			}
+			chunk = chunk[end:]
+		}
+	}
+}
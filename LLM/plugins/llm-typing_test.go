@@ -0,0 +1,107 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func typingHoneypot(t *testing.T, config LLMHoneypot) (*LLMHoneypot, *[]time.Duration) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	httpmock.RegisterResponder("POST", ollamaEndpoint,
+		func(req *http.Request) (*http.Response, error) {
+			return newJSONStringResponse(`{"message":{"role":"assistant","content":"ab"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":"c\ndé\n"},"done":false}` + "\n" +
+				`{"message":{"role":"assistant","content":""},"done":true}` + "\n"), nil
+		},
+	)
+
+	var pauses []time.Duration
+	wait := sleepContext
+	sleepContext = func(_ context.Context, d time.Duration) error {
+		pauses = append(pauses, d)
+		return nil
+	}
+	t.Cleanup(func() { sleepContext = wait })
+
+	config.Model = "llama3"
+	config.Provider = Ollama
+	honeypot := InitLLMHoneypot(config)
+	honeypot.client = client
+	return honeypot, &pauses
+}
+
+func TestExecuteModelStreamPacedPerCharacter(t *testing.T) {
+	//Given
+	honeypot, pauses := typingHoneypot(t, LLMHoneypot{Protocol: tracer.SSH, OutputRate: 20})
+	var chunks []string
+
+	//When
+	_, err := honeypot.ExecuteModelStream(context.Background(), "ls", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "\n", "d", "é", "\n"}, chunks)
+	assert.Len(t, *pauses, 7)
+	assert.Equal(t, 50*time.Millisecond, (*pauses)[0])
+}
+
+func TestExecuteModelStreamPacedPerLine(t *testing.T) {
+	//Given
+	honeypot, pauses := typingHoneypot(t, LLMHoneypot{Protocol: tracer.SSH, OutputRate: 1000, OutputPerLine: true})
+	var chunks []string
+
+	//When
+	_, err := honeypot.ExecuteModelStream(context.Background(), "ls", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ab", "c\n", "dé\n"}, chunks)
+	assert.Equal(t, []time.Duration{2 * time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, *pauses)
+}
+
+func TestExecuteModelStreamPacingOnlyForSSH(t *testing.T) {
+	//Given
+	honeypot, pauses := typingHoneypot(t, LLMHoneypot{Protocol: tracer.HTTP, OutputRate: 20})
+	var chunks []string
+
+	//When
+	_, err := honeypot.ExecuteModelStream(context.Background(), "GET /", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ab", "c\ndé\n"}, chunks)
+	assert.Empty(t, *pauses)
+}
+
+func TestPaceStopsDuringLongLineOnCancel(t *testing.T) {
+	//Given
+	honeypot := LLMHoneypot{Protocol: tracer.SSH, OutputRate: 10, OutputPerLine: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	var chunks []string
+	paced := honeypot.pace(ctx, func(chunk string) { chunks = append(chunks, chunk) })
+
+	//When
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	paced("drwxr-xr-x 2 root root 4096 Jan 1 backups\nnext line\n")
+
+	//Then
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"drwxr-xr-x 2 root root 4096 Jan 1 backups\n"}, chunks)
+}
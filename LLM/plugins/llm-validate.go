@@ -0,0 +1,74 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Validate reports every configuration error that would otherwise only show up on
+// the first attacker command: missing model or credentials for the provider, a
+// protocol without prompt, a prompt template that does not render, invalid regexes.
+// Keys resolved by a KeyProvider cannot be checked here
+func (llm *LLMHoneypot) Validate() error {
+	var errs []error
+
+	if llm.Model == "" && llm.Provider != Exec {
+		errs = append(errs, errors.New("model is empty"))
+	}
+	switch llm.Provider {
+	case Ollama:
+	case OpenAI:
+		if llm.OpenAIKey == "" && llm.KeyProvider == nil {
+			errs = append(errs, errors.New("openAIKey is empty"))
+		}
+	case Gemini:
+		switch {
+		case llm.VertexAI && (llm.GCPProject == "" || llm.GCPRegion == ""):
+			errs = append(errs, errors.New("gcpProject and gcpRegion are required for Vertex AI"))
+		case !llm.VertexAI && llm.GoogleAPIKey == "" && llm.KeyProvider == nil:
+			errs = append(errs, errors.New("googleAPIKey is empty"))
+		}
+	case Cohere:
+		if llm.CohereKey == "" && llm.KeyProvider == nil {
+			errs = append(errs, errors.New("cohereKey is empty"))
+		}
+	case Compatible:
+		if llm.CompatibleBaseURL == "" {
+			errs = append(errs, errors.New("compatibleBaseURL is empty"))
+		}
+	case Exec:
+		if len(llm.ExecCommand) == 0 {
+			errs = append(errs, errors.New("execCommand is empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("provider %d not supported", llm.Provider))
+	}
+
+	if _, err := llm.persona(); err != nil {
+		errs = append(errs, err)
+	}
+	patterns := []struct{ name, pattern string }{
+		{"sshPromptRegex", llm.SSHPromptRegex},
+		{"canaryPattern", llm.CanaryPattern},
+	}
+	for _, pattern := range llm.DenyPatterns {
+		patterns = append(patterns, struct{ name, pattern string }{"denyPatterns entry", pattern})
+	}
+	for _, field := range patterns {
+		if field.pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(field.pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %v", field.name, err))
+		}
+	}
+	// a client used as is ignores the TLS options
+	if llm.RestyClient == nil && llm.HTTPClient == nil {
+		if _, err := llm.tlsConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid TLS settings: %v", err))
+		}
+	}
+	errs = append(errs, llm.validateExtraHeaders()...)
+	return errors.Join(errs...)
+}
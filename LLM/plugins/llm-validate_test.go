@@ -0,0 +1,72 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitLLMHoneypotStrict(t *testing.T) {
+	//Given
+	config := LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Provider:  OpenAI,
+		Model:     "gpt-4o",
+		OpenAIKey: "sdjdnklfjndslkjanfk",
+		EnvPolicy: EnvIgnore,
+	}
+
+	//When
+	honeypot, err := InitLLMHoneypotStrict(config)
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "gpt-4o", honeypot.Model)
+}
+
+func TestInitLLMHoneypotStrictMissingCredentials(t *testing.T) {
+	//Given
+	config := LLMHoneypot{
+		Protocol:  tracer.SSH,
+		Provider:  Gemini,
+		EnvPolicy: EnvIgnore,
+	}
+
+	//When
+	honeypot, err := InitLLMHoneypotStrict(config)
+
+	//Then
+	assert.Nil(t, honeypot)
+	assert.ErrorContains(t, err, "invalid LLMHoneypot configuration")
+	assert.ErrorContains(t, err, "model is empty")
+	assert.ErrorContains(t, err, "googleAPIKey is empty")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		llm   LLMHoneypot
+		error string
+	}{
+		{"ollama needs no key", LLMHoneypot{Protocol: tracer.SSH, Provider: Ollama, Model: "llama3"}, ""},
+		{"key provider", LLMHoneypot{Protocol: tracer.SSH, Provider: Cohere, Model: "command-r", KeyProvider: &rotatingKeys{}}, ""},
+		{"vertex", LLMHoneypot{Protocol: tracer.SSH, Provider: Gemini, Model: "gemini-pro", VertexAI: true}, "gcpProject and gcpRegion are required for Vertex AI"},
+		{"compatible", LLMHoneypot{Protocol: tracer.SSH, Provider: Compatible, Model: "mixtral"}, "compatibleBaseURL is empty"},
+		{"exec", LLMHoneypot{Protocol: tracer.SSH, Provider: Exec}, "execCommand is empty"},
+		{"protocol", LLMHoneypot{Protocol: tracer.SIP + 100, Provider: Ollama, Model: "llama3"}, "no prompt for protocol selected"},
+		{"template", LLMHoneypot{Protocol: tracer.SSH, Provider: Ollama, Model: "llama3", CustomPrompt: "{{.Missing}}"}, "rendering prompt template"},
+		{"regex", LLMHoneypot{Protocol: tracer.SSH, Provider: Ollama, Model: "llama3", SSHPromptRegex: "("}, "invalid sshPromptRegex"},
+		{"deny pattern", LLMHoneypot{Protocol: tracer.SSH, Provider: Ollama, Model: "llama3", DenyPatterns: []string{`(?i)honeypot`, "["}}, "invalid denyPatterns entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.llm.Validate()
+			if tt.error == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.error)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package plugins
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	gcpDefaultTokenURI    = "https://oauth2.googleapis.com/token"
+)
+
+// TokenSource returns an OAuth2 access token, it is called before every Vertex AI request
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource for a token obtained elsewhere, e.g. `gcloud auth print-access-token`
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+func (llm *LLMHoneypot) vertexAIEndpoint() (string, error) {
+	if llm.GCPProject == "" || llm.GCPRegion == "" {
+		return "", errors.New("gcpProject and gcpRegion are required for Vertex AI")
+	}
+	return fmt.Sprintf(vertexEndpoint, llm.GCPRegion, llm.GCPProject, llm.Model), nil
+}
+
+func (llm *LLMHoneypot) gcpTokenSource() TokenSource {
+	if llm.GCPTokenSource != nil {
+		return llm.GCPTokenSource
+	}
+	return credentialsFileTokenSource(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+}
+
+// serviceAccountSources keeps one cached token per credentials file across honeypot instances
+var serviceAccountSources sync.Map
+
+type credentialsFileTokenSource string
+
+func (path credentialsFileTokenSource) Token(ctx context.Context) (string, error) {
+	if path == "" {
+		return "", errors.New("GOOGLE_APPLICATION_CREDENTIALS is not set")
+	}
+	if source, ok := serviceAccountSources.Load(string(path)); ok {
+		return source.(*serviceAccountTokenSource).Token(ctx)
+	}
+
+	source, err := newServiceAccountTokenSource(string(path))
+	if err != nil {
+		return "", err
+	}
+	actual, _ := serviceAccountSources.LoadOrStore(string(path), source)
+	return actual.(*serviceAccountTokenSource).Token(ctx)
+}
+
+// serviceAccountTokenSource implements the OAuth2 JWT bearer grant of Google service accounts
+type serviceAccountTokenSource struct {
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+	client   *resty.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newServiceAccountTokenSource(path string) (*serviceAccountTokenSource, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account: %v", err)
+	}
+	var file struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing service account: %v", err)
+	}
+	if file.Type != "service_account" {
+		return nil, fmt.Errorf("unsupported credentials type %q, only service_account is supported", file.Type)
+	}
+
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing service account private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private key is not RSA")
+	}
+
+	tokenURI := file.TokenURI
+	if tokenURI == "" {
+		tokenURI = gcpDefaultTokenURI
+	}
+	return &serviceAccountTokenSource{email: file.ClientEmail, key: key, tokenURI: tokenURI, client: resty.New()}, nil
+}
+
+func (s *serviceAccountTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Before(s.expiry.Add(-time.Minute)) {
+		return s.token, nil
+	}
+
+	assertion, err := s.assertion(now)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	resp, err := s.client.R().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"grant_type": "urn:ietf:params:oauth:grant-type:jwt-bearer",
+			"assertion":  assertion,
+		}).
+		SetResult(&result).
+		Post(s.tokenURI)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode() != 200 || result.AccessToken == "" {
+		return "", fmt.Errorf("token exchange failed: %s – %s", resp.Status(), resp.String())
+	}
+
+	s.token = result.AccessToken
+	s.expiry = now.Add(time.Duration(result.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// assertion is the RS256 signed JWT exchanged for an access token
+func (s *serviceAccountTokenSource) assertion(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   s.email,
+		"scope": gcpCloudPlatformScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,129 @@
+package plugins
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/mariocandela/beelzebub/v3/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildExecuteModelVertexAI(t *testing.T) {
+	client := resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	// Given
+	var authorization string
+	httpmock.RegisterResponder("POST",
+		"https://europe-west4-aiplatform.googleapis.com/v1/projects/honeynet/locations/europe-west4/publishers/google/models/gemini-1.5-pro:generateContent",
+		func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get("Authorization")
+			return newJSONStringResponse(`{"candidates":[{"content":{"parts":[{"text":"prova.txt"}]}}]}`), nil
+		},
+	)
+
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "gemini-1.5-pro",
+		Provider:       Gemini,
+		VertexAI:       true,
+		GCPProject:     "honeynet",
+		GCPRegion:      "europe-west4",
+		GCPTokenSource: StaticToken("ya29.token"),
+	})
+	honeypot.client = client
+
+	//When
+	str, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "prova.txt", str)
+	assert.Equal(t, "Bearer ya29.token", authorization)
+}
+
+func TestBuildExecuteModelVertexAIMissingProject(t *testing.T) {
+	//Given
+	honeypot := InitLLMHoneypot(LLMHoneypot{
+		Protocol:       tracer.SSH,
+		Model:          "gemini-1.5-pro",
+		Provider:       Gemini,
+		VertexAI:       true,
+		GCPTokenSource: StaticToken("ya29.token"),
+	})
+
+	//When
+	_, err := honeypot.ExecuteModel("ls")
+
+	//Then
+	assert.Equal(t, "gcpProject and gcpRegion are required for Vertex AI", err.Error())
+}
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	//Given
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+
+	exchanges := 0
+	var assertion string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		r.ParseForm()
+		assertion = r.PostForm.Get("assertion")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"ya29.exchanged","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	credentials, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "honeypot@honeynet.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL,
+	})
+	path := filepath.Join(t.TempDir(), "sa.json")
+	os.WriteFile(path, credentials, 0600)
+	source := credentialsFileTokenSource(path)
+
+	//When
+	first, err := source.Token(context.Background())
+	second, _ := source.Token(context.Background())
+
+	//Then
+	assert.Nil(t, err)
+	assert.Equal(t, "ya29.exchanged", first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, exchanges)
+
+	parts := strings.Split(assertion, ".")
+	assert.Equal(t, 3, len(parts))
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.Nil(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.Contains(t, string(claims), `"iss":"honeypot@honeynet.iam.gserviceaccount.com"`)
+}
+
+func TestCredentialsFileTokenSourceUnset(t *testing.T) {
+	_, err := credentialsFileTokenSource("").Token(context.Background())
+
+	assert.Equal(t, "GOOGLE_APPLICATION_CREDENTIALS is not set", err.Error())
+}
@@ -0,0 +1,152 @@
+// Package llmtest runs fake OpenAI, Ollama and Gemini providers on httptest servers,
+// so that the callers of the plugins package can be tested without network or keys.
+// Point LLMHoneypot.Host at Server.Host():
+//
+//	server := llmtest.NewOpenAIServer(t, llmtest.OpenAIReply("prova.txt"))
+//	honeypot := plugins.InitLLMHoneypot(plugins.LLMHoneypot{Provider: plugins.OpenAI, Host: server.Host(), ...})
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Response is a canned answer of a fake provider
+type Response struct {
+	Status int
+	Body   string
+}
+
+// Canned failures shared by every provider shape
+var (
+	// Malformed is a 200 whose body is not valid JSON
+	Malformed = Response{Status: http.StatusOK, Body: `{"choices":[{"message":`}
+	// OpenAIEmptyChoices is a 200 without any choice
+	OpenAIEmptyChoices = Response{Status: http.StatusOK, Body: `{"choices":[]}`}
+	// GeminiEmptyCandidates is a 200 without any candidate
+	GeminiEmptyCandidates = Response{Status: http.StatusOK, Body: `{"candidates":[]}`}
+)
+
+// OpenAIReply is a chat completion answering content
+func OpenAIReply(content string) Response {
+	return Response{Status: http.StatusOK, Body: fmt.Sprintf(
+		`{"choices":[{"message":{"role":"assistant","content":%s}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+		quote(content))}
+}
+
+// OllamaReply is a non-streamed /api/chat answer with content
+func OllamaReply(content string) Response {
+	return Response{Status: http.StatusOK, Body: fmt.Sprintf(
+		`{"message":{"role":"assistant","content":%s},"done":true,"prompt_eval_count":10,"eval_count":5}`,
+		quote(content))}
+}
+
+// GeminiReply is a generateContent answer with content
+func GeminiReply(content string) Response {
+	return Response{Status: http.StatusOK, Body: fmt.Sprintf(
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":%s}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}`,
+		quote(content))}
+}
+
+// OpenAIError is an error in the OpenAI shape, also used by compatible gateways
+func OpenAIError(status int, message string) Response {
+	return Response{Status: status, Body: fmt.Sprintf(`{"error":{"message":%s,"type":"invalid_request_error"}}`, quote(message))}
+}
+
+// OllamaError is an error in the Ollama shape
+func OllamaError(status int, message string) Response {
+	return Response{Status: status, Body: fmt.Sprintf(`{"error":%s}`, quote(message))}
+}
+
+// GeminiError is an error in the Google API shape
+func GeminiError(status int, message string) Response {
+	return Response{Status: status, Body: fmt.Sprintf(`{"error":{"code":%d,"message":%s,"status":"INVALID_ARGUMENT"}}`, status, quote(message))}
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// Server is a fake provider answering its responses in order, the last one is
+// repeated once they are exhausted. A request to any other path gets a 404
+type Server struct {
+	*httptest.Server
+	path      func(string) bool
+	host      string
+	mu        sync.Mutex
+	responses []Response
+	requests  [][]byte
+}
+
+// NewOpenAIServer fakes POST /v1/chat/completions, Host() is the full endpoint
+func NewOpenAIServer(t testing.TB, responses ...Response) *Server {
+	s := newServer(t, responses, func(path string) bool { return path == "/v1/chat/completions" })
+	s.host = s.URL + "/v1/chat/completions"
+	return s
+}
+
+// NewOllamaServer fakes POST /api/chat, Host() is the full endpoint
+func NewOllamaServer(t testing.TB, responses ...Response) *Server {
+	s := newServer(t, responses, func(path string) bool { return path == "/api/chat" })
+	s.host = s.URL + "/api/chat"
+	return s
+}
+
+// NewGeminiServer fakes POST /v1beta/models/{model}:generateContent for any model,
+// Host() is the base URL the caller adds the path to
+func NewGeminiServer(t testing.TB, responses ...Response) *Server {
+	s := newServer(t, responses, func(path string) bool {
+		return strings.HasPrefix(path, "/v1beta/models/") && strings.HasSuffix(path, ":generateContent")
+	})
+	s.host = s.URL
+	return s
+}
+
+func newServer(t testing.TB, responses []Response, path func(string) bool) *Server {
+	if len(responses) == 0 {
+		t.Fatal("llmtest: a fake provider needs at least one response")
+	}
+	s := &Server{path: path, responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Host is the value to set as LLMHoneypot.Host
+func (s *Server) Host() string {
+	return s.host
+}
+
+// Requests returns the bodies received so far, in order
+func (s *Server) Requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !s.path(r.URL.Path) {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, body)
+	response := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.Status)
+	io.WriteString(w, response.Body)
+}
@@ -0,0 +1,53 @@
+package llmtest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func post(t *testing.T, url, body string) (int, string) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(answer)
+}
+
+func TestServerRepliesInOrderAndRepeatsTheLast(t *testing.T) {
+	//Given
+	server := NewOllamaServer(t, OllamaError(503, "loading model"), OllamaReply("prova.txt"))
+
+	//When
+	firstStatus, _ := post(t, server.Host(), `{"n":1}`)
+	secondStatus, second := post(t, server.Host(), `{"n":2}`)
+	thirdStatus, third := post(t, server.Host(), `{"n":3}`)
+
+	//Then
+	assert.Equal(t, 503, firstStatus)
+	assert.Equal(t, 200, secondStatus)
+	assert.Equal(t, 200, thirdStatus)
+	assert.Equal(t, second, third)
+	assert.Contains(t, second, `"content":"prova.txt"`)
+	assert.Equal(t, [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)}, server.Requests())
+}
+
+func TestServerRejectsOtherPaths(t *testing.T) {
+	//Given
+	openAI := NewOpenAIServer(t, OpenAIReply("ok"))
+	gemini := NewGeminiServer(t, GeminiReply("ok"))
+
+	//When
+	wrongPath, _ := post(t, openAI.URL+"/api/chat", `{}`)
+	geminiModel, _ := post(t, gemini.Host()+"/v1beta/models/gemini-1.5-flash:generateContent", `{}`)
+
+	//Then
+	assert.Equal(t, http.StatusNotFound, wrongPath)
+	assert.Equal(t, http.StatusOK, geminiModel)
+	assert.Empty(t, openAI.Requests())
+}
@@ -89,7 +89,7 @@ func buildHTTPResponse(servConf parser.BeelzebubServiceConfiguration, tr tracer.
 		Headers:    command.Headers,
 		StatusCode: command.StatusCode,
 	}
-	traceRequest(request, tr, command, servConf.Description)
+	event := traceRequest(request, tr, command, servConf.Description)
 
 	if command.Plugin == plugins.LLMPluginName {
 		llmProvider, err := plugins.FromStringToLLMProvider(servConf.Plugin.LLMProvider)
@@ -107,6 +107,10 @@ func buildHTTPResponse(servConf parser.BeelzebubServiceConfiguration, tr tracer.
 			Model:        servConf.Plugin.LLMModel,
 			Provider:     llmProvider,
 			CustomPrompt: servConf.Plugin.Prompt,
+			Tracer:       tr,
+			SessionID:    event.ID,
+			SourceIP:     event.SourceIp,
+			HTTPRequest:  httpRequestContext(request),
 		}
 		llmHoneypotInstance := plugins.InitLLMHoneypot(llmHoneypot)
 		command := fmt.Sprintf("%s %s", request.Method, request.RequestURI)
@@ -121,12 +125,30 @@ func buildHTTPResponse(servConf parser.BeelzebubServiceConfiguration, tr tracer.
 	return resp, nil
 }
 
-func traceRequest(request *http.Request, tr tracer.Tracer, command parser.Command, HoneypotDescription string) {
+func httpRequestContext(request *http.Request) *plugins.HTTPRequestContext {
+	headers := request.Header.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Host", request.Host)
+
+	bodyBytes, _ := io.ReadAll(request.Body)
+	return &plugins.HTTPRequestContext{
+		Method:  request.Method,
+		Path:    request.RequestURI,
+		Headers: headers,
+		Body:    string(bodyBytes),
+	}
+}
+
+func traceRequest(request *http.Request, tr tracer.Tracer, command parser.Command, HoneypotDescription string) tracer.Event {
 	bodyBytes, err := io.ReadAll(request.Body)
 	body := ""
 	if err == nil {
 		body = string(bodyBytes)
 	}
+	// Put the body back, the LLM plugin reads it again to build the prompt.
+	request.Body = io.NopCloser(strings.NewReader(body))
 	host, port, _ := net.SplitHostPort(request.RemoteAddr)
 
 	event := tracer.Event{
@@ -154,6 +176,7 @@ func traceRequest(request *http.Request, tr tracer.Tracer, command parser.Comman
 		event.TLSServerName = request.TLS.ServerName
 	}
 	tr.TraceEvent(event)
+	return event
 }
 
 func mapHeaderToString(headers http.Header) string {
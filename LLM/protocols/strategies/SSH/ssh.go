@@ -63,6 +63,9 @@ func (sshStrategy *SSHStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 									Model:        servConf.Plugin.LLMModel,
 									Provider:     llmProvider,
 									CustomPrompt: servConf.Plugin.Prompt,
+									Tracer:       tr,
+									SessionID:    uuidSession.String(),
+									SourceIP:     host,
 								}
 								llmHoneypotInstance := plugins.InitLLMHoneypot(llmHoneypot)
 								if commandOutput, err = llmHoneypotInstance.ExecuteModel(sess.RawCommand()); err != nil {
@@ -142,6 +145,9 @@ func (sshStrategy *SSHStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 									Model:        servConf.Plugin.LLMModel,
 									Provider:     llmProvider,
 									CustomPrompt: servConf.Plugin.Prompt,
+									Tracer:       tr,
+									SessionID:    uuidSession.String(),
+									SourceIP:     host,
 								}
 								llmHoneypotInstance := plugins.InitLLMHoneypot(llmHoneypot)
 								if commandOutput, err = llmHoneypotInstance.ExecuteModel(commandInput); err != nil {
@@ -38,6 +38,9 @@ type Event struct {
 	SourcePort      string
 	TLSServerName   string
 	Handler         string
+	LLMProvider     string
+	LLMModel        string
+	Error           string
 }
 
 type (
@@ -50,10 +53,19 @@ const (
 	SSH
 	TCP
 	MCP
+	DNS
+	SIP
+	RDP
+	VNC
+	SNMP
+	POSTGRES
+	IMAP
+	POP3
+	LDAP
 )
 
 func (protocol Protocol) String() string {
-	return [...]string{"HTTP", "SSH", "TCP", "MCP"}[protocol]
+	return [...]string{"HTTP", "SSH", "TCP", "MCP", "DNS", "SIP", "RDP", "VNC", "SNMP", "POSTGRES", "IMAP", "POP3", "LDAP"}[protocol]
 }
 
 const (
@@ -61,10 +73,13 @@ const (
 	End
 	Stateless
 	Interaction
+	// LLMInteraction details the model call behind an event the strategy already traced,
+	// it is not counted again in the Prometheus counters
+	LLMInteraction
 )
 
 func (status Status) String() string {
-	return [...]string{"Start", "End", "Stateless", "Interaction"}[status]
+	return [...]string{"Start", "End", "Stateless", "Interaction", "LLMInteraction"}[status]
 }
 
 type Strategy func(event Event)
@@ -145,7 +160,9 @@ func (tracer *tracer) TraceEvent(event Event) {
 
 	tracer.eventsChan <- event
 
-	tracer.updatePrometheusCounters(event.Protocol)
+	if event.Status != LLMInteraction.String() {
+		tracer.updatePrometheusCounters(event.Protocol)
+	}
 }
 
 func (tracer *tracer) updatePrometheusCounters(protocol string) {
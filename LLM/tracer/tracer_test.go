@@ -77,6 +77,7 @@ func TestStringStatus(t *testing.T) {
 	assert.Equal(t, End.String(), "End")
 	assert.Equal(t, Stateless.String(), "Stateless")
 	assert.Equal(t, Interaction.String(), "Interaction")
+	assert.Equal(t, LLMInteraction.String(), "LLMInteraction")
 }
 
 type mockCounter struct {
@@ -121,3 +122,24 @@ func TestUpdatePrometheusCounters(t *testing.T) {
 	tracer.updatePrometheusCounters(MCP.String())
 	assert.Equal(t, 8, counter)
 }
+
+func TestTraceEventDoesNotCountLLMInteractions(t *testing.T) {
+	mockStrategy := func(event Event) {}
+
+	tracer := &tracer{
+		strategy:        mockStrategy,
+		eventsChan:      make(chan Event, Workers),
+		eventsTotal:     mockCounter{},
+		eventsSSHTotal:  mockCounter{},
+		eventsTCPTotal:  mockCounter{},
+		eventsHTTPTotal: mockCounter{},
+		eventsMCPTotal:  mockCounter{},
+	}
+	counter = 0
+
+	tracer.TraceEvent(Event{Protocol: SSH.String(), Status: Interaction.String()})
+	tracer.TraceEvent(Event{Protocol: SSH.String(), Status: LLMInteraction.String()})
+
+	assert.Equal(t, 2, counter)
+	assert.Len(t, tracer.eventsChan, 2)
+}